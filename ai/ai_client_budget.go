@@ -0,0 +1,370 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultOutputReserve = 1024
+
+// tenantKey is the context key for the tenant attached by WithTenant
+type tenantKey struct{}
+
+// WithTenant returns a context whose requests are charged to tenant by WithBudget
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant attached to ctx, or "" if there is none
+func TenantFrom(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// BudgetLimits caps what a tenant may use per UTC day and calendar month.
+// Zero-valued fields are unlimited.
+type BudgetLimits struct {
+	DailyTokens   int     `json:"daily_tokens,omitempty"`
+	MonthlyTokens int     `json:"monthly_tokens,omitempty"`
+	DailySpend    float64 `json:"daily_spend,omitempty"`   // US dollars
+	MonthlySpend  float64 `json:"monthly_spend,omitempty"` // US dollars
+}
+
+// BudgetAmount is a quantity of tokens and spend, used for counters and reservations
+type BudgetAmount struct {
+	Tokens int     `json:"tokens"`
+	Spend  float64 `json:"spend"`
+}
+
+// add returns the sum of two amounts
+func (a BudgetAmount) add(other BudgetAmount) BudgetAmount {
+	return BudgetAmount{Tokens: a.Tokens + other.Tokens, Spend: a.Spend + other.Spend}
+}
+
+// BudgetPeriods names the day and month a request is counted in
+type BudgetPeriods struct {
+	Day   string // UTC date such as "2025-06-01"
+	Month string // UTC month such as "2025-06"
+}
+
+// BudgetPeriodsAt returns the periods containing t
+func BudgetPeriodsAt(t time.Time) BudgetPeriods {
+	t = t.UTC()
+	return BudgetPeriods{Day: t.Format("2006-01-02"), Month: t.Format("2006-01")}
+}
+
+// BudgetExceededError is returned when a request would take a tenant over a limit.
+// It matches ErrorCategoryQuota with errors.Is and is never retried.
+type BudgetExceededError struct {
+	Tenant    string
+	Period    string  // "day" or "month"
+	Resource  string  // "tokens" or "spend"
+	Limit     float64 // Configured limit
+	Used      float64 // Amount already used or reserved in the period
+	Requested float64 // Amount the request would reserve
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("tenant %s would exceed its %s %s budget: %g used, %g requested, limit %g",
+		e.Tenant, e.Period, e.Resource, e.Used, e.Requested, e.Limit)
+}
+
+// Unwrap lets errors.Is(err, ErrorCategoryQuota) and CategoryOf recognize the error
+func (e *BudgetExceededError) Unwrap() error {
+	return ErrorCategoryQuota
+}
+
+// checkBudget returns a *BudgetExceededError if adding amount to the day and month
+// counters would exceed limits
+func checkBudget(tenant string, limits BudgetLimits, day, month, amount BudgetAmount) error {
+	checks := []struct {
+		period, resource string
+		limit, used, req float64
+	}{
+		{"day", "tokens", float64(limits.DailyTokens), float64(day.Tokens), float64(amount.Tokens)},
+		{"month", "tokens", float64(limits.MonthlyTokens), float64(month.Tokens), float64(amount.Tokens)},
+		{"day", "spend", limits.DailySpend, day.Spend, amount.Spend},
+		{"month", "spend", limits.MonthlySpend, month.Spend, amount.Spend},
+	}
+
+	for _, check := range checks {
+		if check.limit > 0 && check.used+check.req > check.limit {
+			return &BudgetExceededError{
+				Tenant:    tenant,
+				Period:    check.period,
+				Resource:  check.resource,
+				Limit:     check.limit,
+				Used:      check.used,
+				Requested: check.req,
+			}
+		}
+	}
+	return nil
+}
+
+// BudgetPolicy configures WithBudget
+type BudgetPolicy struct {
+	Store         BudgetStore  // Holds limits and counters, defaults to a new MemoryBudgetStore
+	DefaultLimits BudgetLimits // Limits for tenants without their own in Store
+	Prices        PriceTable   // Prices for spend limits, defaults to DefaultPrices()
+	OutputReserve int          // Output tokens reserved when config.MaxTokens is 0, defaults to 1024
+	RequireTenant bool         // Reject requests without a tenant instead of letting them through uncounted
+
+	// Model prices reservations, defaults to the ModelID passed to Initialize. Requests
+	// from tenants with spend limits fail while the model has no price, since their
+	// spend could not be reserved.
+	Model string
+}
+
+// budgetClient wraps a Client and enforces per-tenant budgets
+type budgetClient struct {
+	client Client
+	policy BudgetPolicy
+
+	mu    sync.Mutex
+	model string
+}
+
+// WithBudget wraps client so that requests are charged to the tenant in their context.
+// The estimated prompt plus the output allowance is reserved before the request is sent,
+// and requests that would exceed a limit fail with a *BudgetExceededError. The reservation
+// is reconciled with the actual usage afterwards, or released if the request fails.
+func WithBudget(client Client, policy BudgetPolicy) Client {
+	if policy.Store == nil {
+		policy.Store = NewMemoryBudgetStore()
+	}
+	if policy.Prices.Prices == nil {
+		policy.Prices = DefaultPrices()
+	}
+	if policy.OutputReserve <= 0 {
+		policy.OutputReserve = defaultOutputReserve
+	}
+	return &budgetClient{client: client, policy: policy, model: policy.Model}
+}
+
+// Initialize initializes the wrapped client and remembers its model for pricing
+func (c *budgetClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	if c.policy.Model == "" {
+		c.setModel(opts.ModelID)
+	}
+	return c.client.Initialize(ctx, opts)
+}
+
+// TextCompletion sends a text request within the tenant's budget
+func (c *budgetClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(ctx, messages, config, func() (Response, error) {
+		return c.client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text within the tenant's budget
+func (c *budgetClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(ctx, messages, config, func() (Response, error) {
+		return c.client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// StreamCompletion streams a response within the tenant's budget. The reservation is
+// reconciled when the usage event arrives. A stream that ends without one, because the
+// consumer stopped reading or the provider sent none, is charged for its prompt and the
+// output received so far.
+func (c *budgetClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	reservation, err := c.reserve(ctx, messages, config)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream, err := c.client.StreamCompletion(ctx, messages, config)
+	if err != nil {
+		c.release(ctx, reservation)
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+
+		var output strings.Builder
+		failed := false
+		defer func() {
+			// Settling is a no-op once the usage event has reconciled the reservation
+			if failed && output.Len() == 0 {
+				c.release(ctx, reservation)
+				return
+			}
+			c.settlePartial(ctx, reservation, output.String())
+		}()
+
+		for event := range upstream {
+			switch event.Type {
+			case StreamEventText:
+				output.WriteString(event.Text)
+			case StreamEventToolCall:
+				if event.ToolCall != nil {
+					output.WriteString(event.ToolCall.Name + event.ToolCall.Arguments)
+				}
+			case StreamEventUsage:
+				c.reconcile(ctx, reservation, event.Model, event.TokenUsage, event.Cost)
+			case StreamEventError:
+				failed = true
+			}
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// CountTokens counts prompt tokens with the wrapped client
+func (c *budgetClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return c.client.CountTokens(ctx, messages, config)
+}
+
+// Close closes the wrapped client
+func (c *budgetClient) Close() error {
+	return c.client.Close()
+}
+
+// budgetReservation is what was reserved for one request
+type budgetReservation struct {
+	tenant  string
+	periods BudgetPeriods
+	amount  BudgetAmount
+	input   int // Estimated prompt tokens
+	settled bool
+}
+
+// do reserves budget, runs call and reconciles the reservation with the response
+func (c *budgetClient) do(ctx context.Context, messages []InputMessage, config ModelConfig, call func() (Response, error)) (Response, error) {
+	reservation, err := c.reserve(ctx, messages, config)
+	if err != nil {
+		return Response{}, err
+	}
+
+	response, err := call()
+	if err != nil {
+		c.release(ctx, reservation)
+		return response, err
+	}
+
+	c.reconcile(ctx, reservation, response.Model, response.TokenUsage, response.Cost)
+	return response, nil
+}
+
+// reserve charges the estimated usage of a request to the tenant. It returns a nil
+// reservation for requests without a tenant when RequireTenant is off.
+func (c *budgetClient) reserve(ctx context.Context, messages []InputMessage, config ModelConfig) (*budgetReservation, error) {
+	tenant := TenantFrom(ctx)
+	if tenant == "" {
+		if c.policy.RequireTenant {
+			return nil, fmt.Errorf("%w: request has no tenant", ErrorCategoryQuota)
+		}
+		return nil, nil
+	}
+
+	limits, ok, err := c.policy.Store.Limits(ctx, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("error loading budget for tenant %s: %w", tenant, err)
+	}
+	if !ok {
+		limits = c.policy.DefaultLimits
+	}
+
+	output := int(config.MaxTokens)
+	if output <= 0 {
+		output = c.policy.OutputReserve
+	}
+	input := EstimateTokens(messages, config)
+	estimate := TokenUsage{InputTokens: input, OutputTokens: output, TotalTokens: input + output}
+
+	reservation := &budgetReservation{
+		tenant:  tenant,
+		periods: BudgetPeriodsAt(time.Now()),
+		amount:  BudgetAmount{Tokens: estimate.TotalTokens},
+		input:   input,
+	}
+	// Spend limits cannot be enforced without knowing what the request costs
+	price, priced := c.policy.Prices.Lookup(c.currentModel())
+	if priced {
+		reservation.amount.Spend = price.Cost(estimate)
+	} else if limits.DailySpend > 0 || limits.MonthlySpend > 0 {
+		return nil, fmt.Errorf("%w: tenant %s has a spend limit but model %q has no price, set BudgetPolicy.Model or Prices",
+			ErrorCategoryInvalidRequest, tenant, c.currentModel())
+	}
+
+	if err := c.policy.Store.Reserve(ctx, tenant, reservation.periods, reservation.amount, limits); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// reconcile replaces the reservation with the actual usage. A cost set by
+// WithCostTracker is used as-is, otherwise the usage is priced here with the model that
+// served it. When the provider reports no usage, the estimate stays charged.
+func (c *budgetClient) reconcile(ctx context.Context, reservation *budgetReservation, model string, usage TokenUsage, cost float64) {
+	if reservation == nil || reservation.settled {
+		return
+	}
+	reservation.settled = true
+	if usageTokens(usage) == 0 && cost == 0 {
+		return
+	}
+
+	if cost == 0 {
+		if model == "" {
+			model = c.currentModel()
+		}
+		if price, ok := c.policy.Prices.Lookup(model); ok {
+			cost = price.Cost(usage)
+		}
+	}
+
+	delta := BudgetAmount{
+		Tokens: usageTokens(usage) - reservation.amount.Tokens,
+		Spend:  cost - reservation.amount.Spend,
+	}
+	// The request has already been served, so a failure to record it cannot fail it
+	_ = c.policy.Store.Adjust(context.WithoutCancel(ctx), reservation.tenant, reservation.periods, delta)
+}
+
+// settlePartial reconciles a reservation that got no usage report with the estimated
+// prompt and the estimated size of output
+func (c *budgetClient) settlePartial(ctx context.Context, reservation *budgetReservation, output string) {
+	if reservation == nil || reservation.settled {
+		return
+	}
+
+	outputTokens := countTextTokens(output)
+	usage := TokenUsage{InputTokens: reservation.input, OutputTokens: outputTokens, TotalTokens: reservation.input + outputTokens}
+	c.reconcile(ctx, reservation, c.currentModel(), usage, 0)
+}
+
+// release returns an unused reservation to the tenant
+func (c *budgetClient) release(ctx context.Context, reservation *budgetReservation) {
+	if reservation == nil || reservation.settled {
+		return
+	}
+	reservation.settled = true
+
+	delta := BudgetAmount{Tokens: -reservation.amount.Tokens, Spend: -reservation.amount.Spend}
+	_ = c.policy.Store.Adjust(context.WithoutCancel(ctx), reservation.tenant, reservation.periods, delta)
+}
+
+// currentModel returns the model used to price reservations
+func (c *budgetClient) currentModel() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.model
+}
+
+// setModel remembers the model used to price reservations
+func (c *budgetClient) setModel(model string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.model = model
+}
@@ -0,0 +1,287 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NaheedRayan/openrouter-go/internal/fileutil"
+)
+
+// BudgetStore holds per-tenant limits and usage counters for WithBudget
+type BudgetStore interface {
+	// Limits returns the tenant's own limits, or false if it has none
+	Limits(ctx context.Context, tenant string) (BudgetLimits, bool, error)
+
+	// SetLimits gives a tenant its own limits
+	SetLimits(ctx context.Context, tenant string, limits BudgetLimits) error
+
+	// Reserve adds amount to the tenant's counters for periods, atomically checking that
+	// the result stays within limits. It returns a *BudgetExceededError if not.
+	Reserve(ctx context.Context, tenant string, periods BudgetPeriods, amount BudgetAmount, limits BudgetLimits) error
+
+	// Adjust adds delta, which may be negative, to the tenant's counters without checking limits
+	Adjust(ctx context.Context, tenant string, periods BudgetPeriods, delta BudgetAmount) error
+
+	// Usage returns the tenant's counters for a day and month
+	Usage(ctx context.Context, tenant string, periods BudgetPeriods) (day, month BudgetAmount, err error)
+}
+
+// budgetState is the data behind the built-in stores. Counters are keyed by tenant and
+// then by period, with days and months in the same map since their formats differ.
+type budgetState struct {
+	Limits   map[string]BudgetLimits            `json:"limits"`
+	Counters map[string]map[string]BudgetAmount `json:"counters"`
+}
+
+func newBudgetState() budgetState {
+	return budgetState{
+		Limits:   map[string]BudgetLimits{},
+		Counters: map[string]map[string]BudgetAmount{},
+	}
+}
+
+// usage returns a tenant's day and month counters
+func (s budgetState) usage(tenant string, periods BudgetPeriods) (day, month BudgetAmount) {
+	counters := s.Counters[tenant]
+	return counters[periods.Day], counters[periods.Month]
+}
+
+// adjust adds delta to a tenant's day and month counters and drops the tenant's
+// counters for periods that have ended
+func (s budgetState) adjust(tenant string, periods BudgetPeriods, delta BudgetAmount) {
+	counters := s.Counters[tenant]
+	if counters == nil {
+		counters = map[string]BudgetAmount{}
+		s.Counters[tenant] = counters
+	}
+	counters[periods.Day] = counters[periods.Day].add(delta)
+	counters[periods.Month] = counters[periods.Month].add(delta)
+	pruneCounters(counters, periods)
+}
+
+// pruneCounters drops counters older than the day and month before periods. The previous
+// period is kept for requests that started before midnight and settle after it.
+func pruneCounters(counters map[string]BudgetAmount, periods BudgetPeriods) {
+	day, err := time.Parse("2006-01-02", periods.Day)
+	if err != nil {
+		return
+	}
+	previous := BudgetPeriodsAt(day.AddDate(0, 0, -1))
+	previousMonth := BudgetPeriodsAt(time.Date(day.Year(), day.Month()-1, 1, 0, 0, 0, 0, time.UTC)).Month
+
+	// Days and months have different lengths, and each sorts by date within its own kind
+	for key := range counters {
+		if (len(key) == len(previous.Day) && key < previous.Day) || (len(key) == len(previousMonth) && key < previousMonth) {
+			delete(counters, key)
+		}
+	}
+}
+
+// reserve checks limits and adds amount if they allow it
+func (s budgetState) reserve(tenant string, periods BudgetPeriods, amount BudgetAmount, limits BudgetLimits) error {
+	day, month := s.usage(tenant, periods)
+	if err := checkBudget(tenant, limits, day, month, amount); err != nil {
+		return err
+	}
+	s.adjust(tenant, periods, amount)
+	return nil
+}
+
+// MemoryBudgetStore keeps limits and counters in memory for the life of the process
+type MemoryBudgetStore struct {
+	mu    sync.Mutex
+	state budgetState
+}
+
+// NewMemoryBudgetStore creates an empty in-memory store
+func NewMemoryBudgetStore() *MemoryBudgetStore {
+	return &MemoryBudgetStore{state: newBudgetState()}
+}
+
+// Limits returns the tenant's own limits
+func (s *MemoryBudgetStore) Limits(ctx context.Context, tenant string) (BudgetLimits, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limits, ok := s.state.Limits[tenant]
+	return limits, ok, nil
+}
+
+// SetLimits gives a tenant its own limits
+func (s *MemoryBudgetStore) SetLimits(ctx context.Context, tenant string, limits BudgetLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.Limits[tenant] = limits
+	return nil
+}
+
+// Reserve adds amount to the tenant's counters if limits allow it
+func (s *MemoryBudgetStore) Reserve(ctx context.Context, tenant string, periods BudgetPeriods, amount BudgetAmount, limits BudgetLimits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.reserve(tenant, periods, amount, limits)
+}
+
+// Adjust adds delta to the tenant's counters
+func (s *MemoryBudgetStore) Adjust(ctx context.Context, tenant string, periods BudgetPeriods, delta BudgetAmount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.state.adjust(tenant, periods, delta)
+	return nil
+}
+
+// Usage returns the tenant's counters for a day and month
+func (s *MemoryBudgetStore) Usage(ctx context.Context, tenant string, periods BudgetPeriods) (BudgetAmount, BudgetAmount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day, month := s.state.usage(tenant, periods)
+	return day, month, nil
+}
+
+// FileBudgetStore keeps limits and counters in a JSON file, so budgets survive restarts.
+// Every operation takes a lock on a ".lock" file next to it and reads the file afresh, so
+// several processes on one host may share a store. Changes rewrite the whole file, which
+// stays small because counters for ended periods are dropped; busy services should back
+// BudgetStore with a database instead.
+type FileBudgetStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileBudgetStore opens the store at path, starting empty if the file does not exist
+func NewFileBudgetStore(path string) (*FileBudgetStore, error) {
+	s := &FileBudgetStore{path: path}
+	// Fail early on a file that cannot be read
+	if err := s.view(func(budgetState) {}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// lock takes the in-process and cross-process locks
+func (s *FileBudgetStore) lock() (unlock func(), err error) {
+	s.mu.Lock()
+	unlockFile, err := fileutil.LockFile(s.path + ".lock")
+	if err != nil {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("error locking budget store: %w", err)
+	}
+	return func() {
+		unlockFile()
+		s.mu.Unlock()
+	}, nil
+}
+
+// load reads the state from disk. The caller must hold the lock.
+func (s *FileBudgetStore) load() (budgetState, error) {
+	state := newBudgetState()
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("error decoding budget store: %w", err)
+	}
+	if state.Limits == nil {
+		state.Limits = map[string]BudgetLimits{}
+	}
+	if state.Counters == nil {
+		state.Counters = map[string]map[string]BudgetAmount{}
+	}
+	return state, nil
+}
+
+// view runs read on the current state
+func (s *FileBudgetStore) view(read func(budgetState)) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	read(state)
+	return nil
+}
+
+// update runs change on the current state and writes the result unless change fails
+func (s *FileBudgetStore) update(change func(budgetState) error) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := change(state); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding budget store: %w", err)
+	}
+	return fileutil.WriteFileAtomic(s.path, data)
+}
+
+// Limits returns the tenant's own limits
+func (s *FileBudgetStore) Limits(ctx context.Context, tenant string) (limits BudgetLimits, ok bool, err error) {
+	err = s.view(func(state budgetState) {
+		limits, ok = state.Limits[tenant]
+	})
+	return limits, ok, err
+}
+
+// SetLimits gives a tenant its own limits and saves the store
+func (s *FileBudgetStore) SetLimits(ctx context.Context, tenant string, limits BudgetLimits) error {
+	return s.update(func(state budgetState) error {
+		state.Limits[tenant] = limits
+		return nil
+	})
+}
+
+// Reserve adds amount to the tenant's counters if limits allow it and saves the store
+func (s *FileBudgetStore) Reserve(ctx context.Context, tenant string, periods BudgetPeriods, amount BudgetAmount, limits BudgetLimits) error {
+	return s.update(func(state budgetState) error {
+		return state.reserve(tenant, periods, amount, limits)
+	})
+}
+
+// Adjust adds delta to the tenant's counters and saves the store. A zero delta writes nothing.
+func (s *FileBudgetStore) Adjust(ctx context.Context, tenant string, periods BudgetPeriods, delta BudgetAmount) error {
+	if delta == (BudgetAmount{}) {
+		return nil
+	}
+	return s.update(func(state budgetState) error {
+		state.adjust(tenant, periods, delta)
+		return nil
+	})
+}
+
+// Usage returns the tenant's counters for a day and month
+func (s *FileBudgetStore) Usage(ctx context.Context, tenant string, periods BudgetPeriods) (day, month BudgetAmount, err error) {
+	err = s.view(func(state budgetState) {
+		day, month = state.usage(tenant, periods)
+	})
+	return day, month, err
+}
@@ -0,0 +1,188 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// budgetStores returns a fresh instance of each built-in store
+func budgetStores(t *testing.T) map[string]BudgetStore {
+	t.Helper()
+
+	file, err := NewFileBudgetStore(filepath.Join(t.TempDir(), "budgets.json"))
+	if err != nil {
+		t.Fatalf("open file store: %v", err)
+	}
+	return map[string]BudgetStore{"memory": NewMemoryBudgetStore(), "file": file}
+}
+
+var budgetTestPeriods = BudgetPeriods{Day: "2025-06-15", Month: "2025-06"}
+
+func TestBudgetStoreReserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  BudgetLimits
+		amounts []BudgetAmount
+		period  string // Period of the limit hit by the last amount, "" if every amount fits
+		day     BudgetAmount
+	}{
+		{"unlimited", BudgetLimits{}, []BudgetAmount{{Tokens: 1e9, Spend: 1e6}}, "", BudgetAmount{Tokens: 1e9, Spend: 1e6}},
+		{"within daily tokens", BudgetLimits{DailyTokens: 100}, []BudgetAmount{{Tokens: 60}, {Tokens: 40}}, "", BudgetAmount{Tokens: 100}},
+		{"over daily tokens", BudgetLimits{DailyTokens: 100}, []BudgetAmount{{Tokens: 60}, {Tokens: 41}}, "day", BudgetAmount{Tokens: 60}},
+		{"over monthly tokens", BudgetLimits{DailyTokens: 100, MonthlyTokens: 50}, []BudgetAmount{{Tokens: 60}}, "month", BudgetAmount{}},
+		{"over daily spend", BudgetLimits{DailySpend: 1}, []BudgetAmount{{Spend: 0.75}, {Spend: 0.5}}, "day", BudgetAmount{Spend: 0.75}},
+		{"over monthly spend", BudgetLimits{MonthlySpend: 1}, []BudgetAmount{{Spend: 1.5}}, "month", BudgetAmount{}},
+	}
+
+	for name, store := range budgetStores(t) {
+		for i, tt := range tests {
+			tenant := string(rune('a' + i))
+			var err error
+			for _, amount := range tt.amounts {
+				err = store.Reserve(context.Background(), tenant, budgetTestPeriods, amount, tt.limits)
+			}
+
+			var exceeded *BudgetExceededError
+			if tt.period == "" && err != nil {
+				t.Errorf("%s, %s: unexpected error: %v", name, tt.name, err)
+			}
+			if tt.period != "" && (!errors.As(err, &exceeded) || exceeded.Period != tt.period || !errors.Is(err, ErrorCategoryQuota)) {
+				t.Errorf("%s, %s: got %v, want a %s limit error", name, tt.name, err, tt.period)
+			}
+
+			day, month, err := store.Usage(context.Background(), tenant, budgetTestPeriods)
+			if err != nil || day != tt.day || month != tt.day {
+				t.Errorf("%s, %s: got day %+v and month %+v, %v, want %+v", name, tt.name, day, month, err, tt.day)
+			}
+		}
+	}
+}
+
+func TestBudgetStoreAdjustAndLimits(t *testing.T) {
+	for name, store := range budgetStores(t) {
+		ctx := context.Background()
+
+		if _, ok, err := store.Limits(ctx, "a"); ok || err != nil {
+			t.Errorf("%s: found limits for a new tenant", name)
+		}
+		limits := BudgetLimits{DailyTokens: 10, MonthlySpend: 2}
+		if err := store.SetLimits(ctx, "a", limits); err != nil {
+			t.Fatalf("%s: set limits: %v", name, err)
+		}
+		if got, ok, err := store.Limits(ctx, "a"); !ok || err != nil || got != limits {
+			t.Errorf("%s: got limits %+v, %v, %v", name, got, ok, err)
+		}
+
+		store.Reserve(ctx, "a", budgetTestPeriods, BudgetAmount{Tokens: 8, Spend: 1}, limits)
+		store.Adjust(ctx, "a", budgetTestPeriods, BudgetAmount{Tokens: -5, Spend: -0.5})
+		// Adjustments are not checked against limits
+		store.Adjust(ctx, "a", budgetTestPeriods, BudgetAmount{Tokens: 20})
+
+		day, _, _ := store.Usage(ctx, "a", budgetTestPeriods)
+		if day != (BudgetAmount{Tokens: 23, Spend: 0.5}) {
+			t.Errorf("%s: got %+v", name, day)
+		}
+	}
+}
+
+func TestBudgetStorePrunesEndedPeriods(t *testing.T) {
+	periods := []BudgetPeriods{
+		{Day: "2025-04-30", Month: "2025-04"},
+		{Day: "2025-05-30", Month: "2025-05"},
+		{Day: "2025-05-31", Month: "2025-05"},
+		{Day: "2025-06-01", Month: "2025-06"},
+	}
+
+	for name, store := range budgetStores(t) {
+		ctx := context.Background()
+		for _, p := range periods {
+			store.Adjust(ctx, "a", p, BudgetAmount{Tokens: 1})
+		}
+
+		tests := []struct {
+			periods BudgetPeriods
+			day     int
+			month   int
+		}{
+			{periods[0], 0, 0}, // Ended before the previous day and month
+			{periods[1], 0, 2}, // The day ended before yesterday, the month is the previous one
+			{periods[2], 1, 2}, // Yesterday is kept for requests that settle after midnight
+			{periods[3], 1, 1},
+		}
+		for _, tt := range tests {
+			day, month, _ := store.Usage(ctx, "a", tt.periods)
+			if day.Tokens != tt.day || month.Tokens != tt.month {
+				t.Errorf("%s, %s: got %d and %d tokens, want %d and %d", name, tt.periods.Day, day.Tokens, month.Tokens, tt.day, tt.month)
+			}
+		}
+	}
+}
+
+func TestFileBudgetStoreIsShared(t *testing.T) {
+	// Interleaving between the stores needs goroutines running in parallel
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	path := filepath.Join(t.TempDir(), "budgets.json")
+	first, err := NewFileBudgetStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	second, err := NewFileBudgetStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	// Two stores on one file stand in for two processes; together they must respect the limit
+	limits := BudgetLimits{DailyTokens: 50}
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for i := 0; i < 100; i++ {
+		store := first
+		if i%2 == 1 {
+			store = second
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if store.Reserve(context.Background(), "a", budgetTestPeriods, BudgetAmount{Tokens: 1}, limits) == nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	day, _, err := first.Usage(context.Background(), "a", budgetTestPeriods)
+	if err != nil || reserved != 50 || day.Tokens != 50 {
+		t.Errorf("reserved %d with %d tokens counted, %v, want 50", reserved, day.Tokens, err)
+	}
+
+	// A store opened later sees everything
+	reopened, err := NewFileBudgetStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if day, _, _ := reopened.Usage(context.Background(), "a", budgetTestPeriods); day.Tokens != 50 {
+		t.Errorf("got %d tokens after reopening", day.Tokens)
+	}
+}
+
+func TestFileBudgetStoreRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budgets.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileBudgetStore(path); err == nil {
+		t.Error("opened a corrupt store")
+	}
+}
@@ -0,0 +1,204 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// budgetTestPrices charges $1 per million input tokens and $2 per million output tokens
+var budgetTestPrices = PriceTable{Prices: map[string]ModelPrice{"priced-model": {Input: 1, Output: 2}}}
+
+var budgetTestMessages = []InputMessage{{Role: RoleUser, Content: "hello there"}}
+
+// budgetTestEstimate is what WithBudget reserves for budgetTestMessages with maxTokens
+func budgetTestEstimate(maxTokens int) TokenUsage {
+	input := EstimateTokens(budgetTestMessages, ModelConfig{})
+	return TokenUsage{InputTokens: input, OutputTokens: maxTokens, TotalTokens: input + maxTokens}
+}
+
+// tenantUsage returns what the store has counted for tenant today
+func tenantUsage(t *testing.T, store BudgetStore, tenant string) BudgetAmount {
+	t.Helper()
+
+	day, _, err := store.Usage(context.Background(), tenant, BudgetPeriodsAt(time.Now()))
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	return day
+}
+
+func TestBudgetReconcile(t *testing.T) {
+	actual := TokenUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}
+	estimate := budgetTestEstimate(100)
+	price := budgetTestPrices.Prices["priced-model"]
+
+	tests := []struct {
+		name     string
+		response Response
+		err      error
+		want     BudgetAmount
+	}{
+		{"actual usage replaces the estimate", Response{Model: "priced-model", TokenUsage: actual}, nil, BudgetAmount{Tokens: 15, Spend: price.Cost(actual)}},
+		{"cost from the response is used as-is", Response{Model: "priced-model", TokenUsage: actual, Cost: 0.5}, nil, BudgetAmount{Tokens: 15, Spend: 0.5}},
+		{"no usage keeps the estimate", Response{Model: "priced-model"}, nil, BudgetAmount{Tokens: estimate.TotalTokens, Spend: price.Cost(estimate)}},
+		{"failure releases the reservation", Response{}, &Error{Category: ErrorCategoryServer}, BudgetAmount{}},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryBudgetStore()
+		client := &fakeClient{response: tt.response, err: tt.err}
+		budget := WithBudget(client, BudgetPolicy{Store: store, Prices: budgetTestPrices, Model: "priced-model"})
+
+		_, err := budget.TextCompletion(WithTenant(context.Background(), "a"), budgetTestMessages, ModelConfig{MaxTokens: 100})
+		if (err != nil) != (tt.err != nil) {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+		if got := tenantUsage(t, store, "a"); got.Tokens != tt.want.Tokens || math.Abs(got.Spend-tt.want.Spend) > 1e-12 {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBudgetRejectsBeforeSending(t *testing.T) {
+	estimate := budgetTestEstimate(100)
+
+	tests := []struct {
+		name    string
+		policy  BudgetPolicy
+		tenant  string
+		limits  *BudgetLimits
+		want    ErrorCategory
+		exceeds bool
+	}{
+		{"within the default limits", BudgetPolicy{DefaultLimits: BudgetLimits{DailyTokens: estimate.TotalTokens}}, "a", nil, "", false},
+		{"over the default limits", BudgetPolicy{DefaultLimits: BudgetLimits{DailyTokens: estimate.TotalTokens - 1}}, "a", nil, ErrorCategoryQuota, true},
+		{"tenant limits override defaults", BudgetPolicy{DefaultLimits: BudgetLimits{DailyTokens: 1}}, "a", &BudgetLimits{MonthlyTokens: 1_000_000}, "", false},
+		{"no tenant passes uncounted", BudgetPolicy{DefaultLimits: BudgetLimits{DailyTokens: 1}}, "", nil, "", false},
+		{"no tenant with RequireTenant", BudgetPolicy{RequireTenant: true}, "", nil, ErrorCategoryQuota, false},
+		{"spend limit without a price", BudgetPolicy{DefaultLimits: BudgetLimits{DailySpend: 1}}, "a", nil, ErrorCategoryInvalidRequest, false},
+		{"spend limit with an unknown model", BudgetPolicy{Model: "unpriced-model", DefaultLimits: BudgetLimits{MonthlySpend: 1}}, "a", nil, ErrorCategoryInvalidRequest, false},
+		{"spend limit with a priced model", BudgetPolicy{Model: "priced-model", DefaultLimits: BudgetLimits{DailySpend: 1}}, "a", nil, "", false},
+		{"over a spend limit", BudgetPolicy{Model: "priced-model", DefaultLimits: BudgetLimits{DailySpend: 1e-9}}, "a", nil, ErrorCategoryQuota, true},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryBudgetStore()
+		if tt.limits != nil {
+			store.SetLimits(context.Background(), tt.tenant, *tt.limits)
+		}
+		tt.policy.Store = store
+		tt.policy.Prices = budgetTestPrices
+		client := &fakeClient{response: Response{Text: "ok"}}
+
+		ctx := context.Background()
+		if tt.tenant != "" {
+			ctx = WithTenant(ctx, tt.tenant)
+		}
+		_, err := WithBudget(client, tt.policy).TextCompletion(ctx, budgetTestMessages, ModelConfig{MaxTokens: 100})
+
+		if tt.want == "" {
+			if err != nil || client.calls.Load() != 1 {
+				t.Errorf("%s: got %v after %d calls", tt.name, err, client.calls.Load())
+			}
+			continue
+		}
+		var exceeded *BudgetExceededError
+		if !errors.Is(err, tt.want) || errors.As(err, &exceeded) != tt.exceeds || client.calls.Load() != 0 {
+			t.Errorf("%s: got %v after %d calls, want %s before sending", tt.name, err, client.calls.Load(), tt.want)
+		}
+		if IsRetryable(err) {
+			t.Errorf("%s: budget errors must not be retried", tt.name)
+		}
+	}
+}
+
+func TestBudgetDefaults(t *testing.T) {
+	client := &fakeClient{response: Response{Text: "ok"}}
+	// Without a store, a memory store is used rather than panicking
+	budget := WithBudget(client, BudgetPolicy{Prices: budgetTestPrices, DefaultLimits: BudgetLimits{DailySpend: 1}})
+
+	// The model passed to Initialize prices reservations from the first request
+	if err := budget.Initialize(context.Background(), ClientOptions{ModelID: "priced-model"}); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	if _, err := budget.TextCompletion(WithTenant(context.Background(), "a"), budgetTestMessages, ModelConfig{}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Without MaxTokens, OutputReserve is reserved
+	store := NewMemoryBudgetStore()
+	limited := WithBudget(&fakeClient{response: Response{Text: "ok"}}, BudgetPolicy{Store: store, OutputReserve: 10})
+	limited.TextCompletion(WithTenant(context.Background(), "a"), budgetTestMessages, ModelConfig{})
+	if got := tenantUsage(t, store, "a").Tokens; got != budgetTestEstimate(10).TotalTokens {
+		t.Errorf("got %d tokens reserved, want %d", got, budgetTestEstimate(10).TotalTokens)
+	}
+}
+
+func TestBudgetConcurrentReservations(t *testing.T) {
+	estimate := budgetTestEstimate(100)
+	store := NewMemoryBudgetStore()
+	client := &fakeClient{response: Response{Text: "ok"}, delay: 20 * time.Millisecond}
+	// Limits allow three requests at a time; the fake reports no usage, so estimates stay charged
+	budget := WithBudget(client, BudgetPolicy{Store: store, DefaultLimits: BudgetLimits{DailyTokens: 3 * estimate.TotalTokens}})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	served, rejected := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := budget.TextCompletion(WithTenant(context.Background(), "a"), budgetTestMessages, ModelConfig{MaxTokens: 100})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				served++
+			} else if errors.Is(err, ErrorCategoryQuota) {
+				rejected++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if served != 3 || rejected != 7 || client.calls.Load() != 3 {
+		t.Errorf("served %d and rejected %d with %d calls, want 3 and 7", served, rejected, client.calls.Load())
+	}
+}
+
+func TestBudgetStream(t *testing.T) {
+	actual := TokenUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}
+
+	tests := []struct {
+		name     string
+		response Response
+		err      error
+		want     int
+	}{
+		{"usage event reconciles", Response{Text: "hi", TokenUsage: actual}, nil, 15},
+		{"error before output releases", Response{}, &Error{Category: ErrorCategoryServer}, 0},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryBudgetStore()
+		budget := WithBudget(&fakeClient{response: tt.response, err: tt.err}, BudgetPolicy{Store: store})
+
+		events, err := budget.StreamCompletion(WithTenant(context.Background(), "a"), budgetTestMessages, ModelConfig{MaxTokens: 100})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		CollectStream(events)
+
+		// Settling happens as the stream goroutine exits, just after the channel closes
+		deadline := time.Now().Add(time.Second)
+		for tenantUsage(t, store, "a").Tokens != tt.want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if got := tenantUsage(t, store, "a").Tokens; got != tt.want {
+			t.Errorf("%s: got %d tokens, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through while failures are counted
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request fast until the cooldown has passed
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe requests through to test whether the endpoint recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// circuitBuckets is how many slices the failure-rate window is divided into
+const circuitBuckets = 10
+
+// minCircuitWindow is the shortest window, so that each slice lasts at least a millisecond
+const minCircuitWindow = circuitBuckets * time.Millisecond
+
+// CircuitBreakerPolicy controls when a CircuitBreaker opens and closes
+type CircuitBreakerPolicy struct {
+	FailureRate    float64          // Share of failed requests in the window that opens the circuit, defaults to 0.5
+	MinRequests    int              // Requests needed in the window before the rate is judged, defaults to 10
+	Window         time.Duration    // Rolling window the failure rate is measured over, defaults to 1m, at least 10ms
+	Cooldown       time.Duration    // Time the circuit stays open before probing, defaults to 30s
+	HalfOpenProbes int              // Successful probes needed to close the circuit, defaults to 1
+	IsFailure      func(error) bool // Decides which errors count against the endpoint, defaults to server errors and timeouts
+
+	// OnStateChange, if set, is called after every transition, outside the breaker's lock
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+// withDefaults fills unset fields
+func (p CircuitBreakerPolicy) withDefaults() CircuitBreakerPolicy {
+	if p.FailureRate <= 0 || p.FailureRate > 1 {
+		p.FailureRate = 0.5
+	}
+	if p.MinRequests <= 0 {
+		p.MinRequests = 10
+	}
+	if p.Window <= 0 {
+		p.Window = time.Minute
+	} else if p.Window < minCircuitWindow {
+		p.Window = minCircuitWindow
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = 30 * time.Second
+	}
+	if p.HalfOpenProbes <= 0 {
+		p.HalfOpenProbes = 1
+	}
+	if p.IsFailure == nil {
+		p.IsFailure = isEndpointFailure
+	}
+	return p
+}
+
+// isEndpointFailure reports whether err suggests the endpoint itself is unhealthy
+func isEndpointFailure(err error) bool {
+	switch CategoryOf(err) {
+	case ErrorCategoryServer, ErrorCategoryTimeout:
+		return true
+	}
+	return false
+}
+
+// CircuitOpenError is returned while a circuit is open, without calling the provider.
+// It matches ErrorCategoryCircuitOpen with errors.Is, so a Router falls back on it.
+type CircuitOpenError struct {
+	Key        string        // Key of the breaker, such as "bedrock/amazon.nova-pro-v1:0"
+	State      CircuitState  // CircuitOpen, or CircuitHalfOpen when all probe slots are taken
+	RetryAfter time.Duration // Time until the breaker lets a probe through, 0 if unknown
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("circuit %s for %s, retry after %s", e.State, e.Key, e.RetryAfter.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("circuit %s for %s", e.State, e.Key)
+}
+
+// Unwrap lets errors.Is(err, ErrorCategoryCircuitOpen) and CategoryOf recognize the error
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrorCategoryCircuitOpen
+}
+
+// circuitBucket counts outcomes in one slice of the window
+type circuitBucket struct {
+	epoch     int64
+	successes int
+	failures  int
+}
+
+// CircuitBreaker tracks the failure rate of one endpoint, typically a provider and model,
+// and stops sending it requests while it is failing. A CircuitBreaker is safe for
+// concurrent use and may be shared by every client for the same endpoint.
+type CircuitBreaker struct {
+	key    string
+	policy CircuitBreakerPolicy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	state     CircuitState
+	openedAt  time.Time
+	buckets   [circuitBuckets]circuitBucket
+	probes    int // Probes in flight while half-open
+	successes int // Successful probes while half-open
+}
+
+// NewCircuitBreaker creates a closed breaker. The key names it in errors and callbacks.
+func NewCircuitBreaker(key string, policy CircuitBreakerPolicy) *CircuitBreaker {
+	return newCircuitBreaker(key, policy, time.Now)
+}
+
+// newCircuitBreaker creates a breaker that reads the time from clock
+func newCircuitBreaker(key string, policy CircuitBreakerPolicy, clock func() time.Time) *CircuitBreaker {
+	return &CircuitBreaker{key: key, policy: policy.withDefaults(), now: clock}
+}
+
+var (
+	sharedBreakersMu sync.Mutex
+	sharedBreakers   = map[string]*CircuitBreaker{}
+)
+
+// SharedCircuitBreaker returns the process-wide breaker for key, creating it with policy
+// on first use. Later calls return the same breaker and ignore policy.
+func SharedCircuitBreaker(key string, policy CircuitBreakerPolicy) *CircuitBreaker {
+	sharedBreakersMu.Lock()
+	defer sharedBreakersMu.Unlock()
+
+	if breaker, ok := sharedBreakers[key]; ok {
+		return breaker
+	}
+	breaker := NewCircuitBreaker(key, policy)
+	sharedBreakers[key] = breaker
+	return breaker
+}
+
+// Key returns the name of the breaker
+func (b *CircuitBreaker) Key() string {
+	return b.key
+}
+
+// State returns the current state, moving an open circuit to half-open once its cooldown has passed
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	from := b.state
+	b.advance(b.now())
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return to
+}
+
+// Allow asks to send a request. If the request may go ahead, the returned function must
+// be called with its outcome; otherwise a *CircuitOpenError is returned.
+func (b *CircuitBreaker) Allow() (func(error), error) {
+	b.mu.Lock()
+	now := b.now()
+	from := b.state
+	b.advance(now)
+	to := b.state
+
+	var err error
+	probe := false
+	switch b.state {
+	case CircuitOpen:
+		err = &CircuitOpenError{Key: b.key, State: CircuitOpen, RetryAfter: b.openedAt.Add(b.policy.Cooldown).Sub(now)}
+	case CircuitHalfOpen:
+		if b.probes+b.successes >= b.policy.HalfOpenProbes {
+			err = &CircuitOpenError{Key: b.key, State: CircuitHalfOpen}
+		} else {
+			b.probes++
+			probe = true
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { b.record(probe, err) })
+	}, nil
+}
+
+// Reset closes the circuit and forgets every recorded outcome
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	from := b.state
+	b.transition(CircuitClosed, b.now())
+	b.mu.Unlock()
+
+	b.notify(from, CircuitClosed)
+}
+
+// record applies the outcome of a request
+func (b *CircuitBreaker) record(probe bool, err error) {
+	// A caller giving up says nothing about the endpoint
+	if CategoryOf(err) == ErrorCategoryCanceled {
+		if probe {
+			b.mu.Lock()
+			b.releaseProbe()
+			b.mu.Unlock()
+		}
+		return
+	}
+	failed := err != nil && b.policy.IsFailure(err)
+
+	b.mu.Lock()
+	now := b.now()
+	from := b.state
+
+	switch {
+	case probe && b.state == CircuitHalfOpen:
+		b.releaseProbe()
+		if failed {
+			b.transition(CircuitOpen, now)
+		} else if b.successes++; b.successes >= b.policy.HalfOpenProbes {
+			b.transition(CircuitClosed, now)
+		}
+	case b.state == CircuitClosed:
+		bucket := b.bucket(now)
+		if failed {
+			bucket.failures++
+		} else {
+			bucket.successes++
+		}
+
+		successes, failures := b.counts(now)
+		total := successes + failures
+		if total >= b.policy.MinRequests && float64(failures) >= b.policy.FailureRate*float64(total) {
+			b.transition(CircuitOpen, now)
+		}
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// releaseProbe frees a half-open probe slot. The caller must hold the lock.
+func (b *CircuitBreaker) releaseProbe() {
+	if b.probes > 0 {
+		b.probes--
+	}
+}
+
+// advance moves an open circuit to half-open once its cooldown has passed. The caller must hold the lock.
+func (b *CircuitBreaker) advance(now time.Time) {
+	if b.state == CircuitOpen && now.Sub(b.openedAt) >= b.policy.Cooldown {
+		b.transition(CircuitHalfOpen, now)
+	}
+}
+
+// transition enters a state and resets what the state tracks. The caller must hold the lock.
+func (b *CircuitBreaker) transition(state CircuitState, now time.Time) {
+	b.state = state
+	b.probes, b.successes = 0, 0
+	switch state {
+	case CircuitOpen:
+		b.openedAt = now
+	case CircuitClosed:
+		b.buckets = [circuitBuckets]circuitBucket{}
+	}
+}
+
+// bucket returns the window slice for now, clearing it if it holds an older slice. The caller must hold the lock.
+func (b *CircuitBreaker) bucket(now time.Time) *circuitBucket {
+	epoch := now.UnixNano() / int64(b.policy.Window/circuitBuckets)
+	bucket := &b.buckets[epoch%circuitBuckets]
+	if bucket.epoch != epoch {
+		*bucket = circuitBucket{epoch: epoch}
+	}
+	return bucket
+}
+
+// counts sums the outcomes within the window. The caller must hold the lock.
+func (b *CircuitBreaker) counts(now time.Time) (successes, failures int) {
+	epoch := now.UnixNano() / int64(b.policy.Window/circuitBuckets)
+	for _, bucket := range b.buckets {
+		if epoch-bucket.epoch < circuitBuckets {
+			successes += bucket.successes
+			failures += bucket.failures
+		}
+	}
+	return successes, failures
+}
+
+// notify reports a transition to OnStateChange
+func (b *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(b.key, from, to)
+	}
+}
+
+// circuitClient wraps a Client with a circuit breaker
+type circuitClient struct {
+	client   Client
+	endpoint bool                 // Whether the breaker is picked per provider and model
+	policy   CircuitBreakerPolicy // Policy of endpoint breakers
+
+	mu      sync.Mutex
+	breaker *CircuitBreaker // Given, or the endpoint breaker once the endpoint is known
+	model   string          // Model from Initialize, used in the endpoint key
+}
+
+// WithCircuitBreaker wraps client so that requests fail fast with a *CircuitOpenError
+// while breaker is open. Wrap every client for the same endpoint with the same breaker,
+// for example one from SharedCircuitBreaker. A nil breaker behaves like
+// WithEndpointCircuitBreaker with the default policy.
+func WithCircuitBreaker(client Client, breaker *CircuitBreaker) Client {
+	if breaker == nil {
+		return WithEndpointCircuitBreaker(client, CircuitBreakerPolicy{})
+	}
+	return &circuitClient{client: client, breaker: breaker}
+}
+
+// WithEndpointCircuitBreaker wraps client with the shared breaker of the provider and
+// model it calls, keyed like RateLimitKey. The model comes from Initialize and the
+// provider from the client's type. For clients initialized before wrapping, or wrapped
+// in other middleware, the endpoint is taken from the first response or *Error
+// instead, and requests pass unguarded until then.
+func WithEndpointCircuitBreaker(client Client, policy CircuitBreakerPolicy) Client {
+	return &circuitClient{client: client, endpoint: true, policy: policy}
+}
+
+// Initialize initializes the wrapped client and, without a given breaker, picks the
+// breaker of the model's endpoint
+func (c *circuitClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	if err := c.client.Initialize(ctx, opts); err != nil {
+		return err
+	}
+	if !c.endpoint {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.model = opts.ModelID
+	if provider := clientProvider(c.client); provider != "" && c.model != "" {
+		c.breaker = SharedCircuitBreaker(RateLimitKey(provider, c.model), c.policy)
+	}
+	return nil
+}
+
+// TextCompletion sends a text request unless the circuit is open
+func (c *circuitClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(func() (Response, error) {
+		return c.client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text unless the circuit is open
+func (c *circuitClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(func() (Response, error) {
+		return c.client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// StreamCompletion starts a stream unless the circuit is open. The outcome is judged by
+// whether the stream produces its first event; later failures are passed through as-is.
+func (c *circuitClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	open := func() (<-chan StreamEvent, error) {
+		return openStream(ctx, func() (<-chan StreamEvent, error) {
+			return c.client.StreamCompletion(ctx, messages, config)
+		})
+	}
+
+	breaker := c.current()
+	if breaker == nil {
+		events, err := open()
+		c.learn("", "", err)
+		return events, err
+	}
+
+	done, err := breaker.Allow()
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := open()
+	done(err)
+	return events, err
+}
+
+// CountTokens counts prompt tokens with the wrapped client. Counting does not affect the circuit.
+func (c *circuitClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return c.client.CountTokens(ctx, messages, config)
+}
+
+// Close closes the wrapped client
+func (c *circuitClient) Close() error {
+	return c.client.Close()
+}
+
+// do runs call if the breaker allows it and records the outcome
+func (c *circuitClient) do(call func() (Response, error)) (Response, error) {
+	breaker := c.current()
+	if breaker == nil {
+		response, err := call()
+		c.learn(response.Provider, response.Model, err)
+		return response, err
+	}
+
+	done, err := breaker.Allow()
+	if err != nil {
+		return Response{}, err
+	}
+
+	response, err := call()
+	done(err)
+	return response, err
+}
+
+// current returns the breaker for requests, nil while the endpoint is not known yet
+func (c *circuitClient) current() *CircuitBreaker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.breaker
+}
+
+// learn picks the endpoint breaker from what an unguarded request reported, and
+// records the request's outcome on it
+func (c *circuitClient) learn(provider, model string, err error) {
+	var aiErr *Error
+	if provider == "" && errors.As(err, &aiErr) {
+		provider = aiErr.Provider
+	}
+
+	c.mu.Lock()
+	if c.model != "" {
+		model = c.model
+	}
+	if c.breaker != nil || provider == "" || model == "" {
+		c.mu.Unlock()
+		return
+	}
+	c.breaker = SharedCircuitBreaker(RateLimitKey(provider, model), c.policy)
+	breaker := c.breaker
+	c.mu.Unlock()
+
+	if done, allowErr := breaker.Allow(); allowErr == nil {
+		done(err)
+	}
+}
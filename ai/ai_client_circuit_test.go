@@ -0,0 +1,288 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// circuitStep is one request made through a breaker under test
+type circuitStep struct {
+	advance time.Duration // Clock movement before the request
+	err     error         // Outcome of the request, if it is allowed
+	blocked bool          // Whether the breaker should refuse the request
+	want    CircuitState  // State after the request
+}
+
+var (
+	serverFailure  = &Error{Category: ErrorCategoryServer}
+	timeoutFailure = &Error{Category: ErrorCategoryTimeout}
+	authFailure    = &Error{Category: ErrorCategoryAuth}
+)
+
+// repeat returns count copies of step
+func repeat(count int, step circuitStep) []circuitStep {
+	steps := make([]circuitStep, count)
+	for i := range steps {
+		steps[i] = step
+	}
+	return steps
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	policy := CircuitBreakerPolicy{FailureRate: 0.5, MinRequests: 4, Window: time.Minute, Cooldown: 10 * time.Second, HalfOpenProbes: 2}
+	ok := circuitStep{want: CircuitClosed}
+	fail := circuitStep{err: serverFailure, want: CircuitClosed}
+
+	tests := []struct {
+		name  string
+		steps []circuitStep
+	}{
+		{"stays closed below MinRequests", repeat(3, fail)},
+		{"opens at the failure rate", append(repeat(2, ok), fail, circuitStep{err: timeoutFailure, want: CircuitOpen})},
+		{"stays closed under the failure rate", append(repeat(3, ok), fail)},
+		{"only endpoint failures count", repeat(6, circuitStep{err: authFailure, want: CircuitClosed})},
+		{"canceled requests do not count", repeat(6, circuitStep{err: context.Canceled, want: CircuitClosed})},
+		{"failures leave the window", append(repeat(3, fail), circuitStep{advance: time.Minute, err: serverFailure, want: CircuitClosed})},
+		{"open fails fast until the cooldown", append(repeat(3, fail),
+			circuitStep{err: serverFailure, want: CircuitOpen},
+			circuitStep{advance: 9 * time.Second, blocked: true, want: CircuitOpen})},
+		{"probes close it", append(repeat(3, fail),
+			circuitStep{err: serverFailure, want: CircuitOpen},
+			circuitStep{advance: 10 * time.Second, want: CircuitHalfOpen},
+			circuitStep{want: CircuitClosed})},
+		{"a failed probe opens it again", append(repeat(3, fail),
+			circuitStep{err: serverFailure, want: CircuitOpen},
+			circuitStep{advance: 10 * time.Second, err: serverFailure, want: CircuitOpen},
+			circuitStep{advance: 5 * time.Second, blocked: true, want: CircuitOpen})},
+		{"closing forgets old failures", append(repeat(3, fail),
+			circuitStep{err: serverFailure, want: CircuitOpen},
+			circuitStep{advance: 10 * time.Second, want: CircuitHalfOpen},
+			circuitStep{want: CircuitClosed},
+			fail, fail, fail)},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		breaker := newCircuitBreaker("bedrock/model", policy, clock.Now)
+
+		for i, step := range tt.steps {
+			clock.Advance(step.advance)
+			done, err := breaker.Allow()
+			if step.blocked {
+				var openErr *CircuitOpenError
+				if !errors.As(err, &openErr) || !errors.Is(err, ErrorCategoryCircuitOpen) || !ShouldFallback(err) {
+					t.Errorf("%s: step %d: got %v, want a *CircuitOpenError", tt.name, i, err)
+				}
+			} else if err != nil {
+				t.Errorf("%s: step %d: unexpected error: %v", tt.name, i, err)
+			} else {
+				done(step.err)
+			}
+
+			if got := breaker.State(); got != step.want {
+				t.Errorf("%s: step %d: got %s, want %s", tt.name, i, got, step.want)
+				break
+			}
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbes(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newCircuitBreaker("k", CircuitBreakerPolicy{MinRequests: 1, Cooldown: time.Second, HalfOpenProbes: 1}, clock.Now)
+	done, _ := breaker.Allow()
+	done(serverFailure)
+
+	// While open, errors say when the next probe is allowed
+	clock.Advance(400 * time.Millisecond)
+	_, err := breaker.Allow()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.State != CircuitOpen || openErr.RetryAfter != 600*time.Millisecond {
+		t.Fatalf("got %v, want open with retry after 600ms", err)
+	}
+
+	// Half-open allows one probe at a time
+	clock.Advance(600 * time.Millisecond)
+	probe, err := breaker.Allow()
+	if err != nil {
+		t.Fatalf("probe refused: %v", err)
+	}
+	if _, err := breaker.Allow(); !errors.As(err, &openErr) || openErr.State != CircuitHalfOpen {
+		t.Errorf("got %v while the probe is in flight, want half-open", err)
+	}
+
+	// A canceled probe frees its slot without deciding anything
+	probe(context.Canceled)
+	probe, err = breaker.Allow()
+	if err != nil {
+		t.Fatalf("probe refused after a cancel: %v", err)
+	}
+	probe(nil)
+	probe(serverFailure) // Outcomes after the first are ignored
+	if got := breaker.State(); got != CircuitClosed {
+		t.Errorf("got %s, want closed", got)
+	}
+}
+
+func TestCircuitBreakerStateChanges(t *testing.T) {
+	var changes []string
+	clock := newFakeClock()
+	breaker := newCircuitBreaker("k", CircuitBreakerPolicy{
+		MinRequests: 1,
+		Cooldown:    time.Second,
+		OnStateChange: func(key string, from, to CircuitState) {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, from, to))
+		},
+	}, clock.Now)
+
+	done, _ := breaker.Allow()
+	done(serverFailure)
+	clock.Advance(time.Second)
+	done, _ = breaker.Allow()
+	done(nil)
+	breaker.Reset()
+
+	want := []string{"k: closed -> open", "k: open -> half-open", "k: half-open -> closed"}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("got %q, want %q", changes, want)
+	}
+}
+
+func TestCircuitBreakerWindowDefaults(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		want   time.Duration
+	}{
+		{0, time.Minute},
+		{-time.Second, time.Minute},
+		{time.Nanosecond, minCircuitWindow},
+		{9 * time.Nanosecond, minCircuitWindow},
+		{time.Second, time.Second},
+	}
+
+	for _, tt := range tests {
+		breaker := NewCircuitBreaker("k", CircuitBreakerPolicy{Window: tt.window})
+		if breaker.policy.Window != tt.want {
+			t.Errorf("window %s: got %s, want %s", tt.window, breaker.policy.Window, tt.want)
+		}
+		// Tiny windows used to divide by zero when counting
+		done, _ := breaker.Allow()
+		done(serverFailure)
+	}
+}
+
+func TestCircuitClient(t *testing.T) {
+	clock := newFakeClock()
+	breaker := newCircuitBreaker("k", CircuitBreakerPolicy{MinRequests: 2}, clock.Now)
+	failing := failingWith(ErrorCategoryServer)
+	client := WithCircuitBreaker(failing, breaker)
+
+	for i := 0; i < 2; i++ {
+		client.TextCompletion(context.Background(), nil, ModelConfig{})
+	}
+	_, err := client.TextCompletion(context.Background(), nil, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryCircuitOpen) || failing.calls.Load() != 2 {
+		t.Errorf("got %v after %d calls, want circuit open after 2", err, failing.calls.Load())
+	}
+	if _, err := client.StreamCompletion(context.Background(), nil, ModelConfig{}); !errors.Is(err, ErrorCategoryCircuitOpen) {
+		t.Errorf("got %v from a stream, want circuit open", err)
+	}
+	if _, err := client.CountTokens(context.Background(), nil, ModelConfig{}); errors.Is(err, ErrorCategoryCircuitOpen) {
+		t.Errorf("counting tokens should bypass the circuit")
+	}
+}
+
+func TestEndpointCircuitBreakerKey(t *testing.T) {
+	forgetSharedBreakers(t, "openai/key-test-response", "gemini/key-test-options", "bedrock/key-test-error")
+	tests := []struct {
+		name    string
+		model   string // Model passed to Initialize, if any
+		client  *fakeClient
+		wantKey string
+	}{
+		{"provider and model from the response", "", &fakeClient{response: Response{Provider: ProviderOpenAI, Model: "key-test-response"}}, "openai/key-test-response"},
+		{"model from the options", "key-test-options", &fakeClient{response: Response{Provider: ProviderGemini, Model: "key-test-options-001"}}, "gemini/key-test-options"},
+		{"provider from an error", "key-test-error", &fakeClient{err: &Error{Category: ErrorCategoryServer, Provider: ProviderBedrock}}, "bedrock/key-test-error"},
+		{"unknown endpoint stays unguarded", "", &fakeClient{err: errors.New("boom")}, ""},
+	}
+
+	for _, tt := range tests {
+		client := WithEndpointCircuitBreaker(tt.client, CircuitBreakerPolicy{}).(*circuitClient)
+		if tt.model != "" {
+			client.Initialize(context.Background(), ClientOptions{ModelID: tt.model})
+		}
+		client.TextCompletion(context.Background(), nil, ModelConfig{})
+
+		got := ""
+		if breaker := client.current(); breaker != nil {
+			got = breaker.Key()
+			if breaker != SharedCircuitBreaker(tt.wantKey, CircuitBreakerPolicy{}) {
+				t.Errorf("%s: breaker is not the shared one for its key", tt.name)
+			}
+		}
+		if got != tt.wantKey {
+			t.Errorf("%s: got key %q, want %q", tt.name, got, tt.wantKey)
+		}
+	}
+
+	// A nil breaker picks endpoint breakers too
+	if client, ok := WithCircuitBreaker(servedBy(ProviderOpenAI), nil).(*circuitClient); !ok || !client.endpoint {
+		t.Errorf("nil breaker should use endpoint breakers")
+	}
+}
+
+// forgetSharedBreakers drops process-wide breakers once a test is done, so that reruns start closed
+func forgetSharedBreakers(t *testing.T, keys ...string) {
+	t.Cleanup(func() {
+		sharedBreakersMu.Lock()
+		defer sharedBreakersMu.Unlock()
+		for _, key := range keys {
+			delete(sharedBreakers, key)
+		}
+	})
+}
+
+func TestEndpointCircuitBreakerOpensPerModel(t *testing.T) {
+	forgetSharedBreakers(t, "bedrock/per-model-degraded", "bedrock/per-model-healthy")
+	policy := CircuitBreakerPolicy{MinRequests: 2}
+	failing := &fakeClient{err: &Error{Category: ErrorCategoryServer, Provider: ProviderBedrock}}
+	degraded := WithEndpointCircuitBreaker(failing, policy)
+	degraded.Initialize(context.Background(), ClientOptions{ModelID: "per-model-degraded"})
+	healthy := WithEndpointCircuitBreaker(&fakeClient{response: Response{Provider: ProviderBedrock}}, policy)
+	healthy.Initialize(context.Background(), ClientOptions{ModelID: "per-model-healthy"})
+
+	// The first failure binds the breaker and counts, the second opens it
+	for i := 0; i < 3; i++ {
+		degraded.TextCompletion(context.Background(), nil, ModelConfig{})
+		healthy.TextCompletion(context.Background(), nil, ModelConfig{})
+	}
+	if failing.calls.Load() != 2 {
+		t.Errorf("got %d calls to the failing model, want 2", failing.calls.Load())
+	}
+	if _, err := healthy.TextCompletion(context.Background(), nil, ModelConfig{}); err != nil {
+		t.Errorf("other model affected: %v", err)
+	}
+}
+
+func TestClientProvider(t *testing.T) {
+	tests := []struct {
+		client Client
+		want   string
+	}{
+		{NewOpenAIClient(), ProviderOpenAI},
+		{NewGeminiClient(), ProviderGemini},
+		{NewBedrockClient(), ProviderBedrock},
+		{&fakeClient{}, ""},
+		{WithRetry(NewOpenAIClient(), RetryPolicy{}), ""},
+	}
+
+	for _, tt := range tests {
+		if got := clientProvider(tt.client); got != tt.want {
+			t.Errorf("%T: got %q, want %q", tt.client, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TrimStrategy selects how a ContextManager shortens a conversation that exceeds its budget
+type TrimStrategy int
+
+const (
+	// TrimDropOldest drops the oldest turns until the conversation fits
+	TrimDropOldest TrimStrategy = iota
+	// TrimKeepLast keeps the system messages and the last KeepLast messages
+	TrimKeepLast
+	// TrimSummarize replaces everything before the last KeepLast messages with a summary
+	TrimSummarize
+)
+
+const (
+	defaultKeepLast      = 4
+	defaultSummaryPrompt = "Summarize the conversation below so that it can replace the original messages. " +
+		"Keep facts, decisions, names, numbers and open questions that later turns may rely on. " +
+		"Reply with the summary only."
+)
+
+// ContextManager fits a conversation into a token budget. System messages are never
+// dropped, the latest user turn is always kept, and tool calls are kept or dropped
+// together with their results.
+type ContextManager struct {
+	Strategy    TrimStrategy
+	KeepLast    int // Messages kept verbatim by TrimKeepLast and TrimSummarize, defaults to 4
+	ImageWindow int // Images are kept only in the last ImageWindow user turns, 0 keeps every image
+
+	Summarizer    Client      // Client that writes summaries for TrimSummarize
+	SummaryConfig ModelConfig // Config for summary requests
+	SummaryPrompt string      // Instruction for summary requests, a default is used if empty
+
+	// CountTokens sizes a conversation, defaults to EstimateTokens
+	CountTokens func(messages []InputMessage, config ModelConfig) int
+}
+
+// TrimReport describes what a ContextManager removed from a conversation
+type TrimReport struct {
+	InputTokens   int            // Estimated prompt tokens before trimming
+	OutputTokens  int            // Estimated prompt tokens after trimming
+	Dropped       []InputMessage // Messages removed outright
+	Summarized    []InputMessage // Messages replaced by the summary
+	Summary       string         // Summary that replaced the Summarized messages
+	SummaryUsage  TokenUsage     // Tokens spent writing the summary
+	ImagesRemoved int            // Images stripped from older messages
+}
+
+// Trimmed reports whether the conversation was changed at all
+func (r TrimReport) Trimmed() bool {
+	return len(r.Dropped) > 0 || len(r.Summarized) > 0 || r.ImagesRemoved > 0
+}
+
+// ContextBudget returns the prompt tokens available to a model once config.MaxTokens is
+// reserved for the output, or 0 if the model is not in the capabilities table
+func ContextBudget(modelID string, config ModelConfig) int {
+	capabilities, ok := LookupModel(modelID)
+	if !ok {
+		return 0
+	}
+	return max(capabilities.ContextWindow-int(config.MaxTokens), 0)
+}
+
+// Fit trims messages until they fit budget prompt tokens, counted together with the
+// system prompt, tools and schema in config. System messages are moved to the front.
+// The caller's messages are not modified.
+func (m *ContextManager) Fit(ctx context.Context, messages []InputMessage, config ModelConfig, budget int) ([]InputMessage, TrimReport, error) {
+	if budget <= 0 {
+		return nil, TrimReport{}, fmt.Errorf("token budget must be positive")
+	}
+
+	var system, history []InputMessage
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			system = append(system, msg)
+		} else {
+			history = append(history, msg)
+		}
+	}
+
+	report := TrimReport{InputTokens: m.count(messages, config)}
+	m.stripImages(history, &report)
+
+	var summary []InputMessage
+	start := 0
+	assemble := func() []InputMessage {
+		result := make([]InputMessage, 0, len(system)+len(summary)+len(history)-start)
+		result = append(result, system...)
+		result = append(result, summary...)
+		return append(result, history[start:]...)
+	}
+	fits := func() bool {
+		return m.count(assemble(), config) <= budget
+	}
+
+	if !fits() {
+		switch m.Strategy {
+		case TrimDropOldest:
+		case TrimKeepLast:
+			start = m.keepLastStart(history)
+		case TrimSummarize:
+			start = m.keepLastStart(history)
+			if start > 0 {
+				text, usage, err := m.summarize(ctx, history[:start])
+				if err != nil {
+					return nil, report, err
+				}
+				report.Summarized = history[:start]
+				report.Summary = text
+				report.SummaryUsage = usage
+				summary = []InputMessage{{Role: RoleSystem, Content: "Summary of the earlier conversation:\n" + text}}
+			}
+		default:
+			return nil, report, fmt.Errorf("unknown trim strategy: %d", m.Strategy)
+		}
+	}
+
+	// Whatever the strategy, the oldest remaining turns go until the conversation fits
+	dropFrom := start
+	if summary == nil {
+		dropFrom = 0
+	}
+	for !fits() {
+		next := nextUserTurn(history, start)
+		if next >= len(history) {
+			return nil, report, fmt.Errorf("%w: the latest turn alone needs more than %d tokens", ErrorCategoryContextLength, budget)
+		}
+		start = next
+	}
+	if start > dropFrom {
+		report.Dropped = history[dropFrom:start]
+	}
+
+	result := assemble()
+	report.OutputTokens = m.count(result, config)
+
+	return result, report, nil
+}
+
+// count sizes a conversation with the configured counter
+func (m *ContextManager) count(messages []InputMessage, config ModelConfig) int {
+	if m.CountTokens != nil {
+		return m.CountTokens(messages, config)
+	}
+	return EstimateTokens(messages, config)
+}
+
+// stripImages removes images from all but the last ImageWindow user turns, leaving a
+// note in their place so the model knows something was shown
+func (m *ContextManager) stripImages(history []InputMessage, report *TrimReport) {
+	if m.ImageWindow <= 0 {
+		return
+	}
+
+	turns := 0
+	for i := len(history) - 1; i >= 0; i-- {
+		if turns >= m.ImageWindow {
+			if count := len(history[i].Images); count > 0 {
+				report.ImagesRemoved += count
+				history[i].Images = nil
+				history[i].Content = strings.TrimSpace(fmt.Sprintf("%s\n[%d image(s) omitted]", history[i].Content, count))
+			}
+		}
+		if isUserTurn(history[i]) {
+			turns++
+		}
+	}
+}
+
+// keepLastStart returns where the last KeepLast messages begin, moved back to the start
+// of a user turn so that no tool result is separated from its call
+func (m *ContextManager) keepLastStart(history []InputMessage) int {
+	keep := m.KeepLast
+	if keep <= 0 {
+		keep = defaultKeepLast
+	}
+
+	start := max(len(history)-keep, 0)
+	for start > 0 && !isUserTurn(history[start]) {
+		start--
+	}
+	return start
+}
+
+// nextUserTurn returns the index of the first user turn after i, or len(history) if there is none
+func nextUserTurn(history []InputMessage, i int) int {
+	for i++; i < len(history); i++ {
+		if isUserTurn(history[i]) {
+			return i
+		}
+	}
+	return len(history)
+}
+
+// isUserTurn reports whether a message is user input rather than model output or a tool result
+func isUserTurn(msg InputMessage) bool {
+	return msg.Role != RoleAssistant && msg.Role != RoleTool && msg.Role != RoleSystem
+}
+
+// summarize asks the Summarizer client to condense older messages
+func (m *ContextManager) summarize(ctx context.Context, older []InputMessage) (string, TokenUsage, error) {
+	if m.Summarizer == nil {
+		return "", TokenUsage{}, fmt.Errorf("TrimSummarize requires a Summarizer client")
+	}
+
+	prompt := m.SummaryPrompt
+	if prompt == "" {
+		prompt = defaultSummaryPrompt
+	}
+
+	var transcript strings.Builder
+	for _, msg := range older {
+		switch msg.Role {
+		case RoleAssistant:
+			if msg.Content != "" {
+				fmt.Fprintf(&transcript, "Assistant: %s\n", msg.Content)
+			}
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&transcript, "Assistant called %s with %s\n", call.Name, call.Arguments)
+			}
+		case RoleTool:
+			if msg.ToolResult != nil {
+				fmt.Fprintf(&transcript, "Tool %s returned: %s\n", msg.ToolResult.Name, msg.ToolResult.Content)
+			}
+		default:
+			fmt.Fprintf(&transcript, "User: %s\n", msg.Content)
+		}
+	}
+
+	response, err := m.Summarizer.TextCompletion(ctx, []InputMessage{
+		{Role: RoleUser, Content: prompt + "\n\n" + transcript.String()},
+	}, m.SummaryConfig)
+	if err != nil {
+		return "", TokenUsage{}, fmt.Errorf("error summarizing conversation: %w", err)
+	}
+
+	return strings.TrimSpace(response.Text), response.TokenUsage, nil
+}
@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// contextTestConversation is a system message and four user turns, one with a tool call
+var contextTestConversation = []InputMessage{
+	{Role: RoleSystem, Content: "sys"},
+	{Role: RoleUser, Content: "u1"},
+	{Role: RoleAssistant, Content: "a1"},
+	{Role: RoleUser, Content: "u2"},
+	{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "t1", Name: "lookup", Arguments: "{}"}}},
+	{Role: RoleTool, ToolResult: &ToolResult{ToolCallID: "t1", Name: "lookup", Content: "found"}},
+	{Role: RoleUser, Content: "u3"},
+	{Role: RoleAssistant, Content: "a3"},
+	{Role: RoleUser, Content: "u4"},
+}
+
+// countMessages sizes a conversation at one token per message
+func countMessages(messages []InputMessage, config ModelConfig) int {
+	return len(messages)
+}
+
+// contents lists the content of each message, naming tool messages by role
+func contents(messages []InputMessage) string {
+	var parts []string
+	for _, msg := range messages {
+		switch {
+		case msg.Role == RoleTool:
+			parts = append(parts, "tool")
+		case len(msg.ToolCalls) > 0:
+			parts = append(parts, "call")
+		case strings.HasPrefix(msg.Content, "Summary of"):
+			parts = append(parts, "summary")
+		default:
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestContextManagerFit(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   TrimStrategy
+		keepLast   int
+		budget     int
+		want       string
+		dropped    int
+		summarized int
+	}{
+		{"fits", TrimDropOldest, 0, 9, "sys,u1,a1,u2,call,tool,u3,a3,u4", 0, 0},
+		{"drop oldest", TrimDropOldest, 0, 5, "sys,u3,a3,u4", 5, 0},
+		{"drop oldest keeps tool pairs", TrimDropOldest, 0, 7, "sys,u2,call,tool,u3,a3,u4", 2, 0},
+		{"keep last moves back to a user turn", TrimKeepLast, 4, 8, "sys,u2,call,tool,u3,a3,u4", 2, 0},
+		{"keep last then drop", TrimKeepLast, 4, 3, "sys,u4", 7, 0},
+		{"summarize", TrimSummarize, 2, 8, "sys,summary,u3,a3,u4", 0, 5},
+		{"summarize then drop", TrimSummarize, 3, 4, "sys,summary,u4", 2, 5},
+	}
+
+	for _, tt := range tests {
+		summarizer := &fakeClient{response: Response{Text: " earlier chat ", TokenUsage: TokenUsage{TotalTokens: 7}}}
+		manager := &ContextManager{Strategy: tt.strategy, KeepLast: tt.keepLast, Summarizer: summarizer, CountTokens: countMessages}
+
+		result, report, err := manager.Fit(context.Background(), contextTestConversation, ModelConfig{}, tt.budget)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := contents(result); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+		if len(report.Dropped) != tt.dropped || len(report.Summarized) != tt.summarized {
+			t.Errorf("%s: dropped %d and summarized %d, want %d and %d", tt.name, len(report.Dropped), len(report.Summarized), tt.dropped, tt.summarized)
+		}
+		if report.InputTokens != 9 || report.OutputTokens != len(result) || report.Trimmed() != (tt.budget < 9) {
+			t.Errorf("%s: unexpected report %+v", tt.name, report)
+		}
+		if tt.summarized > 0 && (report.Summary != "earlier chat" || report.SummaryUsage.TotalTokens != 7 || summarizer.calls.Load() != 1) {
+			t.Errorf("%s: got summary %q after %d calls", tt.name, report.Summary, summarizer.calls.Load())
+		}
+	}
+}
+
+func TestContextManagerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		manager *ContextManager
+		budget  int
+		want    ErrorCategory
+	}{
+		{"zero budget", &ContextManager{CountTokens: countMessages}, 0, ""},
+		{"latest turn too large", &ContextManager{CountTokens: countMessages}, 1, ErrorCategoryContextLength},
+		{"no summarizer", &ContextManager{Strategy: TrimSummarize, CountTokens: countMessages}, 4, ""},
+		{"summarizer fails", &ContextManager{Strategy: TrimSummarize, Summarizer: failingWith(ErrorCategoryServer), CountTokens: countMessages}, 4, ErrorCategoryServer},
+		{"unknown strategy", &ContextManager{Strategy: TrimStrategy(9), CountTokens: countMessages}, 4, ""},
+	}
+
+	for _, tt := range tests {
+		_, _, err := tt.manager.Fit(context.Background(), contextTestConversation, ModelConfig{}, tt.budget)
+		if err == nil || (tt.want != "" && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: got %v, want an error %s", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestContextManagerImageWindow(t *testing.T) {
+	image := Image{Data: []byte{1}, Format: "png"}
+	messages := []InputMessage{
+		{Role: RoleUser, Content: "first", Images: []Image{image, image}},
+		{Role: RoleAssistant, Content: "a1"},
+		{Role: RoleUser, Content: "second", Images: []Image{image}},
+		{Role: RoleAssistant, Content: "a2"},
+		{Role: RoleUser, Content: "third", Images: []Image{image}},
+	}
+	manager := &ContextManager{ImageWindow: 2, CountTokens: countMessages}
+
+	result, report, err := manager.Fit(context.Background(), messages, ModelConfig{}, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.ImagesRemoved != 2 || len(result[0].Images) != 0 || result[0].Content != "first\n[2 image(s) omitted]" {
+		t.Errorf("got %d images removed, first message %+v", report.ImagesRemoved, result[0])
+	}
+	if len(result[2].Images) != 1 || len(result[4].Images) != 1 {
+		t.Error("images in the last two user turns were removed")
+	}
+	if len(messages[0].Images) != 2 || messages[0].Content != "first" {
+		t.Error("the caller's messages were modified")
+	}
+}
+
+func TestContextBudget(t *testing.T) {
+	RegisterModel("budget-test", ModelCapabilities{ContextWindow: 1000})
+
+	tests := []struct {
+		modelID   string
+		maxTokens int32
+		want      int
+	}{
+		{"budget-test", 0, 1000},
+		{"budget-test", 200, 800},
+		{"budget-test", 2000, 0},
+		{"unknown-model", 0, 0},
+	}
+
+	for _, tt := range tests {
+		if got := ContextBudget(tt.modelID, ModelConfig{MaxTokens: tt.maxTokens}); got != tt.want {
+			t.Errorf("%s with %d output tokens: got %d, want %d", tt.modelID, tt.maxTokens, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package ai
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ConversationState is the serializable part of a Conversation
+type ConversationState struct {
+	ID       string         `json:"id"`
+	Messages []InputMessage `json:"messages"`
+	Usage    TokenUsage     `json:"usage"` // Cumulative usage of every exchange
+}
+
+// copy returns a state that shares no message slice with s
+func (s ConversationState) copy() ConversationState {
+	s.Messages = append([]InputMessage(nil), s.Messages...)
+	return s
+}
+
+// Conversation is a chat with one client and model config. It appends user input and
+// model replies to its history automatically and tracks cumulative token usage.
+// A Conversation is safe for concurrent use; exchanges are applied one at a time.
+type Conversation struct {
+	// Store, if set, receives the history after every successful exchange
+	Store HistoryStore
+
+	mu     sync.Mutex
+	client Client
+	config ModelConfig
+	state  ConversationState
+}
+
+// NewConversation starts an empty conversation with a generated ID
+func NewConversation(client Client, config ModelConfig) *Conversation {
+	return &Conversation{
+		client: client,
+		config: config,
+		state:  ConversationState{ID: newConversationID()},
+	}
+}
+
+// ResumeConversation continues the conversation saved under id in store, or starts an
+// empty one under that ID if none exists. Later exchanges are saved back to store.
+func ResumeConversation(ctx context.Context, store HistoryStore, id string, client Client, config ModelConfig) (*Conversation, error) {
+	state, err := store.Load(ctx, id)
+	if errors.Is(err, ErrConversationNotFound) {
+		state = ConversationState{ID: id}
+	} else if err != nil {
+		return nil, fmt.Errorf("error loading conversation %s: %w", id, err)
+	}
+
+	return &Conversation{
+		Store:  store,
+		client: client,
+		config: config,
+		state:  state,
+	}, nil
+}
+
+// newConversationID returns a random identifier
+func newConversationID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ID returns the conversation identifier
+func (c *Conversation) ID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.ID
+}
+
+// Messages returns a copy of the history
+func (c *Conversation) Messages() []InputMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.copy().Messages
+}
+
+// Usage returns the cumulative token usage of every exchange
+func (c *Conversation) Usage() TokenUsage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.Usage
+}
+
+// State returns a copy of the serializable state
+func (c *Conversation) State() ConversationState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.copy()
+}
+
+// Append adds messages to the history without sending anything
+func (c *Conversation) Append(messages ...InputMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.Messages = append(c.state.Messages, messages...)
+}
+
+// Send sends user text with optional images and returns the model's reply
+func (c *Conversation) Send(ctx context.Context, text string, images ...Image) (Response, error) {
+	return c.SendMessages(ctx, InputMessage{Role: RoleUser, Content: text, Images: images})
+}
+
+// SendToolResults answers the tool calls of the previous reply
+func (c *Conversation) SendToolResults(ctx context.Context, results ...ToolResult) (Response, error) {
+	messages := make([]InputMessage, 0, len(results))
+	for i := range results {
+		messages = append(messages, InputMessage{Role: RoleTool, ToolResult: &results[i]})
+	}
+	return c.SendMessages(ctx, messages...)
+}
+
+// SendMessages appends messages, sends the whole history and appends the reply.
+// If the request or saving the history fails, the history is left unchanged.
+func (c *Conversation) SendMessages(ctx context.Context, messages ...InputMessage) (Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Build on a copy so that a failed request leaves no trace in the history
+	history := append(c.state.copy().Messages, messages...)
+
+	response, err := c.client.TextCompletion(ctx, history, c.config)
+	if err != nil {
+		return response, err
+	}
+
+	next := c.state
+	next.Messages = append(history, InputMessage{
+		Role:      RoleAssistant,
+		Content:   response.Text,
+		ToolCalls: response.ToolCalls,
+	})
+	next.Usage = next.Usage.Add(response.TokenUsage)
+
+	// The turn is only committed once it is saved, so memory never runs ahead of the store
+	if c.Store != nil {
+		if err := c.Store.Save(ctx, next.copy()); err != nil {
+			return response, fmt.Errorf("error saving conversation %s: %w", next.ID, err)
+		}
+	}
+	c.state = next
+
+	return response, nil
+}
+
+// MarshalJSON encodes the conversation state
+func (c *Conversation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.State())
+}
+
+// UnmarshalJSON replaces the conversation state. The client, config and store are kept,
+// so a conversation created with NewConversation can be restored from JSON.
+func (c *Conversation) UnmarshalJSON(data []byte) error {
+	var state ConversationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+
+	return nil
+}
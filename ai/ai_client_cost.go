@@ -0,0 +1,319 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// costTagsKey is the context key for the tags attached by WithCostTags
+type costTagsKey struct{}
+
+// WithCostTags returns a context whose requests are attributed to tags, such as a team
+// or feature name, by CostTracker. Tags already on ctx are kept unless overridden.
+func WithCostTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	for key, value := range CostTags(ctx) {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, costTagsKey{}, merged)
+}
+
+// CostTags returns the tags attached to ctx by WithCostTags. The map must not be modified.
+func CostTags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(costTagsKey{}).(map[string]string)
+	return tags
+}
+
+// CostRecord is the cost of one request
+type CostRecord struct {
+	Time         time.Time
+	Provider     string
+	Model        string
+	Tags         map[string]string
+	Usage        TokenUsage
+	Cost         float64 // US dollars, 0 if the model has no price
+	PriceVersion string  // Version of the price table used
+	Priced       bool    // Whether the model was found in the price table
+}
+
+// CostTotals aggregates the cost of a set of requests
+type CostTotals struct {
+	Requests int
+	Unpriced int // Requests whose model has no price, counted in Usage but not Cost
+	Usage    TokenUsage
+	Cost     float64
+}
+
+// add includes a record in the totals
+func (t *CostTotals) add(record CostRecord) {
+	t.Requests++
+	if !record.Priced {
+		t.Unpriced++
+	}
+	t.Usage = t.Usage.Add(record.Usage)
+	t.Cost += record.Cost
+}
+
+// CostFilter selects records for a query. Zero-valued fields match everything.
+type CostFilter struct {
+	Provider string
+	Model    string
+	Tags     map[string]string // Records must carry every listed tag with the same value
+	Since    time.Time         // Inclusive
+	Until    time.Time         // Exclusive
+}
+
+// matches reports whether a record passes the filter
+func (f CostFilter) matches(record CostRecord) bool {
+	if f.Provider != "" && record.Provider != f.Provider {
+		return false
+	}
+	if f.Model != "" && record.Model != f.Model {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.Time.Before(f.Until) {
+		return false
+	}
+	for key, value := range f.Tags {
+		if v, ok := record.Tags[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Grouping keys for CostTracker.GroupBy
+var (
+	// ByProvider groups records by provider
+	ByProvider = func(record CostRecord) string { return record.Provider }
+	// ByModel groups records by model
+	ByModel = func(record CostRecord) string { return record.Model }
+)
+
+// ByTag groups records by the value of a tag, with untagged records under ""
+func ByTag(name string) func(CostRecord) string {
+	return func(record CostRecord) string { return record.Tags[name] }
+}
+
+// CostTracker prices requests made through clients wrapped by WithCostTracker and keeps
+// the records in memory for queries. Long-running processes should export records with
+// OnRecord and call Reset periodically. A CostTracker is safe for concurrent use and may
+// be shared by many clients.
+type CostTracker struct {
+	// OnRecord, if set, is called with every new record, for example to feed a billing system
+	OnRecord func(CostRecord)
+
+	mu      sync.RWMutex
+	prices  PriceTable
+	records []CostRecord
+}
+
+// NewCostTracker creates a tracker that prices requests with prices
+func NewCostTracker(prices PriceTable) *CostTracker {
+	return &CostTracker{prices: prices}
+}
+
+// Prices returns the price table in use
+func (t *CostTracker) Prices() PriceTable {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.prices
+}
+
+// SetPrices replaces the price table for future requests. Existing records keep their costs.
+func (t *CostTracker) SetPrices(prices PriceTable) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices = prices
+}
+
+// Record prices usage and stores the result. Wrapped clients call it for every request;
+// call it directly for usage from elsewhere, such as embeddings.
+func (t *CostTracker) Record(ctx context.Context, provider, model string, usage TokenUsage) CostRecord {
+	t.mu.Lock()
+	price, priced := t.prices.Lookup(model)
+	record := CostRecord{
+		Time:         time.Now(),
+		Provider:     provider,
+		Model:        model,
+		Tags:         CostTags(ctx),
+		Usage:        usage,
+		PriceVersion: t.prices.Version,
+		Priced:       priced,
+	}
+	if priced {
+		record.Cost = price.Cost(usage)
+	}
+	t.records = append(t.records, record)
+	onRecord := t.OnRecord
+	t.mu.Unlock()
+
+	if onRecord != nil {
+		onRecord(record)
+	}
+	return record
+}
+
+// Records returns the records that match filter, oldest first
+func (t *CostTracker) Records(filter CostFilter) []CostRecord {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var records []CostRecord
+	for _, record := range t.records {
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Totals sums the records that match filter
+func (t *CostTracker) Totals(filter CostFilter) CostTotals {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var totals CostTotals
+	for _, record := range t.records {
+		if filter.matches(record) {
+			totals.add(record)
+		}
+	}
+	return totals
+}
+
+// GroupBy sums the records that match filter under the key each one maps to,
+// for example ByProvider, ByModel or ByTag("team")
+func (t *CostTracker) GroupBy(filter CostFilter, key func(CostRecord) string) map[string]CostTotals {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	groups := map[string]CostTotals{}
+	for _, record := range t.records {
+		if !filter.matches(record) {
+			continue
+		}
+		group := key(record)
+		totals := groups[group]
+		totals.add(record)
+		groups[group] = totals
+	}
+	return groups
+}
+
+// Reset removes every record and returns them, oldest first
+func (t *CostTracker) Reset() []CostRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	records := t.records
+	t.records = nil
+	return records
+}
+
+// costClient wraps a Client and records the cost of every request
+type costClient struct {
+	client  Client
+	tracker *CostTracker
+}
+
+// WithCostTracker wraps client so that the cost of every request is recorded in tracker
+// and reported on Response.Cost, or on the usage event of a stream
+func WithCostTracker(client Client, tracker *CostTracker) Client {
+	return &costClient{client: client, tracker: tracker}
+}
+
+// Initialize initializes the wrapped client
+func (c *costClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	return c.client.Initialize(ctx, opts)
+}
+
+// TextCompletion sends a text request and records its cost
+func (c *costClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	response, err := c.client.TextCompletion(ctx, messages, config)
+	return c.record(ctx, messages, response), err
+}
+
+// ImageRecognition sends images with optional text and records the cost
+func (c *costClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	response, err := c.client.ImageRecognition(ctx, messages, config)
+	return c.record(ctx, messages, response), err
+}
+
+// StreamCompletion streams a response and records its cost when the usage event arrives
+func (c *costClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	upstream, err := c.client.StreamCompletion(ctx, messages, config)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for event := range upstream {
+			if event.Type == StreamEventUsage {
+				usage := withImageTokens(event.TokenUsage, event.Provider, messages)
+				record := c.tracker.Record(ctx, event.Provider, event.Model, usage)
+				event.TokenUsage = usage
+				event.Cost = record.Cost
+			}
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// CountTokens counts prompt tokens with the wrapped client
+func (c *costClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return c.client.CountTokens(ctx, messages, config)
+}
+
+// Close closes the wrapped client
+func (c *costClient) Close() error {
+	return c.client.Close()
+}
+
+// record prices a response. Failed requests that report no usage are not recorded.
+func (c *costClient) record(ctx context.Context, messages []InputMessage, response Response) Response {
+	if response.TokenUsage.TotalTokens == 0 && response.TokenUsage.InputTokens == 0 {
+		return response
+	}
+
+	response.TokenUsage = withImageTokens(response.TokenUsage, response.Provider, messages)
+	response.Cost = c.tracker.Record(ctx, response.Provider, response.Model, response.TokenUsage).Cost
+	return response
+}
+
+// withImageTokens estimates the share of the input spent on images when the provider
+// does not report it, so that image prices can apply
+func withImageTokens(usage TokenUsage, provider string, messages []InputMessage) TokenUsage {
+	if usage.ImageTokens > 0 {
+		return usage
+	}
+
+	imageTokens := estimateImageTokens
+	switch provider {
+	case ProviderOpenAI:
+		imageTokens = openAIImageTokens
+	case ProviderGemini:
+		imageTokens = geminiImageTokens
+	}
+
+	for _, msg := range messages {
+		for _, img := range msg.Images {
+			usage.ImageTokens += imageTokens(img)
+		}
+	}
+	usage.ImageTokens = max(min(usage.ImageTokens, usage.InputTokens-usage.CachedTokens), 0)
+	return usage
+}
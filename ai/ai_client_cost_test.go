@@ -0,0 +1,137 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// costTestPrices charges $1 per million input tokens and $2 per million output tokens
+var costTestPrices = PriceTable{Version: "test", Prices: map[string]ModelPrice{"priced-model": {Input: 1, Output: 2}}}
+
+func TestCostTrackerQueries(t *testing.T) {
+	tracker := NewCostTracker(costTestPrices)
+	var exported []CostRecord
+	tracker.OnRecord = func(record CostRecord) { exported = append(exported, record) }
+
+	teamA := WithCostTags(context.Background(), map[string]string{"team": "a"})
+	teamB := WithCostTags(teamA, map[string]string{"team": "b", "feature": "chat"})
+	usage := TokenUsage{InputTokens: 1_000_000, OutputTokens: 1_000_000, TotalTokens: 2_000_000}
+
+	start := time.Now()
+	tracker.Record(teamA, ProviderOpenAI, "priced-model", usage)
+	tracker.Record(teamB, ProviderOpenAI, "priced-model", usage)
+	tracker.Record(teamB, ProviderGemini, "unpriced-model", usage)
+
+	tests := []struct {
+		name     string
+		filter   CostFilter
+		requests int
+		unpriced int
+		cost     float64
+	}{
+		{"all", CostFilter{}, 3, 1, 6},
+		{"provider", CostFilter{Provider: ProviderGemini}, 1, 1, 0},
+		{"model", CostFilter{Model: "priced-model"}, 2, 0, 6},
+		{"tag", CostFilter{Tags: map[string]string{"team": "b"}}, 2, 1, 3},
+		{"two tags", CostFilter{Tags: map[string]string{"team": "b", "feature": "chat"}}, 2, 1, 3},
+		{"missing tag", CostFilter{Tags: map[string]string{"feature": "search"}}, 0, 0, 0},
+		{"since", CostFilter{Since: start}, 3, 1, 6},
+		{"until", CostFilter{Until: start}, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		totals := tracker.Totals(tt.filter)
+		if totals.Requests != tt.requests || totals.Unpriced != tt.unpriced || math.Abs(totals.Cost-tt.cost) > 1e-9 {
+			t.Errorf("%s: got %+v", tt.name, totals)
+		}
+		if records := tracker.Records(tt.filter); len(records) != tt.requests {
+			t.Errorf("%s: got %d records, want %d", tt.name, len(records), tt.requests)
+		}
+	}
+
+	byTeam := tracker.GroupBy(CostFilter{}, ByTag("team"))
+	if byTeam["a"].Requests != 1 || byTeam["b"].Requests != 2 || byTeam["b"].Usage.TotalTokens != 4_000_000 {
+		t.Errorf("got groups %+v", byTeam)
+	}
+	if byProvider := tracker.GroupBy(CostFilter{}, ByProvider); len(byProvider) != 2 {
+		t.Errorf("got groups %+v", byProvider)
+	}
+
+	if len(exported) != 3 || exported[0].PriceVersion != "test" || !exported[0].Priced || exported[2].Priced {
+		t.Errorf("got exported records %+v", exported)
+	}
+	if removed := tracker.Reset(); len(removed) != 3 || tracker.Totals(CostFilter{}).Requests != 0 {
+		t.Errorf("reset returned %d records", len(removed))
+	}
+}
+
+func TestCostTrackerSetPrices(t *testing.T) {
+	tracker := NewCostTracker(costTestPrices)
+	usage := TokenUsage{InputTokens: 1_000_000, TotalTokens: 1_000_000}
+
+	before := tracker.Record(context.Background(), ProviderOpenAI, "priced-model", usage)
+	tracker.SetPrices(PriceTable{Version: "v2", Prices: map[string]ModelPrice{"priced-model": {Input: 5}}})
+	after := tracker.Record(context.Background(), ProviderOpenAI, "priced-model", usage)
+
+	if before.Cost != 1 || after.Cost != 5 || after.PriceVersion != "v2" || tracker.Prices().Version != "v2" {
+		t.Errorf("got $%f then $%f with version %q", before.Cost, after.Cost, after.PriceVersion)
+	}
+	if records := tracker.Records(CostFilter{}); records[0].Cost != 1 {
+		t.Error("changing prices repriced an existing record")
+	}
+}
+
+func TestWithCostTracker(t *testing.T) {
+	usage := TokenUsage{InputTokens: 500_000, OutputTokens: 250_000, TotalTokens: 750_000}
+	client := &fakeClient{response: Response{Text: "ok", Provider: ProviderOpenAI, Model: "priced-model", TokenUsage: usage}}
+	tracker := NewCostTracker(costTestPrices)
+	wrapped := WithCostTracker(client, tracker)
+
+	response, err := wrapped.TextCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil || response.Cost != 1 {
+		t.Errorf("got $%f, %v, want $1", response.Cost, err)
+	}
+
+	events, err := wrapped.StreamCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	streamed, err := CollectStream(events)
+	if err != nil || streamed.Cost != 1 {
+		t.Errorf("got $%f, %v from the stream, want $1", streamed.Cost, err)
+	}
+
+	// Failures without usage are not recorded
+	client.err = &Error{Category: ErrorCategoryServer}
+	wrapped.TextCompletion(context.Background(), nil, ModelConfig{})
+
+	if totals := tracker.Totals(CostFilter{}); totals.Requests != 2 || totals.Cost != 2 {
+		t.Errorf("got totals %+v", totals)
+	}
+}
+
+func TestWithImageTokens(t *testing.T) {
+	image := Image{URL: "https://example.com/a.png"}
+	messages := []InputMessage{{Role: RoleUser, Content: "what is this?", Images: []Image{image, image}}}
+
+	tests := []struct {
+		name     string
+		usage    TokenUsage
+		provider string
+		want     int
+	}{
+		{"gemini charges per image", TokenUsage{InputTokens: 1000}, ProviderGemini, 2 * geminiImageTokens(image)},
+		{"reported image tokens are kept", TokenUsage{InputTokens: 1000, ImageTokens: 7}, ProviderGemini, 7},
+		{"capped by input", TokenUsage{InputTokens: 100}, ProviderGemini, 100},
+		{"capped by uncached input", TokenUsage{InputTokens: 1000, CachedTokens: 900}, ProviderGemini, 100},
+		{"openai tiles", TokenUsage{InputTokens: 10_000}, ProviderOpenAI, 2 * openAIImageTokens(image)},
+	}
+
+	for _, tt := range tests {
+		if got := withImageTokens(tt.usage, tt.provider, messages).ImageTokens; got != tt.want {
+			t.Errorf("%s: got %d image tokens, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"context"
+	"math"
+)
+
+// Input types that let embedding models optimize vectors for their use
+const (
+	EmbedInputDocument = "document" // Text stored in an index and searched later
+	EmbedInputQuery    = "query"    // Search query matched against stored documents
+)
+
+// EmbedOptions controls an embedding request
+type EmbedOptions struct {
+	Model      string // Embedding model, a provider default is used if empty
+	Dimensions int    // Truncate vectors to this many dimensions, 0 keeps the model's size
+	Normalize  bool   // Scale vectors to unit length, after any truncation
+	InputType  string // Optional EmbedInputDocument or EmbedInputQuery hint
+}
+
+// Embedder turns text into vectors
+type Embedder interface {
+	// Embed returns one vector per input, in input order. Inputs are split into as many
+	// requests as the provider's batch size requires.
+	Embed(ctx context.Context, inputs []string, opts EmbedOptions) ([][]float32, TokenUsage, error)
+}
+
+// embedInBatches sends inputs in batches of at most size and concatenates the results
+func embedInBatches(ctx context.Context, inputs []string, size int, embed func(ctx context.Context, batch []string) ([][]float32, TokenUsage, error)) ([][]float32, TokenUsage, error) {
+	vectors := make([][]float32, 0, len(inputs))
+	var usage TokenUsage
+
+	for start := 0; start < len(inputs); start += size {
+		end := min(start+size, len(inputs))
+
+		batch, batchUsage, err := embed(ctx, inputs[start:end])
+		if err != nil {
+			return nil, usage, err
+		}
+		vectors = append(vectors, batch...)
+		usage = usage.Add(batchUsage)
+	}
+
+	return vectors, usage, nil
+}
+
+// shapeEmbeddings applies the truncation and normalization requested in opts
+func shapeEmbeddings(vectors [][]float32, opts EmbedOptions) [][]float32 {
+	for i, vector := range vectors {
+		if opts.Dimensions > 0 && len(vector) > opts.Dimensions {
+			vector = vector[:opts.Dimensions]
+		}
+		if opts.Normalize {
+			vector = normalizeVector(vector)
+		}
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+// normalizeVector scales a vector to unit length. Zero vectors are returned as-is.
+func normalizeVector(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+
+	norm := float32(math.Sqrt(sum))
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
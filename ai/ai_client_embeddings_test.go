@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestEmbedInBatches(t *testing.T) {
+	tests := []struct {
+		inputs  int
+		size    int
+		batches []int
+	}{
+		{0, 3, nil},
+		{1, 3, []int{1}},
+		{3, 3, []int{3}},
+		{7, 3, []int{3, 3, 1}},
+		{4, 1, []int{1, 1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		inputs := make([]string, tt.inputs)
+		for i := range inputs {
+			inputs[i] = strconv.Itoa(i)
+		}
+
+		var batches []int
+		vectors, usage, err := embedInBatches(context.Background(), inputs, tt.size, func(ctx context.Context, batch []string) ([][]float32, TokenUsage, error) {
+			batches = append(batches, len(batch))
+			vectors := make([][]float32, len(batch))
+			for i, text := range batch {
+				n, _ := strconv.Atoi(text)
+				vectors[i] = []float32{float32(n)}
+			}
+			return vectors, newTokenUsage(len(batch), 0), nil
+		})
+		if err != nil {
+			t.Errorf("%d inputs: unexpected error: %v", tt.inputs, err)
+			continue
+		}
+		if !reflect.DeepEqual(batches, tt.batches) {
+			t.Errorf("%d inputs in batches of %d: got batches %v, want %v", tt.inputs, tt.size, batches, tt.batches)
+		}
+		for i, vector := range vectors {
+			if vector[0] != float32(i) {
+				t.Errorf("%d inputs: vector %d belongs to input %v", tt.inputs, i, vector[0])
+			}
+		}
+		if len(vectors) != tt.inputs || usage.InputTokens != tt.inputs {
+			t.Errorf("%d inputs: got %d vectors and %d tokens", tt.inputs, len(vectors), usage.InputTokens)
+		}
+	}
+}
+
+func TestEmbedInBatchesStopsOnError(t *testing.T) {
+	calls := 0
+	failure := errors.New("boom")
+	_, usage, err := embedInBatches(context.Background(), []string{"a", "b", "c"}, 1, func(ctx context.Context, batch []string) ([][]float32, TokenUsage, error) {
+		calls++
+		if calls == 2 {
+			return nil, TokenUsage{}, failure
+		}
+		return [][]float32{{1}}, newTokenUsage(5, 0), nil
+	})
+	if !errors.Is(err, failure) || calls != 2 || usage.InputTokens != 5 {
+		t.Errorf("got %v after %d calls with %d tokens, want the error after 2 calls with 5", err, calls, usage.InputTokens)
+	}
+}
+
+func TestShapeEmbeddings(t *testing.T) {
+	tests := []struct {
+		name   string
+		vector []float32
+		opts   EmbedOptions
+		want   []float32
+	}{
+		{"unchanged", []float32{3, 4, 12}, EmbedOptions{}, []float32{3, 4, 12}},
+		{"truncated", []float32{3, 4, 12}, EmbedOptions{Dimensions: 2}, []float32{3, 4}},
+		{"shorter than dimensions", []float32{3, 4}, EmbedOptions{Dimensions: 8}, []float32{3, 4}},
+		{"normalized", []float32{3, 4, 12}, EmbedOptions{Normalize: true}, []float32{3.0 / 13, 4.0 / 13, 12.0 / 13}},
+		{"truncated then normalized", []float32{3, 4, 12}, EmbedOptions{Dimensions: 2, Normalize: true}, []float32{0.6, 0.8}},
+		{"zero vector", []float32{0, 0}, EmbedOptions{Normalize: true}, []float32{0, 0}},
+	}
+
+	for _, tt := range tests {
+		got := shapeEmbeddings([][]float32{tt.vector}, tt.opts)[0]
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(float64(got[i]-tt.want[i])) > 1e-6 {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorCategory classifies a failure independently of the provider that produced it.
+// Categories are themselves errors, so errors.Is(err, ErrorCategoryRateLimited) works.
+type ErrorCategory string
+
+const (
+	ErrorCategoryUnknown         ErrorCategory = "unknown"
+	ErrorCategoryAuth            ErrorCategory = "auth"
+	ErrorCategoryRateLimited     ErrorCategory = "rate_limited"
+	ErrorCategoryQuota           ErrorCategory = "quota"
+	ErrorCategoryInvalidRequest  ErrorCategory = "invalid_request"
+	ErrorCategoryContentFiltered ErrorCategory = "content_filtered"
+	ErrorCategoryContextLength   ErrorCategory = "context_length_exceeded"
+	ErrorCategoryServer          ErrorCategory = "server_error"
+	ErrorCategoryTimeout         ErrorCategory = "timeout"
+	ErrorCategoryCanceled        ErrorCategory = "canceled"
+	ErrorCategoryCircuitOpen     ErrorCategory = "circuit_open"
+)
+
+func (c ErrorCategory) Error() string {
+	return string(c)
+}
+
+// Error is a classified failure returned by a provider
+type Error struct {
+	Category   ErrorCategory
+	Provider   string
+	StatusCode int           // HTTP status, 0 if the request never got a response
+	RequestID  string        // Provider request ID, useful for support tickets
+	RetryAfter time.Duration // Provider hint for when to retry, 0 if none was given
+	Message    string
+	Err        error // Underlying SDK error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s", e.Provider, e.Category)
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (status %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		fmt.Fprintf(&b, ": %s", e.Message)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request id: %s]", e.RequestID)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error's category, so callers can branch with errors.Is
+func (e *Error) Is(target error) bool {
+	category, ok := target.(ErrorCategory)
+	return ok && category == e.Category
+}
+
+// Retryable reports whether sending the same request again may succeed
+func (e *Error) Retryable() bool {
+	switch e.Category {
+	case ErrorCategoryRateLimited, ErrorCategoryServer, ErrorCategoryTimeout:
+		return true
+	}
+	return false
+}
+
+// CategoryOf returns the category of err, classifying bare context errors as well
+func CategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+
+	var category ErrorCategory
+	if errors.As(err, &category) {
+		return category
+	}
+
+	var aiErr *Error
+	if errors.As(err, &aiErr) {
+		return aiErr.Category
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorCategoryCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCategoryTimeout
+	}
+	return ErrorCategoryUnknown
+}
+
+// IsRetryable reports whether err is a failure that may succeed on retry
+func IsRetryable(err error) bool {
+	switch CategoryOf(err) {
+	case ErrorCategoryRateLimited, ErrorCategoryServer, ErrorCategoryTimeout:
+		return true
+	}
+	return false
+}
+
+// newError starts classifying a provider failure from the generic parts of the
+// error chain. Provider translations then fill in status and native error codes.
+func newError(provider string, err error) *Error {
+	e := &Error{
+		Category: ErrorCategoryUnknown,
+		Provider: provider,
+		Message:  err.Error(),
+		Err:      err,
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		e.Category = ErrorCategoryCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Category = ErrorCategoryTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		e.Category = ErrorCategoryTimeout
+	}
+
+	return e
+}
+
+// setStatus records the HTTP status and derives the category from it
+func (e *Error) setStatus(status int, header http.Header) {
+	e.StatusCode = status
+	e.RetryAfter = parseRetryAfter(header)
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		e.Category = ErrorCategoryAuth
+	case status == http.StatusTooManyRequests:
+		e.Category = ErrorCategoryRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		e.Category = ErrorCategoryTimeout
+	case status >= 500:
+		e.Category = ErrorCategoryServer
+	case status >= 400:
+		e.Category = ErrorCategoryInvalidRequest
+	}
+}
+
+// Phrases providers use for failures that share a status code with other failures
+var (
+	contextLengthPhrases = []string{
+		"context_length_exceeded", "context length", "context window", "maximum context",
+		"too many tokens", "too many input tokens", "input is too long", "prompt is too long",
+		"exceeds the maximum number of tokens",
+	}
+	quotaPhrases = []string{
+		"insufficient_quota", "exceeded your current quota", "billing",
+	}
+	contentFilterPhrases = []string{
+		"content_filter", "content filter", "content_policy_violation", "content management policy",
+	}
+)
+
+// refine narrows a generic category using well-known phrases in the error message
+func (e *Error) refine() {
+	switch e.Category {
+	case ErrorCategoryInvalidRequest, ErrorCategoryRateLimited, ErrorCategoryUnknown:
+	default:
+		return
+	}
+
+	message := strings.ToLower(e.Message)
+	containsAny := func(phrases []string) bool {
+		for _, phrase := range phrases {
+			if strings.Contains(message, phrase) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case containsAny(quotaPhrases):
+		e.Category = ErrorCategoryQuota
+	case e.Category == ErrorCategoryRateLimited:
+	case containsAny(contextLengthPhrases):
+		e.Category = ErrorCategoryContextLength
+	case containsAny(contentFilterPhrases):
+		e.Category = ErrorCategoryContentFiltered
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
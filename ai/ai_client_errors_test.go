@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   ErrorCategory
+	}{
+		{http.StatusBadRequest, ErrorCategoryInvalidRequest},
+		{http.StatusUnauthorized, ErrorCategoryAuth},
+		{http.StatusForbidden, ErrorCategoryAuth},
+		{http.StatusNotFound, ErrorCategoryInvalidRequest},
+		{http.StatusRequestTimeout, ErrorCategoryTimeout},
+		{http.StatusTooManyRequests, ErrorCategoryRateLimited},
+		{http.StatusInternalServerError, ErrorCategoryServer},
+		{http.StatusBadGateway, ErrorCategoryServer},
+		{http.StatusServiceUnavailable, ErrorCategoryServer},
+		{http.StatusGatewayTimeout, ErrorCategoryTimeout},
+	}
+
+	for _, tt := range tests {
+		e := newError("test", errors.New("failed"))
+		e.setStatus(tt.status, nil)
+		if e.Category != tt.want {
+			t.Errorf("status %d: got %s, want %s", tt.status, e.Category, tt.want)
+		}
+		if e.StatusCode != tt.status {
+			t.Errorf("status %d: StatusCode is %d", tt.status, e.StatusCode)
+		}
+	}
+}
+
+func TestRefine(t *testing.T) {
+	tests := []struct {
+		category ErrorCategory
+		message  string
+		want     ErrorCategory
+	}{
+		{ErrorCategoryInvalidRequest, "This model's maximum context length is 8192 tokens", ErrorCategoryContextLength},
+		{ErrorCategoryInvalidRequest, "prompt is too long: 210000 tokens > 200000 maximum", ErrorCategoryContextLength},
+		{ErrorCategoryInvalidRequest, "The response was filtered due to the content management policy", ErrorCategoryContentFiltered},
+		{ErrorCategoryRateLimited, "You exceeded your current quota, please check your plan", ErrorCategoryQuota},
+		{ErrorCategoryRateLimited, "Rate limit reached, too many tokens per minute", ErrorCategoryRateLimited},
+		{ErrorCategoryInvalidRequest, "unknown parameter", ErrorCategoryInvalidRequest},
+		{ErrorCategoryServer, "context length", ErrorCategoryServer},
+		{ErrorCategoryAuth, "billing account disabled", ErrorCategoryAuth},
+	}
+
+	for _, tt := range tests {
+		e := &Error{Category: tt.category, Message: tt.message}
+		e.refine()
+		if e.Category != tt.want {
+			t.Errorf("%s %q: got %s, want %s", tt.category, tt.message, e.Category, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	header := func(value string) http.Header {
+		h := http.Header{}
+		h.Set("Retry-After", value)
+		return h
+	}
+
+	if got := parseRetryAfter(nil); got != 0 {
+		t.Errorf("no header: got %s", got)
+	}
+	if got := parseRetryAfter(header("7")); got != 7*time.Second {
+		t.Errorf("seconds: got %s, want 7s", got)
+	}
+	if got := parseRetryAfter(header("0")); got != 0 {
+		t.Errorf("zero seconds: got %s", got)
+	}
+	if got := parseRetryAfter(header("soon")); got != 0 {
+		t.Errorf("invalid value: got %s", got)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(header(date)); got <= 50*time.Second || got > time.Minute {
+		t.Errorf("date: got %s, want about 1m", got)
+	}
+	past := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(header(past)); got != 0 {
+		t.Errorf("past date: got %s", got)
+	}
+}
+
+func TestCategoryOf(t *testing.T) {
+	aiErr := &Error{Category: ErrorCategoryRateLimited, Provider: "test"}
+	tests := []struct {
+		err  error
+		want ErrorCategory
+	}{
+		{nil, ""},
+		{aiErr, ErrorCategoryRateLimited},
+		{fmt.Errorf("wrapped: %w", aiErr), ErrorCategoryRateLimited},
+		{fmt.Errorf("%w: no backend", ErrorCategoryContextLength), ErrorCategoryContextLength},
+		{context.Canceled, ErrorCategoryCanceled},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ErrorCategoryTimeout},
+		{errors.New("boom"), ErrorCategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := CategoryOf(tt.err); got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.err, got, tt.want)
+		}
+	}
+
+	if !errors.Is(aiErr, ErrorCategoryRateLimited) || errors.Is(aiErr, ErrorCategoryServer) {
+		t.Error("errors.Is does not match the category")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	retryable := []ErrorCategory{ErrorCategoryRateLimited, ErrorCategoryServer, ErrorCategoryTimeout}
+	final := []ErrorCategory{
+		ErrorCategoryAuth, ErrorCategoryQuota, ErrorCategoryInvalidRequest, ErrorCategoryContentFiltered,
+		ErrorCategoryContextLength, ErrorCategoryCanceled, ErrorCategoryUnknown,
+	}
+
+	for _, category := range retryable {
+		err := &Error{Category: category}
+		if !IsRetryable(err) || !err.Retryable() {
+			t.Errorf("%s should be retryable", category)
+		}
+	}
+	for _, category := range final {
+		err := &Error{Category: category}
+		if IsRetryable(err) || err.Retryable() {
+			t.Errorf("%s should not be retryable", category)
+		}
+	}
+}
+
+func TestNewErrorContext(t *testing.T) {
+	if got := newError("test", fmt.Errorf("post: %w", context.DeadlineExceeded)).Category; got != ErrorCategoryTimeout {
+		t.Errorf("deadline: got %s", got)
+	}
+	if got := newError("test", fmt.Errorf("post: %w", context.Canceled)).Category; got != ErrorCategoryCanceled {
+		t.Errorf("canceled: got %s", got)
+	}
+}
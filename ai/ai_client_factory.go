@@ -3,6 +3,8 @@ package ai
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 )
 
 const (
@@ -11,18 +13,78 @@ const (
 	ProviderBedrock = "bedrock"
 )
 
+// UnknownProviderError is returned when no factory is registered for a provider name
+type UnknownProviderError struct {
+	Provider string
+}
+
+func (e *UnknownProviderError) Error() string {
+	return fmt.Sprintf("unsupported provider: %s", e.Provider)
+}
+
+// DuplicateProviderError is returned when a provider name is registered twice
+type DuplicateProviderError struct {
+	Provider string
+}
+
+func (e *DuplicateProviderError) Error() string {
+	return fmt.Sprintf("provider already registered: %s", e.Provider)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func() Client{
+		ProviderOpenAI:  func() Client { return NewOpenAIClient() },
+		ProviderGemini:  func() Client { return NewGeminiClient() },
+		ProviderBedrock: func() Client { return NewBedrockClient() },
+	}
+)
+
+// Register makes a provider available to NewClient and InitializeClient under name
+func Register(name string, factory func() Client) error {
+	if name == "" {
+		return fmt.Errorf("provider name must not be empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("nil factory for provider: %s", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		return &DuplicateProviderError{Provider: name}
+	}
+	registry[name] = factory
+
+	return nil
+}
+
+// Providers returns the names of all registered providers in sorted order
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 // NewClient creates a new AI client for the specified provider
 func NewClient(provider string) (Client, error) {
-	switch provider {
-	case ProviderOpenAI:
-		return NewOpenAIClient(), nil
-	case ProviderGemini:
-		return NewGeminiClient(), nil
-	case ProviderBedrock:
-		return NewBedrockClient(), nil
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
+	registryMu.RLock()
+	factory, ok := registry[provider]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, &UnknownProviderError{Provider: provider}
 	}
+
+	return factory(), nil
 }
 
 // InitializeClient is a helper to create and initialize a client in one step
@@ -38,3 +100,16 @@ func InitializeClient(ctx context.Context, provider string, opts ClientOptions)
 
 	return client, nil
 }
+
+// clientProvider returns the provider of one of the built-in clients, or "" for any other client
+func clientProvider(client Client) string {
+	switch client.(type) {
+	case *OpenAIClient:
+		return ProviderOpenAI
+	case *GeminiClient:
+		return ProviderGemini
+	case *BedrockClient:
+		return ProviderBedrock
+	}
+	return ""
+}
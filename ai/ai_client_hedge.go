@@ -0,0 +1,311 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HedgePolicy controls when Hedge sends the second request
+type HedgePolicy struct {
+	Percentile   float64       // Primary latency percentile after which the hedge is sent, defaults to 0.95
+	InitialDelay time.Duration // Delay used until MinSamples latencies are known, defaults to 2s
+	MinDelay     time.Duration // Lower bound for the delay, 0 for none
+	MaxDelay     time.Duration // Upper bound for the delay, 0 for none
+	MinSamples   int           // Latencies needed before the percentile is used, defaults to 20
+	Samples      int           // Most recent latencies the percentile is taken over, defaults to 200
+}
+
+// hedgeLoserWait is how long a decided race waits for the canceled loser to report its usage
+const hedgeLoserWait = 100 * time.Millisecond
+
+// withDefaults fills unset fields
+func (p HedgePolicy) withDefaults() HedgePolicy {
+	if p.Percentile <= 0 || p.Percentile > 1 {
+		p.Percentile = 0.95
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = 2 * time.Second
+	}
+	if p.MinSamples <= 0 {
+		p.MinSamples = 20
+	}
+	if p.Samples < p.MinSamples {
+		p.Samples = max(200, p.MinSamples)
+	}
+	return p
+}
+
+// Hedge is a Client that sends each request to a primary client and, if no response
+// arrives within a percentile of the primary's recent latencies, sends the same request
+// to a secondary client. The first success wins and the other request is canceled.
+// Because only slow requests are hedged, a 0.95 percentile adds about 5% more requests.
+type Hedge struct {
+	primary   Client
+	secondary Client
+	policy    HedgePolicy
+
+	mu        sync.Mutex
+	latencies []time.Duration // Ring of recent primary latencies
+	next      int
+}
+
+// NewHedge creates a hedge over two initialized clients
+func NewHedge(primary, secondary Client, policy HedgePolicy) *Hedge {
+	return &Hedge{primary: primary, secondary: secondary, policy: policy.withDefaults()}
+}
+
+// Delay returns how long the hedge currently waits for the primary before sending the second request
+func (h *Hedge) Delay() time.Duration {
+	h.mu.Lock()
+	var delay time.Duration
+	if len(h.latencies) < h.policy.MinSamples {
+		delay = h.policy.InitialDelay
+	} else {
+		delay = percentile(h.latencies, h.policy.Percentile)
+	}
+	h.mu.Unlock()
+
+	if h.policy.MinDelay > 0 {
+		delay = max(delay, h.policy.MinDelay)
+	}
+	if h.policy.MaxDelay > 0 {
+		delay = min(delay, h.policy.MaxDelay)
+	}
+	return delay
+}
+
+// percentile returns the sample at percentile p, between 0 and 1, of a non-empty set of samples
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(index, 0)]
+}
+
+// observe records a latency of the primary client
+func (h *Hedge) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.latencies) < h.policy.Samples {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % len(h.latencies)
+}
+
+// Initialize is a no-op, since the hedge is built from already initialized clients
+func (h *Hedge) Initialize(ctx context.Context, opts ClientOptions) error {
+	return nil
+}
+
+// TextCompletion sends a text request, hedging it if the primary is slow
+func (h *Hedge) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return h.race(ctx, messages, config, func(ctx context.Context, client Client) (Response, error) {
+		return client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text, hedging the request if the primary is slow
+func (h *Hedge) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return h.race(ctx, messages, config, func(ctx context.Context, client Client) (Response, error) {
+		return client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// hedgeResult is the outcome of one side of a race
+type hedgeResult struct {
+	response  Response
+	err       error
+	secondary bool
+}
+
+// race runs call against the primary and, after the hedge delay or as soon as the primary
+// fails with a retryable error, the secondary. The first success is returned; if both fail,
+// the last error is returned.
+func (h *Hedge) race(ctx context.Context, messages []InputMessage, config ModelConfig, call func(context.Context, Client) (Response, error)) (Response, error) {
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+	secondaryCtx, cancelSecondary := context.WithCancel(ctx)
+	defer cancelSecondary()
+
+	// Buffered so that the loser can finish after the race is decided
+	results := make(chan hedgeResult, 2)
+	start := time.Now()
+	go func() {
+		response, err := call(primaryCtx, h.primary)
+		results <- hedgeResult{response: response, err: err}
+	}()
+
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+
+	pending, hedged, primaryDone := 1, false, false
+	hedgeAt := timer.C
+	hedge := func() {
+		hedged = true
+		hedgeAt = nil
+		pending++
+		go func() {
+			response, err := call(secondaryCtx, h.secondary)
+			results <- hedgeResult{response: response, err: err, secondary: true}
+		}()
+	}
+
+	var lost TokenUsage
+	for {
+		select {
+		case <-hedgeAt:
+			hedge()
+		case result := <-results:
+			pending--
+			if !result.secondary {
+				primaryDone = true
+				if result.err == nil {
+					h.observe(time.Since(start))
+				}
+			} else if result.err == nil && !primaryDone {
+				// The primary took at least this long, leaving it out would skew the delay low
+				h.observe(time.Since(start))
+			}
+			if result.err != nil && !hedged && IsRetryable(result.err) && ctx.Err() == nil {
+				// No point waiting for the delay once the primary has failed
+				lost = lost.Add(result.response.TokenUsage)
+				hedge()
+				continue
+			}
+			if result.err != nil && pending > 0 {
+				// The other request may still succeed
+				lost = lost.Add(result.response.TokenUsage)
+				continue
+			}
+
+			if hedged {
+				if pending > 0 {
+					cancelPrimary()
+					cancelSecondary()
+					lost = lost.Add(loserUsage(results, messages, config))
+				}
+				result.response.Hedged = true
+				result.response.HedgeUsage = lost
+			}
+			return result.response, result.err
+		}
+	}
+}
+
+// loserUsage waits briefly for the canceled loser of a race to report its usage. A loser that
+// reports none in time is estimated at its prompt, which was sent before it was canceled.
+func loserUsage(results <-chan hedgeResult, messages []InputMessage, config ModelConfig) TokenUsage {
+	timer := time.NewTimer(hedgeLoserWait)
+	defer timer.Stop()
+
+	select {
+	case loser := <-results:
+		if loser.response.TokenUsage != (TokenUsage{}) {
+			return loser.response.TokenUsage
+		}
+	case <-timer.C:
+	}
+	input := EstimateTokens(messages, config)
+	return TokenUsage{InputTokens: input, TotalTokens: input}
+}
+
+// hedgeStream is one side of a streaming race
+type hedgeStream struct {
+	events    <-chan StreamEvent
+	err       error
+	secondary bool
+}
+
+// StreamCompletion hedges on the time to the first event, or as soon as the primary fails
+// to start with a retryable error. The stream that starts first is returned and the other
+// is canceled. Hedge usage is not reported for streams.
+func (h *Hedge) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	secondaryCtx, cancelSecondary := context.WithCancel(ctx)
+
+	results := make(chan hedgeStream, 2)
+	open := func(ctx context.Context, client Client, secondary bool) {
+		events, err := openStream(ctx, func() (<-chan StreamEvent, error) {
+			return client.StreamCompletion(ctx, messages, config)
+		})
+		results <- hedgeStream{events: events, err: err, secondary: secondary}
+	}
+
+	start := time.Now()
+	go open(primaryCtx, h.primary, false)
+
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+
+	pending, hedged, primaryDone := 1, false, false
+	hedgeAt := timer.C
+	hedge := func() {
+		hedged = true
+		hedgeAt = nil
+		pending++
+		go open(secondaryCtx, h.secondary, true)
+	}
+
+	for {
+		select {
+		case <-hedgeAt:
+			hedge()
+		case result := <-results:
+			pending--
+			if !result.secondary {
+				primaryDone = true
+				if result.err == nil {
+					h.observe(time.Since(start))
+				}
+			} else if result.err == nil && !primaryDone {
+				h.observe(time.Since(start))
+			}
+			if result.err != nil && !hedged && IsRetryable(result.err) && ctx.Err() == nil {
+				hedge()
+				continue
+			}
+			if result.err != nil && pending > 0 {
+				continue
+			}
+
+			cancelWinner, cancelLoser := cancelPrimary, cancelSecondary
+			if result.secondary {
+				cancelWinner, cancelLoser = cancelSecondary, cancelPrimary
+			}
+			cancelLoser()
+			if result.err != nil {
+				cancelWinner()
+				return nil, result.err
+			}
+
+			// The winner's context must live until its stream ends
+			events := make(chan StreamEvent)
+			go func() {
+				defer cancelWinner()
+				defer close(events)
+				for event := range result.events {
+					if !sendStreamEvent(ctx, events, event) {
+						return
+					}
+				}
+			}()
+			return events, nil
+		}
+	}
+}
+
+// CountTokens counts prompt tokens with the primary client
+func (h *Hedge) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return h.primary.CountTokens(ctx, messages, config)
+}
+
+// Close closes both clients
+func (h *Hedge) Close() error {
+	return closeAll(h.primary, h.secondary)
+}
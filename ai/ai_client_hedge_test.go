@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// billedOnCancel is a client that never finishes, and reports usage when it is canceled
+type billedOnCancel struct {
+	fakeClient
+	usage TokenUsage
+}
+
+func (c *billedOnCancel) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	c.calls.Add(1)
+	<-ctx.Done()
+	return Response{TokenUsage: c.usage}, ctx.Err()
+}
+
+func TestHedgeRace(t *testing.T) {
+	messages := []InputMessage{{Role: RoleUser, Content: "hello there"}}
+	input := EstimateTokens(messages, ModelConfig{})
+	estimate := TokenUsage{InputTokens: input, TotalTokens: input}
+	billed := TokenUsage{InputTokens: 7, OutputTokens: 3, TotalTokens: 10}
+	slow := 500 * time.Millisecond
+
+	tests := []struct {
+		name          string
+		primary       Client
+		secondary     *fakeClient
+		wantProvider  string
+		wantErr       ErrorCategory
+		wantHedged    bool
+		wantUsage     TokenUsage
+		wantSecondary int32
+		within        time.Duration // Upper bound on the race, to show it did not wait out the delay
+	}{
+		{"fast primary is not hedged", servedBy("primary"), servedBy("secondary"), "primary", "", false, TokenUsage{}, 0, slow},
+		{"slow primary is hedged", &fakeClient{response: Response{Provider: "primary"}, delay: slow}, servedBy("secondary"), "secondary", "", true, estimate, 1, slow},
+		{"loser usage is used when reported", &billedOnCancel{usage: billed}, servedBy("secondary"), "secondary", "", true, billed, 1, slow},
+		{"retryable primary failure hedges at once", failingWith(ErrorCategoryServer), servedBy("secondary"), "secondary", "", true, TokenUsage{}, 1, 100 * time.Millisecond},
+		{"other primary failures are returned", failingWith(ErrorCategoryAuth), servedBy("secondary"), "", ErrorCategoryAuth, false, TokenUsage{}, 0, 100 * time.Millisecond},
+		{"both fail", failingWith(ErrorCategoryRateLimited), failingWith(ErrorCategoryServer), "", ErrorCategoryServer, false, TokenUsage{}, 1, 100 * time.Millisecond},
+		{"secondary failure waits for the primary", &fakeClient{response: Response{Provider: "primary"}, delay: 100 * time.Millisecond}, failingWith(ErrorCategoryServer), "primary", "", true, TokenUsage{}, 1, slow},
+	}
+
+	for _, tt := range tests {
+		hedge := NewHedge(tt.primary, tt.secondary, HedgePolicy{InitialDelay: 20 * time.Millisecond, MinSamples: 100})
+		if tt.within <= 100*time.Millisecond {
+			// Immediate hedges must not wait for the delay
+			hedge = NewHedge(tt.primary, tt.secondary, HedgePolicy{InitialDelay: time.Minute, MinSamples: 100})
+		}
+
+		start := time.Now()
+		response, err := hedge.TextCompletion(context.Background(), messages, ModelConfig{})
+		elapsed := time.Since(start)
+
+		if tt.wantErr != "" {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got %v, want %s", tt.name, err, tt.wantErr)
+			}
+		} else if err != nil || response.Provider != tt.wantProvider {
+			t.Errorf("%s: got %q, %v, want a response from %s", tt.name, response.Provider, err, tt.wantProvider)
+		}
+		if err == nil && (response.Hedged != tt.wantHedged || response.HedgeUsage != tt.wantUsage) {
+			t.Errorf("%s: got hedged %v with %+v, want %v with %+v", tt.name, response.Hedged, response.HedgeUsage, tt.wantHedged, tt.wantUsage)
+		}
+		if got := tt.secondary.calls.Load(); got != tt.wantSecondary {
+			t.Errorf("%s: got %d secondary calls, want %d", tt.name, got, tt.wantSecondary)
+		}
+		if elapsed >= tt.within {
+			t.Errorf("%s: took %s, want under %s", tt.name, elapsed, tt.within)
+		}
+	}
+}
+
+func TestHedgeDelay(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    HedgePolicy
+		latencies []time.Duration
+		want      time.Duration
+	}{
+		{"initial delay before MinSamples", HedgePolicy{InitialDelay: time.Second, MinSamples: 3}, []time.Duration{1, 2}, time.Second},
+		{"percentile of the samples", HedgePolicy{Percentile: 0.5, MinSamples: 4}, []time.Duration{40, 10, 30, 20}, 20},
+		{"p95 takes the slowest of few samples", HedgePolicy{MinSamples: 4}, []time.Duration{40, 10, 30, 20}, 40},
+		{"only the latest samples count", HedgePolicy{Percentile: 1, MinSamples: 2, Samples: 2}, []time.Duration{90, 10, 20}, 20},
+		{"MinDelay bounds the delay", HedgePolicy{MinSamples: 1, MinDelay: 50}, []time.Duration{10}, 50},
+		{"MaxDelay bounds the delay", HedgePolicy{MinSamples: 1, MaxDelay: 5}, []time.Duration{10}, 5},
+	}
+
+	for _, tt := range tests {
+		hedge := NewHedge(servedBy("primary"), servedBy("secondary"), tt.policy)
+		for _, latency := range tt.latencies {
+			hedge.observe(latency)
+		}
+		if got := hedge.Delay(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHedgeStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		primary   *fakeClient
+		secondary *fakeClient
+		want      string
+	}{
+		{"fast primary", servedBy("primary"), servedBy("secondary"), "from primary"},
+		{"slow primary", &fakeClient{response: Response{Text: "from primary"}, delay: 500 * time.Millisecond}, servedBy("secondary"), "from secondary"},
+		{"failing primary", failingWith(ErrorCategoryTimeout), servedBy("secondary"), "from secondary"},
+	}
+
+	for _, tt := range tests {
+		hedge := NewHedge(tt.primary, tt.secondary, HedgePolicy{InitialDelay: 20 * time.Millisecond, MinSamples: 100})
+		events, err := hedge.StreamCompletion(context.Background(), nil, ModelConfig{})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if response, err := CollectStream(events); err != nil || response.Text != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, response.Text, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/NaheedRayan/openrouter-go/internal/fileutil"
+)
+
+// ErrConversationNotFound is returned by a HistoryStore when no conversation has the requested ID
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// HistoryStore persists conversation histories
+type HistoryStore interface {
+	// Load returns the conversation saved under id, or ErrConversationNotFound
+	Load(ctx context.Context, id string) (ConversationState, error)
+
+	// Save stores a conversation under its ID, replacing any earlier version
+	Save(ctx context.Context, state ConversationState) error
+
+	// Delete removes a conversation. Deleting a missing conversation is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryHistoryStore keeps conversations in memory for the life of the process
+type MemoryHistoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]ConversationState
+}
+
+// NewMemoryHistoryStore creates an empty in-memory store
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{conversations: map[string]ConversationState{}}
+}
+
+// Load returns a copy of the conversation saved under id
+func (s *MemoryHistoryStore) Load(ctx context.Context, id string) (ConversationState, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.conversations[id]
+	if !ok {
+		return ConversationState{}, ErrConversationNotFound
+	}
+	return state.copy(), nil
+}
+
+// Save stores a copy of the conversation
+func (s *MemoryHistoryStore) Save(ctx context.Context, state ConversationState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversations[state.ID] = state.copy()
+	return nil
+}
+
+// Delete removes a conversation
+func (s *MemoryHistoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conversations, id)
+	return nil
+}
+
+// FileHistoryStore keeps each conversation as a JSON file in a directory
+type FileHistoryStore struct {
+	dir string
+}
+
+// NewFileHistoryStore creates a store in dir, creating the directory if needed
+func NewFileHistoryStore(dir string) (*FileHistoryStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating history directory: %w", err)
+	}
+	return &FileHistoryStore{dir: dir}, nil
+}
+
+// path returns the file for a conversation. IDs are escaped so they cannot leave the directory.
+func (s *FileHistoryStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+".json")
+}
+
+// Load reads the conversation saved under id
+func (s *FileHistoryStore) Load(ctx context.Context, id string) (ConversationState, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ConversationState{}, ErrConversationNotFound
+	}
+	if err != nil {
+		return ConversationState{}, err
+	}
+
+	var state ConversationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return ConversationState{}, fmt.Errorf("error decoding conversation %s: %w", id, err)
+	}
+	return state, nil
+}
+
+// Save writes the conversation to a temporary file and renames it into place,
+// so a crash never leaves a partially written history behind
+func (s *FileHistoryStore) Save(ctx context.Context, state ConversationState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error encoding conversation %s: %w", state.ID, err)
+	}
+
+	return fileutil.WriteFileAtomic(s.path(state.ID), data)
+}
+
+// Delete removes the conversation file
+func (s *FileHistoryStore) Delete(ctx context.Context, id string) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
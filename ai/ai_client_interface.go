@@ -2,44 +2,146 @@ package ai
 
 import (
 	"context"
+	"strings"
+)
+
+// Message roles understood by every provider
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
 )
 
 // InputMessage represents a single message in a conversation
 type InputMessage struct {
-	Role    string
-	Content string
-	Images  []Image // Optional images for multimodal models
+	Role       string      `json:"role"`
+	Content    string      `json:"content,omitempty"`
+	Images     []Image     `json:"images,omitempty"`      // Optional images for multimodal models
+	ToolCalls  []ToolCall  `json:"tool_calls,omitempty"`  // Tool calls requested by the assistant in this turn
+	ToolResult *ToolResult `json:"tool_result,omitempty"` // Result of a tool call, required for the tool role
 }
 
 // Image represents an image to be processed by AI models
 type Image struct {
-	Format string
-	Data   []byte
-	URL    string // Optional URL alternative to inline data
+	Format string `json:"format,omitempty"`
+	Data   []byte `json:"data,omitempty"`
+	URL    string `json:"url,omitempty"`    // Optional URL alternative to inline data
+	Detail string `json:"detail,omitempty"` // Optional detail level ("low", "high" or "auto"), used by OpenAI
+}
+
+// MIMEType returns the media type of the image derived from its format
+func (img Image) MIMEType() string {
+	format := strings.ToLower(img.Format)
+	switch format {
+	case "jpg", "jpeg":
+		return "image/jpeg"
+	case "":
+		return "application/octet-stream"
+	}
+	if strings.Contains(format, "/") {
+		return format
+	}
+	return "image/" + format
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema describing the arguments
+}
+
+// ToolCall is a request from the model to invoke a tool
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
+// ToolResult carries the output of a tool call back to the model
+type ToolResult struct {
+	ToolCallID string `json:"tool_call_id"`
+	Name       string `json:"name,omitempty"` // Name of the tool that was called, found from ToolCallID if empty
+	Content    string `json:"content"`
+	IsError    bool   `json:"is_error,omitempty"`
 }
 
 // ModelConfig represents configuration parameters for an AI model
 type ModelConfig struct {
-	Temperature   float32
-	TopP          float32
-	TopK          int32
-	MaxTokens     int32
-	SystemPrompt  string
-	StopSequences []string
+	Temperature    float32 // Sent as given, so 0 asks for deterministic output
+	TopP           float32 // 0 leaves the provider default on Bedrock
+	TopK           int32   // 0 leaves the provider default on Bedrock
+	MaxTokens      int32
+	SystemPrompt   string
+	StopSequences  []string
+	Tools          []Tool          // Optional tools the model may call
+	ResponseSchema *ResponseSchema // Optional schema for structured JSON output
 }
 
 // Response represents a standardized response from any AI provider
 type Response struct {
-	Text       string
-	TokenUsage TokenUsage
-	Raw        interface{} // Raw provider-specific response
+	Text         string
+	ToolCalls    []ToolCall // Tool calls requested by the model
+	FinishReason string     // Provider-specific reason the model stopped
+	TokenUsage   TokenUsage
+	Provider     string           // Provider that served the request
+	Model        string           // Model that served the request
+	Attempts     int              // Number of attempts made, set by WithRetry
+	Cost         float64          // Cost of the request in US dollars, set by WithCostTracker
+	Hedged       bool             // A hedge request was sent to a second client, set by Hedge
+	HedgeUsage   TokenUsage       // Usage of the request that lost a Hedge race, estimated from its prompt if it reported none
+	Routing      *RoutingDecision // Why the backend was chosen, set by SmartRouter
+	Raw          interface{}      // Raw provider-specific response
 }
 
 // TokenUsage stores token usage information
 type TokenUsage struct {
-	InputTokens  int
-	OutputTokens int
-	TotalTokens  int
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+	CachedTokens int `json:"cached_tokens,omitempty"` // Input tokens read from the provider's prompt cache
+	ImageTokens  int `json:"image_tokens,omitempty"`  // Input tokens spent on images, estimated by CostTracker
+}
+
+// Add returns the sum of two usage records
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+		TotalTokens:  u.TotalTokens + other.TotalTokens,
+		CachedTokens: u.CachedTokens + other.CachedTokens,
+		ImageTokens:  u.ImageTokens + other.ImageTokens,
+	}
+}
+
+// StreamEventType identifies the kind of delta carried by a StreamEvent
+type StreamEventType int
+
+const (
+	// StreamEventText carries a chunk of generated text
+	StreamEventText StreamEventType = iota
+	// StreamEventToolCall carries a complete tool call requested by the model
+	StreamEventToolCall
+	// StreamEventFinish carries the reason the model stopped generating
+	StreamEventFinish
+	// StreamEventUsage carries the final token usage of the request
+	StreamEventUsage
+	// StreamEventError carries the error that terminated the stream
+	StreamEventError
+)
+
+// StreamEvent is a single delta emitted by StreamCompletion
+type StreamEvent struct {
+	Type         StreamEventType
+	Text         string
+	ToolCall     *ToolCall
+	FinishReason string
+	TokenUsage   TokenUsage
+	Provider     string  // Provider that served the stream, set on usage events
+	Model        string  // Model that served the stream, set on usage events
+	Cost         float64 // Cost of the request in US dollars, set on usage events by WithCostTracker
+	Err          error
 }
 
 // Client is the common interface for all AI providers
@@ -50,20 +152,36 @@ type Client interface {
 	// TextCompletion sends a text request to the AI provider
 	TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error)
 
+	// StreamCompletion sends a request and streams the response back as it is generated.
+	// The returned channel is closed when the stream ends, fails or ctx is canceled.
+	StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error)
+
 	// ImageRecognition sends images with optional text for processing
 	ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error)
 
+	// CountTokens returns the number of prompt tokens a request would use. Providers
+	// without a counting endpoint return a local estimate.
+	CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error)
+
 	// Close releases any resources held by the client
 	Close() error
 }
 
 // ClientOptions contains all configuration options
 type ClientOptions struct {
-	AccessKey   string
+	AccessKey   string // AWS access key, the default credential chain is used if empty
 	SecretKey   string
 	APIKey      string
 	Region      string
-	EndpointURL string
+	EndpointURL string // Optional base URL overriding the provider's default endpoint
 	ModelID     string
-	Timeout     int
+	Timeout     int    // Optional timeout in seconds for a response to arrive, 0 for none
+	BedrockAPI  string // Bedrock only: BedrockAPIInvoke or BedrockAPIConverse, chosen by model ID if empty
+
+	// AWS credentials beyond static keys, used by Bedrock
+	SessionToken    string // Session token for temporary static keys
+	Profile         string // Named profile from the shared config and credentials files
+	RoleARN         string // Role to assume on top of the resolved credentials
+	ExternalID      string // External ID required by the role's trust policy
+	RoleSessionName string // Session name for the assumed role, generated if empty
 }
@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"strings"
+	"sync"
+)
+
+// ModelCapabilities describes the limits and features of a model
+type ModelCapabilities struct {
+	ContextWindow   int  // Maximum prompt plus output tokens
+	MaxOutputTokens int  // Maximum tokens the model generates in one response
+	Images          bool // Accepts image input
+	Tools           bool // Supports tool calling
+}
+
+var (
+	modelsMu sync.RWMutex
+
+	// models is keyed by model ID prefix. Lookups use the longest matching prefix,
+	// so a specific entry such as "gpt-4o-mini" takes precedence over "gpt-4o".
+	models = map[string]ModelCapabilities{
+		// OpenAI
+		"gpt-4o":        {ContextWindow: 128000, MaxOutputTokens: 16384, Images: true, Tools: true},
+		"gpt-4o-mini":   {ContextWindow: 128000, MaxOutputTokens: 16384, Images: true, Tools: true},
+		"gpt-4.1":       {ContextWindow: 1047576, MaxOutputTokens: 32768, Images: true, Tools: true},
+		"gpt-4-turbo":   {ContextWindow: 128000, MaxOutputTokens: 4096, Images: true, Tools: true},
+		"gpt-4":         {ContextWindow: 8192, MaxOutputTokens: 8192, Tools: true},
+		"gpt-3.5-turbo": {ContextWindow: 16385, MaxOutputTokens: 4096, Tools: true},
+		"o1":            {ContextWindow: 200000, MaxOutputTokens: 100000, Images: true, Tools: true},
+		"o1-mini":       {ContextWindow: 128000, MaxOutputTokens: 65536},
+		"o3":            {ContextWindow: 200000, MaxOutputTokens: 100000, Images: true, Tools: true},
+		"o3-mini":       {ContextWindow: 200000, MaxOutputTokens: 100000, Tools: true},
+		"o4-mini":       {ContextWindow: 200000, MaxOutputTokens: 100000, Images: true, Tools: true},
+
+		// Gemini
+		"gemini-1.5-flash": {ContextWindow: 1048576, MaxOutputTokens: 8192, Images: true, Tools: true},
+		"gemini-1.5-pro":   {ContextWindow: 2097152, MaxOutputTokens: 8192, Images: true, Tools: true},
+		"gemini-2.0-flash": {ContextWindow: 1048576, MaxOutputTokens: 8192, Images: true, Tools: true},
+		"gemini-2.5-flash": {ContextWindow: 1048576, MaxOutputTokens: 65536, Images: true, Tools: true},
+		"gemini-2.5-pro":   {ContextWindow: 1048576, MaxOutputTokens: 65536, Images: true, Tools: true},
+
+		// Bedrock
+		"amazon.nova-micro":           {ContextWindow: 128000, MaxOutputTokens: 10000, Tools: true},
+		"amazon.nova-lite":            {ContextWindow: 300000, MaxOutputTokens: 10000, Images: true, Tools: true},
+		"amazon.nova-pro":             {ContextWindow: 300000, MaxOutputTokens: 10000, Images: true, Tools: true},
+		"amazon.titan-text-lite":      {ContextWindow: 4096, MaxOutputTokens: 4096},
+		"amazon.titan-text-express":   {ContextWindow: 8192, MaxOutputTokens: 8192},
+		"amazon.titan-text-premier":   {ContextWindow: 32000, MaxOutputTokens: 3072},
+		"anthropic.claude-3-haiku":    {ContextWindow: 200000, MaxOutputTokens: 4096, Images: true, Tools: true},
+		"anthropic.claude-3-opus":     {ContextWindow: 200000, MaxOutputTokens: 4096, Images: true, Tools: true},
+		"anthropic.claude-3-sonnet":   {ContextWindow: 200000, MaxOutputTokens: 4096, Images: true, Tools: true},
+		"anthropic.claude-3-5-haiku":  {ContextWindow: 200000, MaxOutputTokens: 8192, Tools: true},
+		"anthropic.claude-3-5-sonnet": {ContextWindow: 200000, MaxOutputTokens: 8192, Images: true, Tools: true},
+		"anthropic.claude-3-7-sonnet": {ContextWindow: 200000, MaxOutputTokens: 64000, Images: true, Tools: true},
+		"anthropic.claude-sonnet-4":   {ContextWindow: 200000, MaxOutputTokens: 64000, Images: true, Tools: true},
+		"anthropic.claude-opus-4":     {ContextWindow: 200000, MaxOutputTokens: 32000, Images: true, Tools: true},
+		"meta.llama3-8b":              {ContextWindow: 8192, MaxOutputTokens: 2048},
+		"meta.llama3-70b":             {ContextWindow: 8192, MaxOutputTokens: 2048},
+		"meta.llama3-1":               {ContextWindow: 128000, MaxOutputTokens: 2048, Tools: true},
+		"meta.llama3-2":               {ContextWindow: 128000, MaxOutputTokens: 2048, Tools: true},
+		"meta.llama3-2-11b":           {ContextWindow: 128000, MaxOutputTokens: 2048, Images: true, Tools: true},
+		"meta.llama3-2-90b":           {ContextWindow: 128000, MaxOutputTokens: 2048, Images: true, Tools: true},
+		"meta.llama3-3":               {ContextWindow: 128000, MaxOutputTokens: 2048, Tools: true},
+		"mistral.mistral-7b":          {ContextWindow: 32000, MaxOutputTokens: 8192},
+		"mistral.mixtral-8x7b":        {ContextWindow: 32000, MaxOutputTokens: 4096},
+		"mistral.mistral-large":       {ContextWindow: 128000, MaxOutputTokens: 8192, Tools: true},
+		"cohere.command-text":         {ContextWindow: 4000, MaxOutputTokens: 4000},
+		"cohere.command-light-text":   {ContextWindow: 4000, MaxOutputTokens: 4000},
+		"cohere.command-r":            {ContextWindow: 128000, MaxOutputTokens: 4000, Tools: true},
+		"cohere.command-r-plus":       {ContextWindow: 128000, MaxOutputTokens: 4000, Tools: true},
+	}
+)
+
+// RegisterModel adds or replaces the capabilities of every model whose ID starts with prefix
+func RegisterModel(prefix string, capabilities ModelCapabilities) {
+	modelsMu.Lock()
+	defer modelsMu.Unlock()
+
+	models[prefix] = capabilities
+}
+
+// LookupModel returns the capabilities of a model. Provider path prefixes such as
+// "models/", ARNs and cross-region inference profile prefixes such as "us." are ignored.
+func LookupModel(modelID string) (ModelCapabilities, bool) {
+	id := normalizeModelID(modelID)
+
+	modelsMu.RLock()
+	defer modelsMu.RUnlock()
+
+	return longestPrefixMatch(models, id)
+}
+
+// longestPrefixMatch returns the entry of table whose key is the longest prefix of id
+func longestPrefixMatch[V any](table map[string]V, id string) (V, bool) {
+	var best string
+	for prefix := range table {
+		if strings.HasPrefix(id, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	value, ok := table[best]
+	return value, ok && best != ""
+}
+
+// normalizeModelID reduces a model name, ARN or inference profile to the bare model ID
+func normalizeModelID(modelID string) string {
+	id := modelID
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+
+	// Inference profiles put a geography in front of "vendor.model"
+	if geography, rest, found := strings.Cut(id, "."); found && strings.Contains(rest, ".") &&
+		!strings.ContainsAny(geography, "-0123456789") {
+		id = rest
+	}
+
+	return id
+}
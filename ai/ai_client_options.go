@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// requestTimeout returns the configured per-request timeout, or 0 for none
+func (opts ClientOptions) requestTimeout() time.Duration {
+	if opts.Timeout <= 0 {
+		return 0
+	}
+	return time.Duration(opts.Timeout) * time.Second
+}
+
+// withTimeout applies the configured deadline to ctx. It is only used for requests that
+// end with their response; streams are limited by the response header timeout instead.
+func (opts ClientOptions) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := opts.requestTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// httpClient returns an HTTP client that times out waiting for response headers.
+// Reading the body is not limited, so a stream lives as long as the caller's context.
+func (opts ClientOptions) httpClient() *http.Client {
+	return &http.Client{Transport: opts.httpTransport()}
+}
+
+// httpTransport returns the default transport with the configured response header timeout
+func (opts ClientOptions) httpTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = opts.requestTimeout()
+	return transport
+}
+
+// downloadImage fetches an image referenced by URL for providers that only accept inline data
+func (opts ClientOptions) downloadImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+
+	resp, err := opts.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: %s", resp.Status)
+	}
+
+	imgBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	return imgBytes, nil
+}
@@ -0,0 +1,306 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PoolStrategy selects how a Pool spreads requests over its members
+type PoolStrategy int
+
+const (
+	// PoolRoundRobin sends requests to each member in turn
+	PoolRoundRobin PoolStrategy = iota
+	// PoolLeastInFlight sends requests to the member with the fewest requests in progress
+	PoolLeastInFlight
+	// PoolWeighted sends requests to members in proportion to their weights
+	PoolWeighted
+)
+
+// PoolMember is one set of credentials or one region in a Pool
+type PoolMember struct {
+	Name    string // Label for status reports, such as "key-2" or "eu-west-1"
+	Options ClientOptions
+	Weight  int // Share of requests under PoolWeighted, defaults to 1
+}
+
+// PoolPolicy controls how a Pool picks and ejects members
+type PoolPolicy struct {
+	Strategy     PoolStrategy
+	EjectFor     time.Duration // Ejection after a rate limit, or longer if the provider asks, defaults to 30s
+	AuthEjectFor time.Duration // Ejection after an auth or quota error, defaults to 5m
+}
+
+// withDefaults fills unset fields
+func (p PoolPolicy) withDefaults() PoolPolicy {
+	if p.EjectFor <= 0 {
+		p.EjectFor = 30 * time.Second
+	}
+	if p.AuthEjectFor <= 0 {
+		p.AuthEjectFor = 5 * time.Minute
+	}
+	return p
+}
+
+// PoolMemberStatus reports the state of a pool member
+type PoolMemberStatus struct {
+	Name         string
+	InFlight     int
+	EjectedUntil time.Time // Zero if the member is not ejected
+	LastError    error     // Error that caused the latest ejection
+}
+
+// poolMember is a member with its client and bookkeeping
+type poolMember struct {
+	name     string
+	client   Client
+	weight   int
+	current  int // Smooth weighted round-robin state
+	inFlight int
+	ejected  time.Time
+	lastErr  error
+}
+
+// Pool is a Client that spreads requests over several clients of one provider, each
+// with its own API key, credentials or region. Members that fail with auth, quota or
+// rate limit errors are ejected for a while and the request moves on to another member.
+type Pool struct {
+	policy PoolPolicy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	members []*poolMember
+	next    int // Round-robin position
+}
+
+// NewPool creates and initializes a client for every member of a provider
+func NewPool(ctx context.Context, provider string, members []PoolMember, policy PoolPolicy) (*Pool, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("pool has no members")
+	}
+
+	pool := &Pool{policy: policy.withDefaults(), now: time.Now}
+	for i, member := range members {
+		client, err := InitializeClient(ctx, provider, member.Options)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("error initializing pool member %d: %w", i, err)
+		}
+
+		name := member.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", provider, i)
+		}
+		pool.members = append(pool.members, &poolMember{
+			name:   name,
+			client: client,
+			weight: max(member.Weight, 1),
+		})
+	}
+
+	return pool, nil
+}
+
+// Status reports the state of every member
+func (p *Pool) Status() []PoolMemberStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	status := make([]PoolMemberStatus, len(p.members))
+	for i, member := range p.members {
+		status[i] = PoolMemberStatus{Name: member.name, InFlight: member.inFlight, LastError: member.lastErr}
+		if member.ejected.After(now) {
+			status[i].EjectedUntil = member.ejected
+		}
+	}
+	return status
+}
+
+// acquire picks a member that has not been tried yet for this request and counts it
+// as in flight. If every untried member is ejected, the one returning soonest is used.
+func (p *Pool) acquire(tried map[*poolMember]bool) *poolMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var available []*poolMember
+	var soonest *poolMember
+	for _, member := range p.members {
+		if tried[member] {
+			continue
+		}
+		if !member.ejected.After(now) {
+			available = append(available, member)
+		} else if soonest == nil || member.ejected.Before(soonest.ejected) {
+			soonest = member
+		}
+	}
+	if len(available) == 0 {
+		if soonest != nil {
+			soonest.inFlight++
+		}
+		return soonest
+	}
+
+	var chosen *poolMember
+	switch p.policy.Strategy {
+	case PoolLeastInFlight:
+		// Ties go round-robin so that idle members share the load
+		start := p.next % len(available)
+		for i := range available {
+			member := available[(start+i)%len(available)]
+			if chosen == nil || member.inFlight < chosen.inFlight {
+				chosen = member
+			}
+		}
+		p.next++
+	case PoolWeighted:
+		// Smooth weighted round-robin spreads heavy members out instead of bunching them
+		total := 0
+		for _, member := range available {
+			member.current += member.weight
+			total += member.weight
+			if chosen == nil || member.current > chosen.current {
+				chosen = member
+			}
+		}
+		chosen.current -= total
+	default:
+		chosen = available[p.next%len(available)]
+		p.next++
+	}
+
+	chosen.inFlight++
+	return chosen
+}
+
+// release marks a request to member as finished and ejects the member if err calls for it.
+// It reports whether the request should move on to another member.
+func (p *Pool) release(member *poolMember, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	member.inFlight--
+
+	var duration time.Duration
+	switch CategoryOf(err) {
+	case ErrorCategoryRateLimited:
+		duration = p.policy.EjectFor
+		var aiErr *Error
+		if errors.As(err, &aiErr) && aiErr.RetryAfter > duration {
+			duration = aiErr.RetryAfter
+		}
+	case ErrorCategoryAuth, ErrorCategoryQuota:
+		duration = p.policy.AuthEjectFor
+	default:
+		return false
+	}
+
+	member.ejected = p.now().Add(duration)
+	member.lastErr = err
+	return true
+}
+
+// do runs call on members until one succeeds, fails with an error that is not the
+// member's own fault, or every member has been tried
+func (p *Pool) do(ctx context.Context, call func(Client) (Response, error)) (Response, error) {
+	tried := map[*poolMember]bool{}
+	var response Response
+	var err error
+	for {
+		member := p.acquire(tried)
+		if member == nil {
+			return response, err
+		}
+		tried[member] = true
+
+		response, err = call(member.client)
+		if !p.release(member, err) || ctx.Err() != nil {
+			return response, err
+		}
+	}
+}
+
+// Initialize is a no-op, since NewPool initializes every member
+func (p *Pool) Initialize(ctx context.Context, opts ClientOptions) error {
+	return nil
+}
+
+// TextCompletion sends a text request to a pool member
+func (p *Pool) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return p.do(ctx, func(client Client) (Response, error) {
+		return client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text to a pool member
+func (p *Pool) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return p.do(ctx, func(client Client) (Response, error) {
+		return client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// StreamCompletion streams from a pool member. The member counts as in flight until
+// the stream ends; members that fail before the first event are ejected as usual.
+func (p *Pool) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	tried := map[*poolMember]bool{}
+	var err error
+	for {
+		member := p.acquire(tried)
+		if member == nil {
+			return nil, err
+		}
+		tried[member] = true
+
+		var upstream <-chan StreamEvent
+		upstream, err = openStream(ctx, func() (<-chan StreamEvent, error) {
+			return member.client.StreamCompletion(ctx, messages, config)
+		})
+		if err != nil {
+			if !p.release(member, err) || ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		events := make(chan StreamEvent)
+		go func() {
+			defer close(events)
+			var streamErr error
+			defer func() { p.release(member, streamErr) }()
+			for event := range upstream {
+				if event.Type == StreamEventError {
+					streamErr = event.Err
+				}
+				if !sendStreamEvent(ctx, events, event) {
+					return
+				}
+			}
+		}()
+		return events, nil
+	}
+}
+
+// CountTokens counts prompt tokens with a pool member
+func (p *Pool) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	var tokens int
+	_, err := p.do(ctx, func(client Client) (Response, error) {
+		var err error
+		tokens, err = client.CountTokens(ctx, messages, config)
+		return Response{}, err
+	})
+	return tokens, err
+}
+
+// Close closes every member
+func (p *Pool) Close() error {
+	clients := make([]Client, len(p.members))
+	for i, member := range p.members {
+		clients[i] = member.client
+	}
+	return closeAll(clients...)
+}
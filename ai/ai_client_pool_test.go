@@ -0,0 +1,188 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPool builds a pool over fake members named after their index letter
+func newTestPool(policy PoolPolicy, clock *fakeClock, clients ...Client) *Pool {
+	pool := &Pool{policy: policy.withDefaults(), now: clock.Now}
+	for i, client := range clients {
+		weight := 1
+		if policy.Strategy == PoolWeighted {
+			weight = len(clients) - i
+		}
+		pool.members = append(pool.members, &poolMember{name: string(rune('a' + i)), client: client, weight: weight})
+	}
+	return pool
+}
+
+func TestPoolStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy PoolStrategy
+		want     string
+	}{
+		{"round robin", PoolRoundRobin, "abcabc"},
+		// Weights are 3, 2 and 1, spread out rather than bunched
+		{"weighted", PoolWeighted, "abacba"},
+		// Without releases, every acquire adds load, so members take turns
+		{"least in flight", PoolLeastInFlight, "abcabc"},
+	}
+
+	for _, tt := range tests {
+		pool := newTestPool(PoolPolicy{Strategy: tt.strategy}, newFakeClock(), servedBy("a"), servedBy("b"), servedBy("c"))
+		var got strings.Builder
+		for i := 0; i < 6; i++ {
+			got.WriteString(pool.acquire(map[*poolMember]bool{}).name)
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got.String(), tt.want)
+		}
+	}
+}
+
+func TestPoolLeastInFlightPrefersIdle(t *testing.T) {
+	pool := newTestPool(PoolPolicy{Strategy: PoolLeastInFlight}, newFakeClock(), servedBy("a"), servedBy("b"), servedBy("c"))
+	busy := pool.acquire(map[*poolMember]bool{})
+	pool.acquire(map[*poolMember]bool{})
+	pool.release(busy, nil)
+
+	if got := pool.acquire(map[*poolMember]bool{}); got.name != "c" {
+		t.Errorf("got %s, want the idle member c", got.name)
+	}
+	if got := pool.acquire(map[*poolMember]bool{}); got != busy {
+		t.Errorf("got %s, want the released member %s", got.name, busy.name)
+	}
+}
+
+func TestPoolEjection(t *testing.T) {
+	policy := PoolPolicy{EjectFor: 30 * time.Second, AuthEjectFor: 5 * time.Minute}
+
+	tests := []struct {
+		name        string
+		err         error
+		wantEjected time.Duration // Ejection of the failing member, 0 for none
+		wantMovedOn bool
+	}{
+		{"rate limited", &Error{Category: ErrorCategoryRateLimited}, 30 * time.Second, true},
+		{"provider asks for longer", &Error{Category: ErrorCategoryRateLimited, RetryAfter: time.Minute}, time.Minute, true},
+		{"provider asks for less", &Error{Category: ErrorCategoryRateLimited, RetryAfter: time.Second}, 30 * time.Second, true},
+		{"auth", &Error{Category: ErrorCategoryAuth}, 5 * time.Minute, true},
+		{"quota", &Error{Category: ErrorCategoryQuota}, 5 * time.Minute, true},
+		{"server errors are not the member's fault", &Error{Category: ErrorCategoryServer}, 0, false},
+		{"invalid requests fail on every member", &Error{Category: ErrorCategoryInvalidRequest}, 0, false},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		failing, healthy := &fakeClient{err: tt.err}, servedBy("b")
+		pool := newTestPool(policy, clock, failing, healthy)
+
+		response, err := pool.TextCompletion(context.Background(), nil, ModelConfig{})
+		if tt.wantMovedOn {
+			if err != nil || response.Provider != "b" {
+				t.Errorf("%s: got %q, %v, want a response from b", tt.name, response.Provider, err)
+			}
+		} else if !errors.Is(err, tt.err) || healthy.calls.Load() != 0 {
+			t.Errorf("%s: got %v after %d calls to b, want the error without moving on", tt.name, err, healthy.calls.Load())
+		}
+
+		status := pool.Status()[0]
+		var wantUntil time.Time
+		var wantErr error
+		if tt.wantEjected > 0 {
+			wantUntil, wantErr = clock.Now().Add(tt.wantEjected), tt.err
+		}
+		if !status.EjectedUntil.Equal(wantUntil) || status.LastError != wantErr || status.InFlight != 0 {
+			t.Errorf("%s: got %+v, want ejected until %s", tt.name, status, wantUntil)
+		}
+	}
+}
+
+func TestPoolEjectedMembersReturn(t *testing.T) {
+	clock := newFakeClock()
+	limited := &fakeClient{err: &Error{Category: ErrorCategoryRateLimited}}
+	healthy := servedBy("b")
+	pool := newTestPool(PoolPolicy{EjectFor: 30 * time.Second}, clock, limited, healthy)
+
+	// a is ejected after its first failure and skipped while ejected
+	for i := 0; i < 4; i++ {
+		pool.TextCompletion(context.Background(), nil, ModelConfig{})
+	}
+	if limited.calls.Load() != 1 || healthy.calls.Load() != 4 {
+		t.Errorf("got %d and %d calls, want 1 and 4", limited.calls.Load(), healthy.calls.Load())
+	}
+
+	// Once the ejection ends, a is back in rotation
+	clock.Advance(30 * time.Second)
+	if got := pool.Status()[0].EjectedUntil; !got.IsZero() {
+		t.Errorf("got ejected until %s, want back", got)
+	}
+	pool.TextCompletion(context.Background(), nil, ModelConfig{})
+	pool.TextCompletion(context.Background(), nil, ModelConfig{})
+	if limited.calls.Load() != 2 {
+		t.Errorf("got %d calls to a, want it tried again", limited.calls.Load())
+	}
+}
+
+func TestPoolAllEjected(t *testing.T) {
+	clock := newFakeClock()
+	first := &fakeClient{err: &Error{Category: ErrorCategoryRateLimited, RetryAfter: time.Minute}}
+	second := &fakeClient{err: &Error{Category: ErrorCategoryRateLimited}}
+	pool := newTestPool(PoolPolicy{EjectFor: 30 * time.Second}, clock, first, second)
+
+	// Every member is tried once, then the last error is returned
+	_, err := pool.TextCompletion(context.Background(), nil, ModelConfig{})
+	if !errors.Is(err, second.err) || first.calls.Load() != 1 || second.calls.Load() != 1 {
+		t.Fatalf("got %v after %d and %d calls", err, first.calls.Load(), second.calls.Load())
+	}
+
+	// With every member ejected, the one due back soonest is tried
+	second.err = nil
+	second.response = Response{Provider: "b"}
+	if response, err := pool.TextCompletion(context.Background(), nil, ModelConfig{}); err != nil || response.Provider != "b" {
+		t.Errorf("got %q, %v, want b, which is due back first", response.Provider, err)
+	}
+}
+
+func TestPoolStream(t *testing.T) {
+	clock := newFakeClock()
+	failing := &fakeClient{err: &Error{Category: ErrorCategoryAuth}}
+	pool := newTestPool(PoolPolicy{}, clock, failing, servedBy("b"))
+
+	events, err := pool.StreamCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response, err := CollectStream(events); err != nil || response.Text != "from b" {
+		t.Errorf("got %q, %v, want the stream from b", response.Text, err)
+	}
+
+	// The member is released once its stream is done
+	deadline := time.Now().Add(time.Second)
+	for pool.Status()[1].InFlight != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	var inFlight []int
+	for _, status := range pool.Status() {
+		inFlight = append(inFlight, status.InFlight)
+	}
+	if !reflect.DeepEqual(inFlight, []int{0, 0}) || pool.Status()[0].EjectedUntil.IsZero() {
+		t.Errorf("got %+v, want a ejected and nothing in flight", pool.Status())
+	}
+}
+
+func TestNewPoolRequiresMembers(t *testing.T) {
+	if _, err := NewPool(context.Background(), ProviderOpenAI, nil, PoolPolicy{}); err == nil {
+		t.Errorf("expected an error for a pool without members")
+	}
+	if _, err := NewPool(context.Background(), "no-such-provider", []PoolMember{{}}, PoolPolicy{}); err == nil {
+		t.Errorf("expected an error for an unknown provider")
+	}
+}
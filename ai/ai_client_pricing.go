@@ -0,0 +1,117 @@
+package ai
+
+// DefaultPriceVersion identifies the list prices in DefaultPrices
+const DefaultPriceVersion = "2025-06-01"
+
+// ModelPrice is what a model costs in US dollars per million tokens
+type ModelPrice struct {
+	Input       float64 // Uncached text input
+	Output      float64 // Generated output, including reasoning tokens
+	CachedInput float64 // Input read from the prompt cache, defaults to Input
+	Image       float64 // Input spent on images, defaults to Input
+}
+
+// Cost returns the price of usage in US dollars. Cached and image tokens are counted
+// within InputTokens and charged at their own rates instead of the input rate.
+func (p ModelPrice) Cost(usage TokenUsage) float64 {
+	cachedRate, imageRate := p.CachedInput, p.Image
+	if cachedRate == 0 {
+		cachedRate = p.Input
+	}
+	if imageRate == 0 {
+		imageRate = p.Input
+	}
+
+	cached := min(usage.CachedTokens, usage.InputTokens)
+	images := min(usage.ImageTokens, usage.InputTokens-cached)
+	text := usage.InputTokens - cached - images
+
+	cost := float64(text)*p.Input +
+		float64(cached)*cachedRate +
+		float64(images)*imageRate +
+		float64(usage.OutputTokens)*p.Output
+	return cost / 1e6
+}
+
+// PriceTable maps model ID prefixes to prices. Lookups use the longest matching prefix,
+// like LookupModel. Version is recorded with every cost so bills can be traced to the
+// prices that produced them; change it whenever prices are edited.
+type PriceTable struct {
+	Version string
+	Prices  map[string]ModelPrice
+}
+
+// DefaultPrices returns a fresh copy of the built-in list prices, which may be edited
+// freely. Prices are on-demand list prices in US regions and exclude discounts.
+func DefaultPrices() PriceTable {
+	return PriceTable{
+		Version: DefaultPriceVersion,
+		Prices: map[string]ModelPrice{
+			// OpenAI
+			"gpt-4o":                 {Input: 2.50, Output: 10.00, CachedInput: 1.25},
+			"gpt-4o-mini":            {Input: 0.15, Output: 0.60, CachedInput: 0.075},
+			"gpt-4.1":                {Input: 2.00, Output: 8.00, CachedInput: 0.50},
+			"gpt-4.1-mini":           {Input: 0.40, Output: 1.60, CachedInput: 0.10},
+			"gpt-4.1-nano":           {Input: 0.10, Output: 0.40, CachedInput: 0.025},
+			"gpt-4-turbo":            {Input: 10.00, Output: 30.00},
+			"gpt-4":                  {Input: 30.00, Output: 60.00},
+			"gpt-3.5-turbo":          {Input: 0.50, Output: 1.50},
+			"o1":                     {Input: 15.00, Output: 60.00, CachedInput: 7.50},
+			"o1-mini":                {Input: 1.10, Output: 4.40, CachedInput: 0.55},
+			"o3":                     {Input: 2.00, Output: 8.00, CachedInput: 0.50},
+			"o3-mini":                {Input: 1.10, Output: 4.40, CachedInput: 0.55},
+			"o4-mini":                {Input: 1.10, Output: 4.40, CachedInput: 0.275},
+			"text-embedding-3-small": {Input: 0.02},
+			"text-embedding-3-large": {Input: 0.13},
+			"text-embedding-ada-002": {Input: 0.10},
+
+			// Gemini, for prompts within the standard context tier
+			"gemini-1.5-flash": {Input: 0.075, Output: 0.30, CachedInput: 0.01875},
+			"gemini-1.5-pro":   {Input: 1.25, Output: 5.00, CachedInput: 0.3125},
+			"gemini-2.0-flash": {Input: 0.10, Output: 0.40, CachedInput: 0.025},
+			"gemini-2.5-flash": {Input: 0.30, Output: 2.50, CachedInput: 0.075},
+			"gemini-2.5-pro":   {Input: 1.25, Output: 10.00, CachedInput: 0.31},
+
+			// Bedrock
+			"amazon.nova-micro":           {Input: 0.035, Output: 0.14},
+			"amazon.nova-lite":            {Input: 0.06, Output: 0.24},
+			"amazon.nova-pro":             {Input: 0.80, Output: 3.20},
+			"amazon.titan-text-lite":      {Input: 0.15, Output: 0.20},
+			"amazon.titan-text-express":   {Input: 0.20, Output: 0.60},
+			"amazon.titan-text-premier":   {Input: 0.50, Output: 1.50},
+			"amazon.titan-embed-text-v1":  {Input: 0.10},
+			"amazon.titan-embed-text-v2":  {Input: 0.02},
+			"anthropic.claude-3-haiku":    {Input: 0.25, Output: 1.25},
+			"anthropic.claude-3-opus":     {Input: 15.00, Output: 75.00},
+			"anthropic.claude-3-sonnet":   {Input: 3.00, Output: 15.00},
+			"anthropic.claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+			"anthropic.claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+			"anthropic.claude-3-7-sonnet": {Input: 3.00, Output: 15.00},
+			"anthropic.claude-sonnet-4":   {Input: 3.00, Output: 15.00},
+			"anthropic.claude-opus-4":     {Input: 15.00, Output: 75.00},
+			"meta.llama3-8b":              {Input: 0.30, Output: 0.60},
+			"meta.llama3-70b":             {Input: 2.65, Output: 3.50},
+			"meta.llama3-1-8b":            {Input: 0.22, Output: 0.22},
+			"meta.llama3-1-70b":           {Input: 0.72, Output: 0.72},
+			"meta.llama3-1-405b":          {Input: 2.40, Output: 2.40},
+			"meta.llama3-2-1b":            {Input: 0.10, Output: 0.10},
+			"meta.llama3-2-3b":            {Input: 0.15, Output: 0.15},
+			"meta.llama3-2-11b":           {Input: 0.16, Output: 0.16},
+			"meta.llama3-2-90b":           {Input: 0.72, Output: 0.72},
+			"meta.llama3-3-70b":           {Input: 0.72, Output: 0.72},
+			"mistral.mistral-7b":          {Input: 0.15, Output: 0.20},
+			"mistral.mixtral-8x7b":        {Input: 0.45, Output: 0.70},
+			"mistral.mistral-large":       {Input: 4.00, Output: 12.00},
+			"cohere.command-text":         {Input: 1.50, Output: 2.00},
+			"cohere.command-light-text":   {Input: 0.30, Output: 0.60},
+			"cohere.command-r":            {Input: 0.50, Output: 1.50},
+			"cohere.command-r-plus":       {Input: 3.00, Output: 15.00},
+			"cohere.embed":                {Input: 0.10},
+		},
+	}
+}
+
+// Lookup returns the price of a model, ignoring provider path and inference profile prefixes
+func (t PriceTable) Lookup(modelID string) (ModelPrice, bool) {
+	return longestPrefixMatch(t.Prices, normalizeModelID(modelID))
+}
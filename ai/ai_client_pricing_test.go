@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"math"
+	"testing"
+)
+
+func TestModelPriceCost(t *testing.T) {
+	price := ModelPrice{Input: 2, Output: 8, CachedInput: 0.5, Image: 4}
+
+	tests := []struct {
+		name  string
+		price ModelPrice
+		usage TokenUsage
+		want  float64
+	}{
+		{"text", price, TokenUsage{InputTokens: 1_000_000, OutputTokens: 500_000}, 2 + 4},
+		{"cached input", price, TokenUsage{InputTokens: 1_000_000, CachedTokens: 400_000}, 0.6*2 + 0.4*0.5},
+		{"image input", price, TokenUsage{InputTokens: 1_000_000, ImageTokens: 250_000}, 0.75*2 + 0.25*4},
+		{"cached and image", price, TokenUsage{InputTokens: 1_000_000, CachedTokens: 500_000, ImageTokens: 800_000}, 0.5*0.5 + 0.5*4},
+		{"rates default to input", ModelPrice{Input: 3}, TokenUsage{InputTokens: 1_000_000, CachedTokens: 200_000, ImageTokens: 300_000}, 3},
+		{"no usage", price, TokenUsage{}, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.price.Cost(tt.usage); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got $%f, want $%f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPriceTableLookup(t *testing.T) {
+	table := PriceTable{Prices: map[string]ModelPrice{
+		"gpt-4o":                   {Input: 2.5},
+		"gpt-4o-mini":              {Input: 0.15},
+		"anthropic.claude-3-haiku": {Input: 0.25},
+	}}
+
+	tests := []struct {
+		modelID string
+		want    float64
+		ok      bool
+	}{
+		{"gpt-4o-2024-08-06", 2.5, true},
+		{"gpt-4o-mini-2024-07-18", 0.15, true},
+		{"us.anthropic.claude-3-haiku-20240307-v1:0", 0.25, true},
+		{"gpt-3.5-turbo", 0, false},
+	}
+
+	for _, tt := range tests {
+		price, ok := table.Lookup(tt.modelID)
+		if ok != tt.ok || price.Input != tt.want {
+			t.Errorf("%s: got %v, %v, want %v, %v", tt.modelID, price.Input, ok, tt.want, tt.ok)
+		}
+	}
+
+	if defaults := DefaultPrices(); defaults.Version != DefaultPriceVersion || len(defaults.Prices) == 0 {
+		t.Errorf("got default prices version %q with %d models", defaults.Version, len(defaults.Prices))
+	}
+}
@@ -0,0 +1,300 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitMode selects what WithRateLimit does when a limit is reached
+type RateLimitMode int
+
+const (
+	// RateLimitWait blocks until capacity is available or the context is done
+	RateLimitWait RateLimitMode = iota
+	// RateLimitReject fails immediately with a *RateLimitError
+	RateLimitReject
+)
+
+// RateLimit sets the throughput allowed through a RateLimiter. Zero-valued fields are unlimited.
+type RateLimit struct {
+	RequestsPerMinute int
+	TokensPerMinute   int // Prompt plus MaxTokens, corrected to actual usage afterwards
+}
+
+// RateLimitError is returned in RateLimitReject mode when a request would exceed a limit.
+// It matches ErrorCategoryRateLimited with errors.Is, so WithRetry retries it after
+// RetryAfter and a Router falls back to its next client.
+type RateLimitError struct {
+	Key        string        // Key of the limiter, such as "openai/gpt-4o"
+	Limit      string        // "requests" or "tokens"
+	RetryAfter time.Duration // Time until the request would fit
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("client-side %s per minute limit reached for %s, retry after %s", e.Limit, e.Key, e.RetryAfter.Round(time.Millisecond))
+}
+
+// Unwrap lets errors.Is(err, ErrorCategoryRateLimited) and CategoryOf recognize the error
+func (e *RateLimitError) Unwrap() error {
+	return ErrorCategoryRateLimited
+}
+
+// bucket is a token bucket that refills continuously up to its capacity.
+// Its level may go negative when actual usage exceeds what was reserved.
+type bucket struct {
+	capacity float64
+	level    float64
+	last     time.Time
+}
+
+// refill adds what accumulated since the last call
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Minutes()
+	b.level = math.Min(b.capacity, b.level+elapsed*b.capacity)
+	b.last = now
+}
+
+// wait returns how long until amount fits. A full bucket always fits, so requests
+// larger than the capacity can still run, one at a time.
+func (b *bucket) wait(amount float64) time.Duration {
+	needed := math.Min(amount, b.capacity)
+	if b.level >= needed {
+		return 0
+	}
+	return time.Duration((needed - b.level) / b.capacity * float64(time.Minute))
+}
+
+// RateLimiter holds request and token buckets. One limiter can be shared by any number
+// of clients, so that they draw from the same per-minute allowance.
+type RateLimiter struct {
+	key   string
+	limit RateLimit
+	now   func() time.Time
+
+	mu       sync.Mutex
+	requests *bucket
+	tokens   *bucket
+}
+
+// NewRateLimiter creates a limiter. The key names it in errors.
+func NewRateLimiter(key string, limit RateLimit) *RateLimiter {
+	return newRateLimiter(key, limit, time.Now)
+}
+
+// newRateLimiter creates a limiter that reads the time from clock
+func newRateLimiter(key string, limit RateLimit, clock func() time.Time) *RateLimiter {
+	now := clock()
+	limiter := &RateLimiter{key: key, limit: limit, now: clock}
+	if limit.RequestsPerMinute > 0 {
+		capacity := float64(limit.RequestsPerMinute)
+		limiter.requests = &bucket{capacity: capacity, level: capacity, last: now}
+	}
+	if limit.TokensPerMinute > 0 {
+		capacity := float64(limit.TokensPerMinute)
+		limiter.tokens = &bucket{capacity: capacity, level: capacity, last: now}
+	}
+	return limiter
+}
+
+var (
+	sharedLimitersMu sync.Mutex
+	sharedLimiters   = map[string]*RateLimiter{}
+)
+
+// RateLimitKey returns the key that SharedRateLimiter uses for a provider and model
+func RateLimitKey(provider, model string) string {
+	return provider + "/" + model
+}
+
+// SharedRateLimiter returns the process-wide limiter for key, creating it with limit on
+// first use. Later calls return the same limiter and ignore limit.
+func SharedRateLimiter(key string, limit RateLimit) *RateLimiter {
+	sharedLimitersMu.Lock()
+	defer sharedLimitersMu.Unlock()
+
+	if limiter, ok := sharedLimiters[key]; ok {
+		return limiter
+	}
+	limiter := NewRateLimiter(key, limit)
+	sharedLimiters[key] = limiter
+	return limiter
+}
+
+// Limit returns the limits of the limiter
+func (l *RateLimiter) Limit() RateLimit {
+	return l.limit
+}
+
+// tryAcquire takes one request and tokens from the buckets if both have room,
+// otherwise it returns the limit that is short and how long until it is not
+func (l *RateLimiter) tryAcquire(tokens int) (string, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	var limit string
+	var wait time.Duration
+	if l.requests != nil {
+		l.requests.refill(now)
+		if w := l.requests.wait(1); w > wait {
+			limit, wait = "requests", w
+		}
+	}
+	if l.tokens != nil {
+		l.tokens.refill(now)
+		if w := l.tokens.wait(float64(tokens)); w > wait {
+			limit, wait = "tokens", w
+		}
+	}
+	if wait > 0 {
+		return limit, wait
+	}
+
+	if l.requests != nil {
+		l.requests.level--
+	}
+	if l.tokens != nil {
+		l.tokens.level -= float64(tokens)
+	}
+	return "", 0
+}
+
+// Acquire takes one request and tokens from the limiter. In RateLimitWait mode it blocks
+// until both fit or ctx is done; in RateLimitReject mode it returns a *RateLimitError.
+func (l *RateLimiter) Acquire(ctx context.Context, tokens int, mode RateLimitMode) error {
+	for {
+		limit, wait := l.tryAcquire(tokens)
+		if wait == 0 {
+			return nil
+		}
+		if mode == RateLimitReject {
+			return &RateLimitError{Key: l.key, Limit: limit, RetryAfter: wait}
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Adjust corrects the token bucket once the actual usage of a request is known.
+// A positive delta takes more tokens, a negative one returns unused tokens.
+func (l *RateLimiter) Adjust(delta int) {
+	if l.tokens == nil || delta == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens.refill(l.now())
+	l.tokens.level = math.Min(l.tokens.capacity, l.tokens.level-float64(delta))
+}
+
+// rateLimitClient wraps a Client and limits its throughput
+type rateLimitClient struct {
+	client  Client
+	limiter *RateLimiter
+	mode    RateLimitMode
+}
+
+// WithRateLimit wraps client so that requests draw from limiter before they are sent.
+// Wrap every client that calls the same provider and model with the same limiter,
+// for example one from SharedRateLimiter, to share its allowance.
+func WithRateLimit(client Client, limiter *RateLimiter, mode RateLimitMode) Client {
+	return &rateLimitClient{client: client, limiter: limiter, mode: mode}
+}
+
+// Initialize initializes the wrapped client
+func (c *rateLimitClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	return c.client.Initialize(ctx, opts)
+}
+
+// TextCompletion sends a text request once the limiter allows it
+func (c *rateLimitClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(ctx, messages, config, func() (Response, error) {
+		return c.client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text once the limiter allows it
+func (c *rateLimitClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(ctx, messages, config, func() (Response, error) {
+		return c.client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// StreamCompletion starts a stream once the limiter allows it and corrects the token
+// bucket when a usage event with counts arrives
+func (c *rateLimitClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	estimate := rateLimitEstimate(messages, config)
+	if err := c.limiter.Acquire(ctx, estimate, c.mode); err != nil {
+		return nil, err
+	}
+
+	upstream, err := c.client.StreamCompletion(ctx, messages, config)
+	if err != nil {
+		c.limiter.Adjust(-estimate)
+		return nil, err
+	}
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		for event := range upstream {
+			if event.Type == StreamEventUsage && event.TokenUsage != (TokenUsage{}) {
+				c.limiter.Adjust(usageTokens(event.TokenUsage) - estimate)
+			}
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// CountTokens counts prompt tokens with the wrapped client. Counting is not rate limited.
+func (c *rateLimitClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return c.client.CountTokens(ctx, messages, config)
+}
+
+// Close closes the wrapped client
+func (c *rateLimitClient) Close() error {
+	return c.client.Close()
+}
+
+// do acquires capacity, runs call and corrects the token bucket with the actual usage
+func (c *rateLimitClient) do(ctx context.Context, messages []InputMessage, config ModelConfig, call func() (Response, error)) (Response, error) {
+	estimate := rateLimitEstimate(messages, config)
+	if err := c.limiter.Acquire(ctx, estimate, c.mode); err != nil {
+		return Response{}, err
+	}
+
+	response, err := call()
+	switch {
+	case response.TokenUsage != (TokenUsage{}):
+		c.limiter.Adjust(usageTokens(response.TokenUsage) - estimate)
+	case err != nil:
+		// Requests that fail without usage are assumed not to have consumed tokens
+		c.limiter.Adjust(-estimate)
+	}
+	// A success that reports no usage keeps the estimate, since the tokens were spent
+	return response, err
+}
+
+// rateLimitEstimate is what a request is charged up front: the prompt plus the output
+// allowance, which is what providers count against their own token limits
+func rateLimitEstimate(messages []InputMessage, config ModelConfig) int {
+	return EstimateTokens(messages, config) + int(config.MaxTokens)
+}
+
+// usageTokens returns the total tokens of a usage record
+func usageTokens(usage TokenUsage) int {
+	if usage.TotalTokens > 0 {
+		return usage.TotalTokens
+	}
+	return usage.InputTokens + usage.OutputTokens
+}
@@ -0,0 +1,170 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when a test advances it
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestRateLimiterAcquire(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     RateLimit
+		before    []int // Tokens of requests acquired first
+		advance   time.Duration
+		tokens    int
+		wantLimit string
+		wantAfter time.Duration
+	}{
+		{"unlimited", RateLimit{}, []int{1000, 1000}, 0, 1000, "", 0},
+		{"requests left", RateLimit{RequestsPerMinute: 2}, []int{0}, 0, 0, "", 0},
+		{"requests exhausted", RateLimit{RequestsPerMinute: 2}, []int{0, 0}, 0, 0, "requests", 30 * time.Second},
+		{"requests refilled", RateLimit{RequestsPerMinute: 2}, []int{0, 0}, 30 * time.Second, 0, "", 0},
+		{"requests partly refilled", RateLimit{RequestsPerMinute: 2}, []int{0, 0}, 15 * time.Second, 0, "requests", 15 * time.Second},
+		{"tokens left", RateLimit{TokensPerMinute: 100}, []int{60}, 0, 40, "", 0},
+		{"tokens exhausted", RateLimit{TokensPerMinute: 100}, []int{60}, 0, 50, "tokens", 6 * time.Second},
+		{"tokens refilled", RateLimit{TokensPerMinute: 100}, []int{60}, 6 * time.Second, 50, "", 0},
+		{"larger than capacity waits for a full bucket", RateLimit{TokensPerMinute: 100}, []int{50}, 0, 500, "tokens", 30 * time.Second},
+		{"larger than capacity runs on a full bucket", RateLimit{TokensPerMinute: 100}, nil, 0, 500, "", 0},
+		{"longest wait wins", RateLimit{RequestsPerMinute: 1, TokensPerMinute: 100}, []int{100}, 0, 100, "requests", time.Minute},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		limiter := newRateLimiter("openai/gpt-4o", tt.limit, clock.Now)
+		for _, tokens := range tt.before {
+			if err := limiter.Acquire(context.Background(), tokens, RateLimitReject); err != nil {
+				t.Fatalf("%s: setup: %v", tt.name, err)
+			}
+		}
+		clock.Advance(tt.advance)
+
+		err := limiter.Acquire(context.Background(), tt.tokens, RateLimitReject)
+		if tt.wantLimit == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		var limitErr *RateLimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit || limitErr.RetryAfter != tt.wantAfter || limitErr.Key != "openai/gpt-4o" {
+			t.Errorf("%s: got %v, want %s limit with retry after %s", tt.name, err, tt.wantLimit, tt.wantAfter)
+			continue
+		}
+		if !errors.Is(err, ErrorCategoryRateLimited) || !IsRetryable(err) || !ShouldFallback(err) {
+			t.Errorf("%s: %v should be a retryable rate limit", tt.name, err)
+		}
+	}
+}
+
+func TestRateLimiterWaitHonorsContext(t *testing.T) {
+	clock := newFakeClock()
+	limiter := newRateLimiter("k", RateLimit{RequestsPerMinute: 1}, clock.Now)
+	limiter.Acquire(context.Background(), 0, RateLimitWait)
+
+	// The clock never moves, so the second request waits until its context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(ctx, 0, RateLimitWait); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestRateLimitReconcile(t *testing.T) {
+	messages := []InputMessage{{Role: RoleUser, Content: "hello there"}}
+	config := ModelConfig{MaxTokens: 100}
+	estimate := rateLimitEstimate(messages, config)
+	usage := TokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30}
+
+	tests := []struct {
+		name     string
+		response Response
+		err      error
+		want     int // Tokens charged once the request is done
+	}{
+		{"success charges actual usage", Response{TokenUsage: usage}, nil, 30},
+		{"success without usage keeps the estimate", Response{Text: "ok"}, nil, estimate},
+		{"failure without usage is refunded", Response{}, &Error{Category: ErrorCategoryServer}, 0},
+		{"usage without a total", Response{TokenUsage: TokenUsage{InputTokens: 10, OutputTokens: 5}}, nil, 15},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		limiter := newRateLimiter("k", RateLimit{TokensPerMinute: 1000}, clock.Now)
+		client := WithRateLimit(&fakeClient{response: tt.response, err: tt.err}, limiter, RateLimitReject)
+
+		client.TextCompletion(context.Background(), messages, config)
+		if got := int(1000 - limiter.tokens.level); got != tt.want {
+			t.Errorf("%s: got %d tokens charged, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitStream(t *testing.T) {
+	messages := []InputMessage{{Role: RoleUser, Content: "hello there"}}
+	config := ModelConfig{MaxTokens: 100}
+	estimate := rateLimitEstimate(messages, config)
+
+	tests := []struct {
+		name     string
+		response Response
+		err      error
+		want     int
+	}{
+		{"usage event corrects the charge", Response{Text: "hi", TokenUsage: TokenUsage{TotalTokens: 30}}, nil, 30},
+		{"no usage event keeps the estimate", Response{Text: "hi"}, nil, estimate},
+	}
+
+	for _, tt := range tests {
+		clock := newFakeClock()
+		limiter := newRateLimiter("k", RateLimit{TokensPerMinute: 1000}, clock.Now)
+		client := WithRateLimit(&fakeClient{response: tt.response, err: tt.err}, limiter, RateLimitReject)
+
+		events, err := client.StreamCompletion(context.Background(), messages, config)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		CollectStream(events)
+
+		limiter.mu.Lock()
+		got := int(1000 - limiter.tokens.level)
+		limiter.mu.Unlock()
+		if got != tt.want {
+			t.Errorf("%s: got %d tokens charged, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSharedRateLimiter(t *testing.T) {
+	key := RateLimitKey(ProviderOpenAI, "shared-test-model")
+	first := SharedRateLimiter(key, RateLimit{RequestsPerMinute: 1})
+	second := SharedRateLimiter(key, RateLimit{RequestsPerMinute: 100})
+
+	if first != second || second.Limit().RequestsPerMinute != 1 {
+		t.Errorf("got separate limiters %p and %p for one key", first, second)
+	}
+}
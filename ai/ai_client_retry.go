@@ -0,0 +1,183 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how WithRetry retries failed requests
+type RetryPolicy struct {
+	MaxAttempts    int              // Total attempts including the first, defaults to 3
+	InitialBackoff time.Duration    // Backoff ceiling before the first retry, defaults to 500ms
+	MaxBackoff     time.Duration    // Upper bound for a single backoff, defaults to 30s
+	Multiplier     float64          // Growth of the backoff ceiling per attempt, defaults to 2
+	MaxElapsed     time.Duration    // Total time budget across all attempts, 0 for no limit
+	ShouldRetry    func(error) bool // Decides which errors are retried, defaults to IsRetryable
+}
+
+// DefaultRetryPolicy returns the policy used for zero-valued RetryPolicy fields
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		ShouldRetry:    IsRetryable,
+	}
+}
+
+// withDefaults fills unset fields from DefaultRetryPolicy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := DefaultRetryPolicy()
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+	}
+	if p.ShouldRetry == nil {
+		p.ShouldRetry = defaults.ShouldRetry
+	}
+	return p
+}
+
+// backoff returns how long to wait after a failed attempt, using exponential backoff
+// with full jitter. A longer Retry-After hint from the provider takes precedence.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	ceiling := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if ceiling > float64(p.MaxBackoff) {
+		ceiling = float64(p.MaxBackoff)
+	}
+	wait := rand.N(time.Duration(ceiling) + 1)
+
+	var aiErr *Error
+	if errors.As(err, &aiErr) && aiErr.RetryAfter > wait {
+		wait = aiErr.RetryAfter
+	}
+	var limitErr *RateLimitError
+	if errors.As(err, &limitErr) && limitErr.RetryAfter > wait {
+		wait = limitErr.RetryAfter
+	}
+
+	return wait
+}
+
+// sleepContext waits for d, returning early with the context error if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryClient wraps a Client and retries transient failures
+type retryClient struct {
+	client Client
+	policy RetryPolicy
+}
+
+// WithRetry wraps client so that requests failing with retryable errors are retried.
+// The OpenAI and Bedrock clients do not retry on their own, so WithRetry is the only
+// retry layer. The Gemini SDK still retries unavailable errors internally, which a
+// single WithRetry attempt may therefore include.
+func WithRetry(client Client, policy RetryPolicy) Client {
+	return &retryClient{
+		client: client,
+		policy: policy.withDefaults(),
+	}
+}
+
+// Initialize initializes the wrapped client
+func (c *retryClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	return c.client.Initialize(ctx, opts)
+}
+
+// TextCompletion sends a text request, retrying transient failures
+func (c *retryClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(ctx, func(ctx context.Context) (Response, error) {
+		return c.client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text, retrying transient failures
+func (c *retryClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.do(ctx, func(ctx context.Context) (Response, error) {
+		return c.client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// StreamCompletion retries streams that fail before delivering their first delta.
+// Once output has reached the caller, a failure is passed through as-is.
+func (c *retryClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		events, err := openStream(ctx, func() (<-chan StreamEvent, error) {
+			return c.client.StreamCompletion(ctx, messages, config)
+		})
+
+		if err == nil || !c.shouldRetry(ctx, attempt, start, err) {
+			return events, err
+		}
+	}
+}
+
+// CountTokens counts prompt tokens, retrying transient failures
+func (c *retryClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		tokens, err := c.client.CountTokens(ctx, messages, config)
+
+		if err == nil || !c.shouldRetry(ctx, attempt, start, err) {
+			return tokens, err
+		}
+	}
+}
+
+// Close closes the wrapped client
+func (c *retryClient) Close() error {
+	return c.client.Close()
+}
+
+// do runs call until it succeeds or the policy gives up
+func (c *retryClient) do(ctx context.Context, call func(context.Context) (Response, error)) (Response, error) {
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		response, err := call(ctx)
+		response.Attempts = attempt
+
+		if err == nil || !c.shouldRetry(ctx, attempt, start, err) {
+			return response, err
+		}
+	}
+}
+
+// shouldRetry decides whether to make another attempt and waits out the backoff if so
+func (c *retryClient) shouldRetry(ctx context.Context, attempt int, start time.Time, err error) bool {
+	if attempt >= c.policy.MaxAttempts || !c.policy.ShouldRetry(err) || ctx.Err() != nil {
+		return false
+	}
+
+	wait := c.policy.backoff(attempt, err)
+	if c.policy.MaxElapsed > 0 && time.Since(start)+wait > c.policy.MaxElapsed {
+		return false
+	}
+
+	return sleepContext(ctx, wait) == nil
+}
@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyClient fails its first failures calls with err, then succeeds
+type flakyClient struct {
+	Client
+	failures int
+	err      error
+	calls    int
+}
+
+func (c *flakyClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	c.calls++
+	if c.calls <= c.failures {
+		return Response{}, c.err
+	}
+	return Response{Text: "ok"}, nil
+}
+
+func (c *flakyClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	c.calls++
+	events := make(chan StreamEvent, 1)
+	if c.calls <= c.failures {
+		events <- StreamEvent{Type: StreamEventError, Err: c.err}
+	} else {
+		events <- StreamEvent{Type: StreamEventText, Text: "ok"}
+	}
+	close(events)
+	return events, nil
+}
+
+// fastRetry keeps backoffs short enough for tests
+var fastRetry = RetryPolicy{InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestRetrySucceedsAfterTransientFailures(t *testing.T) {
+	client := &flakyClient{failures: 2, err: &Error{Category: ErrorCategoryServer}}
+
+	response, err := WithRetry(client, fastRetry).TextCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Text != "ok" || response.Attempts != 3 || client.calls != 3 {
+		t.Errorf("got %q after %d attempts and %d calls, want ok after 3", response.Text, response.Attempts, client.calls)
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	policy := fastRetry
+	policy.MaxAttempts = 2
+	client := &flakyClient{failures: 5, err: &Error{Category: ErrorCategoryRateLimited}}
+
+	response, err := WithRetry(client, policy).TextCompletion(context.Background(), nil, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryRateLimited) {
+		t.Fatalf("got %v, want a rate limit error", err)
+	}
+	if response.Attempts != 2 || client.calls != 2 {
+		t.Errorf("got %d attempts and %d calls, want 2", response.Attempts, client.calls)
+	}
+}
+
+func TestRetrySkipsFinalErrors(t *testing.T) {
+	client := &flakyClient{failures: 1, err: &Error{Category: ErrorCategoryInvalidRequest}}
+
+	_, err := WithRetry(client, fastRetry).TextCompletion(context.Background(), nil, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryInvalidRequest) || client.calls != 1 {
+		t.Errorf("got %v after %d calls, want the invalid request error after 1", err, client.calls)
+	}
+}
+
+func TestRetryCustomShouldRetry(t *testing.T) {
+	policy := fastRetry
+	policy.ShouldRetry = func(err error) bool { return errors.Is(err, ErrorCategoryQuota) }
+	client := &flakyClient{failures: 1, err: &Error{Category: ErrorCategoryQuota}}
+
+	if _, err := WithRetry(client, policy).TextCompletion(context.Background(), nil, ModelConfig{}); err != nil || client.calls != 2 {
+		t.Errorf("got %v after %d calls, want success after 2", err, client.calls)
+	}
+}
+
+func TestRetryStopsWhenContextEnds(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour}
+	client := &flakyClient{failures: 5, err: &Error{Category: ErrorCategoryServer, RetryAfter: time.Hour}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := WithRetry(client, policy).TextCompletion(ctx, nil, ModelConfig{})
+	if err == nil || client.calls != 1 {
+		t.Errorf("got %v after %d calls, want the first error", err, client.calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %s after the context ended", elapsed)
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	policy := fastRetry
+	policy.MaxElapsed = 50 * time.Millisecond
+	client := &flakyClient{failures: 5, err: &Error{Category: ErrorCategoryServer, RetryAfter: time.Second}}
+
+	start := time.Now()
+	_, err := WithRetry(client, policy).TextCompletion(context.Background(), nil, ModelConfig{})
+	if err == nil || client.calls != 1 {
+		t.Errorf("got %v after %d calls, want to give up after 1", err, client.calls)
+	}
+	if elapsed := time.Since(start); elapsed > policy.MaxElapsed {
+		t.Errorf("slept %s past the elapsed budget", elapsed)
+	}
+}
+
+func TestRetryStream(t *testing.T) {
+	client := &flakyClient{failures: 1, err: &Error{Category: ErrorCategoryServer}}
+
+	events, err := WithRetry(client, fastRetry).StreamCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response, err := CollectStream(events)
+	if err != nil || response.Text != "ok" || client.calls != 2 {
+		t.Errorf("got %q, %v after %d calls, want ok after 2", response.Text, err, client.calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}.withDefaults()
+	serverErr := &Error{Category: ErrorCategoryServer}
+
+	for attempt, ceiling := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		for i := 0; i < 50; i++ {
+			if wait := policy.backoff(attempt, serverErr); wait < 0 || wait > ceiling {
+				t.Fatalf("attempt %d: backoff %s outside [0, %s]", attempt, wait, ceiling)
+			}
+		}
+	}
+
+	// A longer Retry-After from the provider or the client-side limiter wins over the jitter
+	if wait := policy.backoff(1, &Error{Category: ErrorCategoryRateLimited, RetryAfter: 5 * time.Second}); wait != 5*time.Second {
+		t.Errorf("Retry-After: got %s, want 5s", wait)
+	}
+	if wait := policy.backoff(1, &RateLimitError{RetryAfter: 3 * time.Second}); wait != 3*time.Second {
+		t.Errorf("RateLimitError: got %s, want 3s", wait)
+	}
+}
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	policy := RetryPolicy{}.withDefaults()
+	if policy.MaxAttempts != 3 || policy.InitialBackoff != 500*time.Millisecond ||
+		policy.MaxBackoff != 30*time.Second || policy.Multiplier != 2 || policy.ShouldRetry == nil {
+		t.Errorf("unexpected defaults: %+v", policy)
+	}
+}
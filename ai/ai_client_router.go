@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Router is a Client that sends each request to an ordered list of initialized
+// clients, falling through to the next one when a client fails with a transient
+// or capacity error. Response.Provider records which client served the request.
+type Router struct {
+	clients []Client
+
+	// ShouldFallback decides whether an error moves the request on to the next
+	// client. Defaults to ShouldFallback when nil.
+	ShouldFallback func(error) bool
+}
+
+// NewRouter creates a router that tries clients in the given order
+func NewRouter(clients ...Client) *Router {
+	return &Router{clients: clients}
+}
+
+// ShouldFallback reports whether another provider or model may succeed where err failed:
+// rate limits, exhausted quota, server errors, timeouts, context windows that are too
+// small and open circuit breakers
+func ShouldFallback(err error) bool {
+	switch CategoryOf(err) {
+	case ErrorCategoryRateLimited, ErrorCategoryQuota, ErrorCategoryServer,
+		ErrorCategoryTimeout, ErrorCategoryContextLength, ErrorCategoryCircuitOpen:
+		return true
+	}
+	return false
+}
+
+// Initialize is a no-op, since the router is built from already initialized clients
+func (r *Router) Initialize(ctx context.Context, opts ClientOptions) error {
+	return nil
+}
+
+// TextCompletion sends a text request to the first client able to serve it
+func (r *Router) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return r.route(ctx, func(client Client) (Response, error) {
+		return client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text to the first client able to serve them
+func (r *Router) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return r.route(ctx, func(client Client) (Response, error) {
+		return client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// StreamCompletion streams from the first client that starts producing output.
+// Failures after output has reached the caller are not retried elsewhere.
+func (r *Router) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	if len(r.clients) == 0 {
+		return nil, fmt.Errorf("router has no clients")
+	}
+
+	var err error
+	for _, client := range r.clients {
+		var events <-chan StreamEvent
+		events, err = openStream(ctx, func() (<-chan StreamEvent, error) {
+			return client.StreamCompletion(ctx, messages, config)
+		})
+		if err == nil || !r.fallback(ctx, err) {
+			return events, err
+		}
+	}
+
+	return nil, err
+}
+
+// CountTokens counts prompt tokens with the first client able to do so
+func (r *Router) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	if len(r.clients) == 0 {
+		return 0, fmt.Errorf("router has no clients")
+	}
+
+	var tokens int
+	var err error
+	for _, client := range r.clients {
+		tokens, err = client.CountTokens(ctx, messages, config)
+		if err == nil || !r.fallback(ctx, err) {
+			return tokens, err
+		}
+	}
+
+	return tokens, err
+}
+
+// Close closes every client in the router
+func (r *Router) Close() error {
+	return closeAll(r.clients...)
+}
+
+// closeAll closes every client, joining their errors
+func closeAll(clients ...Client) error {
+	var errs []error
+	for _, client := range clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// route tries each client in order. If every client fails, the last error is returned.
+func (r *Router) route(ctx context.Context, call func(Client) (Response, error)) (Response, error) {
+	if len(r.clients) == 0 {
+		return Response{}, fmt.Errorf("router has no clients")
+	}
+
+	var response Response
+	var err error
+	for _, client := range r.clients {
+		response, err = call(client)
+		if err == nil || !r.fallback(ctx, err) {
+			return response, err
+		}
+	}
+
+	return response, err
+}
+
+// fallback decides whether to move on to the next client after err
+func (r *Router) fallback(ctx context.Context, err error) bool {
+	// Once the caller's own context is done, no other client can do better
+	if ctx.Err() != nil {
+		return false
+	}
+	if r.ShouldFallback != nil {
+		return r.ShouldFallback(err)
+	}
+	return ShouldFallback(err)
+}
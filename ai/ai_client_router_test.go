@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClient answers every request with response or err after delay and counts its calls
+type fakeClient struct {
+	response Response
+	err      error
+	delay    time.Duration
+	tokens   int
+	calls    atomic.Int32
+	closed   atomic.Bool
+}
+
+func (c *fakeClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	return nil
+}
+
+func (c *fakeClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	c.calls.Add(1)
+	if c.delay > 0 {
+		select {
+		case <-time.After(c.delay):
+		case <-ctx.Done():
+			return Response{}, ctx.Err()
+		}
+	}
+	if c.err != nil {
+		return Response{}, c.err
+	}
+	return c.response, nil
+}
+
+func (c *fakeClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.TextCompletion(ctx, messages, config)
+}
+
+// StreamCompletion replays the response as text, finish and usage events, or fails the stream with err
+func (c *fakeClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	response, err := c.TextCompletion(ctx, messages, config)
+
+	events := make(chan StreamEvent, 3)
+	if err != nil {
+		events <- StreamEvent{Type: StreamEventError, Err: err}
+	} else {
+		events <- StreamEvent{Type: StreamEventText, Text: response.Text}
+		events <- StreamEvent{Type: StreamEventFinish, FinishReason: response.FinishReason}
+		events <- StreamEvent{Type: StreamEventUsage, TokenUsage: response.TokenUsage, Provider: response.Provider, Model: response.Model}
+	}
+	close(events)
+	return events, nil
+}
+
+func (c *fakeClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return c.tokens, c.err
+}
+
+func (c *fakeClient) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// servedBy returns a fakeClient that answers as provider
+func servedBy(provider string) *fakeClient {
+	return &fakeClient{response: Response{Text: "from " + provider, Provider: provider}, tokens: 10}
+}
+
+// failingWith returns a fakeClient that fails every request with category
+func failingWith(category ErrorCategory) *fakeClient {
+	return &fakeClient{err: &Error{Category: category, Message: string(category)}}
+}
+
+func TestRouterFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		clients  []*fakeClient
+		provider string
+		want     ErrorCategory
+		calls    []int32
+	}{
+		{"first serves", []*fakeClient{servedBy("a"), servedBy("b")}, "a", "", []int32{1, 0}},
+		{"rate limited", []*fakeClient{failingWith(ErrorCategoryRateLimited), servedBy("b")}, "b", "", []int32{1, 1}},
+		{"server error twice", []*fakeClient{failingWith(ErrorCategoryServer), failingWith(ErrorCategoryTimeout), servedBy("c")}, "c", "", []int32{1, 1, 1}},
+		{"context length", []*fakeClient{failingWith(ErrorCategoryContextLength), servedBy("b")}, "b", "", []int32{1, 1}},
+		{"invalid request stops", []*fakeClient{failingWith(ErrorCategoryInvalidRequest), servedBy("b")}, "", ErrorCategoryInvalidRequest, []int32{1, 0}},
+		{"auth stops", []*fakeClient{failingWith(ErrorCategoryAuth), servedBy("b")}, "", ErrorCategoryAuth, []int32{1, 0}},
+		{"all fail", []*fakeClient{failingWith(ErrorCategoryServer), failingWith(ErrorCategoryQuota)}, "", ErrorCategoryQuota, []int32{1, 1}},
+	}
+
+	for _, tt := range tests {
+		clients := make([]Client, len(tt.clients))
+		for i, client := range tt.clients {
+			clients[i] = client
+		}
+
+		response, err := NewRouter(clients...).TextCompletion(context.Background(), nil, ModelConfig{})
+		if tt.want != "" {
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: got %v, want %s", tt.name, err, tt.want)
+			}
+		} else if err != nil || response.Provider != tt.provider {
+			t.Errorf("%s: got %q, %v, want %s", tt.name, response.Provider, err, tt.provider)
+		}
+		for i, client := range tt.clients {
+			if got := client.calls.Load(); got != tt.calls[i] {
+				t.Errorf("%s: client %d got %d calls, want %d", tt.name, i, got, tt.calls[i])
+			}
+		}
+	}
+}
+
+func TestRouterCustomShouldFallback(t *testing.T) {
+	first, second := failingWith(ErrorCategoryInvalidRequest), servedBy("b")
+	router := NewRouter(first, second)
+	router.ShouldFallback = func(err error) bool { return true }
+
+	response, err := router.TextCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil || response.Provider != "b" {
+		t.Errorf("got %q, %v, want b", response.Provider, err)
+	}
+}
+
+func TestRouterStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	first, second := failingWith(ErrorCategoryServer), servedBy("b")
+
+	_, err := NewRouter(first, second).TextCompletion(ctx, nil, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryServer) || second.calls.Load() != 0 {
+		t.Errorf("got %v with %d fallback calls, want the first error and none", err, second.calls.Load())
+	}
+}
+
+func TestRouterStreamFallback(t *testing.T) {
+	first, second := failingWith(ErrorCategoryRateLimited), servedBy("b")
+
+	events, err := NewRouter(first, second).StreamCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response, err := CollectStream(events)
+	if err != nil || response.Text != "from b" || response.Provider != "b" {
+		t.Errorf("got %q from %q, %v", response.Text, response.Provider, err)
+	}
+}
+
+func TestRouterCountTokens(t *testing.T) {
+	first, second := failingWith(ErrorCategoryServer), servedBy("b")
+	second.tokens = 42
+
+	tokens, err := NewRouter(first, second).CountTokens(context.Background(), nil, ModelConfig{})
+	if err != nil || tokens != 42 {
+		t.Errorf("got %d, %v, want 42", tokens, err)
+	}
+}
+
+func TestRouterWithoutClients(t *testing.T) {
+	router := NewRouter()
+	if _, err := router.TextCompletion(context.Background(), nil, ModelConfig{}); err == nil {
+		t.Error("TextCompletion succeeded without clients")
+	}
+	if _, err := router.StreamCompletion(context.Background(), nil, ModelConfig{}); err == nil {
+		t.Error("StreamCompletion succeeded without clients")
+	}
+}
+
+func TestRouterClose(t *testing.T) {
+	a, b := servedBy("a"), servedBy("b")
+	if err := NewRouter(a, b).Close(); err != nil || !a.closed.Load() || !b.closed.Load() {
+		t.Errorf("got %v, closed %v and %v", err, a.closed.Load(), b.closed.Load())
+	}
+}
@@ -0,0 +1,417 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResponseSchema asks the model to answer with JSON matching a schema
+type ResponseSchema struct {
+	Name   string                 // Identifier for the schema, defaults to "response"
+	Schema map[string]interface{} // JSON Schema the output must match
+}
+
+// SchemaValidationError lists every way a model output violates its schema
+type SchemaValidationError struct {
+	Violations []string
+}
+
+func (e *SchemaValidationError) Error() string {
+	return fmt.Sprintf("response does not match schema: %s", strings.Join(e.Violations, "; "))
+}
+
+var schemaNamePattern = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// schemaName returns a provider-safe name for the response schema
+func (s *ResponseSchema) schemaName() string {
+	name := schemaNamePattern.ReplaceAllString(s.Name, "_")
+	if name == "" {
+		return "response"
+	}
+	return name
+}
+
+// schemaInstruction builds the prompt fallback for providers without native schema support
+func schemaInstruction(schema *ResponseSchema) string {
+	if schema == nil {
+		return ""
+	}
+	schemaJSON, _ := json.Marshal(schema.Schema)
+	return "Respond only with a JSON document, without any surrounding text or code fences, " +
+		"that matches this JSON Schema:\n" + string(schemaJSON)
+}
+
+// SchemaFor derives a JSON Schema from the Go type T
+func SchemaFor[T any]() *ResponseSchema {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	return &ResponseSchema{
+		Name:   t.Name(),
+		Schema: SchemaFromType(t),
+	}
+}
+
+// SchemaFromType derives a JSON Schema from a Go type using its json struct tags.
+// Fields tagged with omitempty are optional, and a `description` tag documents a field.
+func SchemaFromType(t reflect.Type) map[string]interface{} {
+	return schemaFromType(t, map[reflect.Type]bool{})
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaFromType(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		// encoding/json writes byte slices as base64 strings
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaFromType(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFromType(t.Elem(), seen)}
+	case reflect.Struct:
+		// Recursive types are cut off rather than expanded forever
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		addStructFields(t, properties, &required, seen)
+
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+
+	// Interfaces and anything else accept any value
+	return map[string]interface{}{}
+}
+
+// addStructFields collects the JSON properties of a struct, flattening embedded structs
+func addStructFields(t reflect.Type, properties map[string]interface{}, required *[]string, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addStructFields(embedded, properties, required, seen)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaFromType(field.Type, seen)
+		if description := field.Tag.Get("description"); description != "" {
+			property["description"] = description
+		}
+		properties[name] = property
+
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// ValidateJSON checks a JSON document against a schema, returning a
+// *SchemaValidationError that lists every violation found
+func ValidateJSON(data []byte, schema map[string]interface{}) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return &SchemaValidationError{Violations: []string{fmt.Sprintf("$: invalid JSON: %v", err)}}
+	}
+
+	var violations []string
+	validateValue(value, schema, "$", &violations)
+	if len(violations) > 0 {
+		return &SchemaValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// validateValue checks a decoded JSON value against the supported subset of JSON Schema
+func validateValue(value interface{}, schema map[string]interface{}, path string, violations *[]string) {
+	if schema == nil {
+		return
+	}
+
+	violate := func(format string, args ...interface{}) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	types := schemaStrings(schema["type"])
+	if schemaType, ok := schema["type"].(string); ok {
+		types = []string{schemaType}
+	}
+	if len(types) > 0 {
+		if nullable, _ := schema["nullable"].(bool); nullable && value == nil {
+			return
+		}
+		if !matchesAnyType(value, types) {
+			violate("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"]; ok {
+		if !enumContains(enum, value) {
+			violate("value %v is not one of the allowed values", value)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				violate("missing required property %q", name)
+			}
+		}
+
+		// Iterate in a stable order so violations are reported deterministically
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			propertyPath := path + "." + name
+			if propertySchema, ok := properties[name].(map[string]interface{}); ok {
+				validateValue(v[name], propertySchema, propertyPath, violations)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					violate("unexpected property %q", name)
+				}
+			case map[string]interface{}:
+				validateValue(v[name], additional, propertyPath, violations)
+			}
+		}
+
+	case []interface{}:
+		if minItems, ok := schemaNumber(schema["minItems"]); ok && float64(len(v)) < minItems {
+			violate("expected at least %v items, got %d", minItems, len(v))
+		}
+		if maxItems, ok := schemaNumber(schema["maxItems"]); ok && float64(len(v)) > maxItems {
+			violate("expected at most %v items, got %d", maxItems, len(v))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(item, items, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+
+	case string:
+		length := float64(len([]rune(v)))
+		if minLength, ok := schemaNumber(schema["minLength"]); ok && length < minLength {
+			violate("expected at least %v characters", minLength)
+		}
+		if maxLength, ok := schemaNumber(schema["maxLength"]); ok && length > maxLength {
+			violate("expected at most %v characters", maxLength)
+		}
+
+	case float64:
+		if minimum, ok := schemaNumber(schema["minimum"]); ok && v < minimum {
+			violate("value %v is below the minimum %v", v, minimum)
+		}
+		if maximum, ok := schemaNumber(schema["maximum"]); ok && v > maximum {
+			violate("value %v is above the maximum %v", v, maximum)
+		}
+	}
+}
+
+// matchesAnyType reports whether a decoded JSON value has one of the schema types
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, schemaType := range types {
+		switch v := value.(type) {
+		case nil:
+			if schemaType == "null" {
+				return true
+			}
+		case bool:
+			if schemaType == "boolean" {
+				return true
+			}
+		case string:
+			if schemaType == "string" {
+				return true
+			}
+		case float64:
+			if schemaType == "number" || (schemaType == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		case []interface{}:
+			if schemaType == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if schemaType == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonTypeName names the JSON type of a decoded value for error messages
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// enumContains reports whether value is one of the enum entries
+func enumContains(enum interface{}, value interface{}) bool {
+	entries := reflect.ValueOf(enum)
+	if entries.Kind() != reflect.Slice {
+		return true
+	}
+	for i := 0; i < entries.Len(); i++ {
+		entry := entries.Index(i).Interface()
+		if number, ok := schemaNumber(entry); ok {
+			entry = number
+		}
+		if reflect.DeepEqual(entry, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaStrings reads a JSON Schema string list that may be typed either way
+func schemaStrings(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			if str, ok := item.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// schemaNumber reads a numeric schema keyword written as any Go number type
+func schemaNumber(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// extractJSON strips code fences and surrounding prose some models add around JSON output
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(text, "```")
+		text = strings.TrimSpace(text)
+	}
+
+	start := strings.IndexAny(text, "{[")
+	end := strings.LastIndexAny(text, "}]")
+	if start >= 0 && end >= start {
+		text = text[start : end+1]
+	}
+	return text
+}
+
+// CompleteInto requests JSON output matching the schema of T, validates it and decodes it.
+// If config.ResponseSchema is nil, the schema is derived from T.
+func CompleteInto[T any](ctx context.Context, client Client, messages []InputMessage, config ModelConfig) (T, Response, error) {
+	var result T
+
+	if config.ResponseSchema == nil {
+		config.ResponseSchema = SchemaFor[T]()
+	}
+
+	response, err := client.TextCompletion(ctx, messages, config)
+	if err != nil {
+		return result, response, err
+	}
+
+	output := extractJSON(response.Text)
+	if err := ValidateJSON([]byte(output), config.ResponseSchema.Schema); err != nil {
+		return result, response, err
+	}
+
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return result, response, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	return result, response, nil
+}
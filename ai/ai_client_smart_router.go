@@ -0,0 +1,561 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SmartStrategy selects how a SmartRouter ranks its backends
+type SmartStrategy int
+
+const (
+	// SmartCheapest prefers the capable backend with the lowest estimated cost
+	SmartCheapest SmartStrategy = iota
+	// SmartFastest prefers the capable backend with the lowest median latency
+	SmartFastest
+	// SmartWeighted prefers the capable backend with the lowest weighted score of cost, latency and errors
+	SmartWeighted
+)
+
+func (s SmartStrategy) String() string {
+	switch s {
+	case SmartCheapest:
+		return "cheapest"
+	case SmartFastest:
+		return "fastest"
+	case SmartWeighted:
+		return "weighted"
+	}
+	return fmt.Sprintf("SmartStrategy(%d)", int(s))
+}
+
+// SmartBackend is an initialized client a SmartRouter may choose
+type SmartBackend struct {
+	Name   string // Label in decisions and stats, defaults to Model
+	Client Client
+	Model  string // Model ID used to look up capabilities and prices
+}
+
+// SmartWeights weigh the terms of the SmartWeighted score. Cost and latency are scaled
+// to the most expensive and slowest capable backend, so every term is between 0 and 1.
+type SmartWeights struct {
+	Cost    float64
+	Latency float64
+	Errors  float64
+}
+
+// SmartRouterPolicy controls how a SmartRouter picks backends
+type SmartRouterPolicy struct {
+	Strategy      SmartStrategy
+	Weights       SmartWeights // Weights for SmartWeighted, defaults to 1 for every term
+	Prices        PriceTable   // Prices for cost estimates, defaults to DefaultPrices()
+	OutputReserve int          // Output tokens assumed when config.MaxTokens is 0, defaults to 1024
+	Window        int          // Recent requests per backend that stats are kept over, defaults to 100
+
+	// ShouldFallback decides whether an error moves the request on to the next
+	// ranked backend and counts against the backend. Defaults to ShouldFallback.
+	ShouldFallback func(error) bool
+
+	// OnDecision, if set, is called with every decision, including those for streams
+	OnDecision func(RoutingDecision)
+}
+
+// withDefaults fills unset fields
+func (p SmartRouterPolicy) withDefaults() SmartRouterPolicy {
+	if p.Weights == (SmartWeights{}) {
+		p.Weights = SmartWeights{Cost: 1, Latency: 1, Errors: 1}
+	}
+	if p.Prices.Prices == nil {
+		p.Prices = DefaultPrices()
+	}
+	if p.OutputReserve <= 0 {
+		p.OutputReserve = defaultOutputReserve
+	}
+	if p.Window <= 0 {
+		p.Window = 100
+	}
+	if p.ShouldFallback == nil {
+		p.ShouldFallback = ShouldFallback
+	}
+	return p
+}
+
+// RoutingCandidate is how a backend was judged for one request
+type RoutingCandidate struct {
+	Backend   string
+	Excluded  string        // Why the backend cannot serve the request, "" if it can
+	Cost      float64       // Estimated cost in US dollars
+	Priced    bool          // The model has a price, so Cost is meaningful
+	P50       time.Duration // Median latency of recent successful requests
+	Samples   int           // Latencies P50 was taken over
+	ErrorRate float64       // Share of recent requests that failed
+	Score     float64       // Weighted score under SmartWeighted, lower is better
+}
+
+// RoutingDecision records why a SmartRouter sent a request where it did
+type RoutingDecision struct {
+	Strategy   SmartStrategy
+	Backend    string             // Backend that served the request, or the last one tried
+	Reason     string             // Human-readable explanation of the choice
+	Failed     []string           // Higher-ranked backends that were tried first and failed
+	Candidates []RoutingCandidate // Capable backends in ranked order, followed by excluded ones
+}
+
+// SmartBackendStats reports the rolling statistics of a backend
+type SmartBackendStats struct {
+	Backend   string
+	Requests  int           // Requests in the window
+	P50       time.Duration // Median latency of recent successful requests
+	ErrorRate float64       // Share of recent requests that failed
+}
+
+// smartBackend is a backend with its rolling statistics
+type smartBackend struct {
+	SmartBackend
+
+	latencies   []time.Duration // Ring of recent successful latencies
+	nextLatency int
+	outcomes    []bool // Ring of recent outcomes, true for failures
+	nextOutcome int
+	failures    int
+}
+
+// SmartRouter is a Client that sends each request to the backend its policy ranks best
+// among those whose model can serve it: image input, tools and context size are checked
+// against LookupModel, with prompts near a context window counted by the backend. Rolling latency and error statistics are kept per backend, and the
+// decision is recorded on Response.Routing. Backends whose model is unknown are assumed
+// capable. If the chosen backend fails with a fallback error, the next one is tried.
+type SmartRouter struct {
+	policy SmartRouterPolicy
+
+	mu       sync.Mutex
+	backends []*smartBackend
+}
+
+// NewSmartRouter creates a router over initialized backends
+func NewSmartRouter(policy SmartRouterPolicy, backends ...SmartBackend) *SmartRouter {
+	router := &SmartRouter{policy: policy.withDefaults()}
+	for _, backend := range backends {
+		if backend.Name == "" {
+			backend.Name = backend.Model
+		}
+		router.backends = append(router.backends, &smartBackend{SmartBackend: backend})
+	}
+	return router
+}
+
+// Stats reports the rolling statistics of every backend
+func (r *SmartRouter) Stats() []SmartBackendStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]SmartBackendStats, len(r.backends))
+	for i, backend := range r.backends {
+		stats[i] = SmartBackendStats{Backend: backend.Name, Requests: len(backend.outcomes)}
+		stats[i].P50, _ = backend.p50()
+		stats[i].ErrorRate = backend.errorRate()
+	}
+	return stats
+}
+
+// p50 returns the median latency and the number of samples. The caller must hold the lock.
+func (b *smartBackend) p50() (time.Duration, int) {
+	if len(b.latencies) == 0 {
+		return 0, 0
+	}
+	return percentile(b.latencies, 0.5), len(b.latencies)
+}
+
+// errorRate returns the share of failed requests in the window. The caller must hold the lock.
+func (b *smartBackend) errorRate() float64 {
+	if len(b.outcomes) == 0 {
+		return 0
+	}
+	return float64(b.failures) / float64(len(b.outcomes))
+}
+
+// observe records the outcome of a request to backend. Latency is only recorded for
+// successes, since failures often return early. Canceled requests are not recorded.
+func (r *SmartRouter) observe(backend *smartBackend, latency time.Duration, err error) {
+	if CategoryOf(err) == ErrorCategoryCanceled {
+		return
+	}
+	failed := err != nil && r.policy.ShouldFallback(err)
+	if err != nil && !failed {
+		// Errors such as invalid requests say nothing about the backend
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(backend.outcomes) < r.policy.Window {
+		backend.outcomes = append(backend.outcomes, failed)
+	} else {
+		if backend.outcomes[backend.nextOutcome] {
+			backend.failures--
+		}
+		backend.outcomes[backend.nextOutcome] = failed
+		backend.nextOutcome = (backend.nextOutcome + 1) % len(backend.outcomes)
+	}
+	if failed {
+		backend.failures++
+		return
+	}
+
+	if latency <= 0 {
+		return
+	}
+	if len(backend.latencies) < r.policy.Window {
+		backend.latencies = append(backend.latencies, latency)
+		return
+	}
+	backend.latencies[backend.nextLatency] = latency
+	backend.nextLatency = (backend.nextLatency + 1) % len(backend.latencies)
+}
+
+// rank judges every backend for a request and returns the capable ones in order of
+// preference, along with a decision listing all candidates
+func (r *SmartRouter) rank(ctx context.Context, messages []InputMessage, config ModelConfig) ([]*smartBackend, RoutingDecision, error) {
+	decision := RoutingDecision{Strategy: r.policy.Strategy}
+	if len(r.backends) == 0 {
+		return nil, decision, fmt.Errorf("smart router has no backends")
+	}
+
+	input := EstimateTokens(messages, config)
+	output := int(config.MaxTokens)
+	if output <= 0 {
+		output = r.policy.OutputReserve
+	}
+	estimate := TokenUsage{InputTokens: input, OutputTokens: output, TotalTokens: input + output}
+	images := hasImages(messages)
+
+	type ranked struct {
+		backend   *smartBackend
+		candidate RoutingCandidate
+	}
+	var capable []ranked
+	var excluded []RoutingCandidate
+	contextOnly := true
+
+	r.mu.Lock()
+	for _, backend := range r.backends {
+		candidate := RoutingCandidate{Backend: backend.Name, ErrorRate: backend.errorRate()}
+		candidate.P50, candidate.Samples = backend.p50()
+		if price, ok := r.policy.Prices.Lookup(backend.Model); ok {
+			candidate.Cost, candidate.Priced = price.Cost(estimate), true
+		}
+
+		var contextLimit bool
+		candidate.Excluded, contextLimit = exclusionReason(backend.Model, images, len(config.Tools) > 0, config)
+		if candidate.Excluded != "" {
+			contextOnly = contextOnly && contextLimit
+			excluded = append(excluded, candidate)
+			continue
+		}
+		capable = append(capable, ranked{backend: backend, candidate: candidate})
+	}
+	r.mu.Unlock()
+
+	// Counting calls the backends, so it happens outside the lock
+	fitting := capable[:0]
+	for _, c := range capable {
+		if c.candidate.Excluded = contextExclusion(ctx, c.backend, messages, input, config); c.candidate.Excluded != "" {
+			excluded = append(excluded, c.candidate)
+			continue
+		}
+		fitting = append(fitting, c)
+	}
+	capable = fitting
+
+	if len(capable) == 0 {
+		decision.Candidates = excluded
+		reasons := make([]string, len(excluded))
+		for i, candidate := range excluded {
+			reasons[i] = candidate.Backend + ": " + candidate.Excluded
+		}
+		category := ErrorCategoryInvalidRequest
+		if contextOnly {
+			category = ErrorCategoryContextLength
+		}
+		return nil, decision, fmt.Errorf("%w: no backend can serve the request (%s)", category, strings.Join(reasons, "; "))
+	}
+
+	if r.policy.Strategy == SmartWeighted {
+		var maxCost float64
+		var maxP50 time.Duration
+		for _, c := range capable {
+			maxCost = max(maxCost, c.candidate.Cost)
+			maxP50 = max(maxP50, c.candidate.P50)
+		}
+		weights := r.policy.Weights
+		for i := range capable {
+			candidate := &capable[i].candidate
+			// Unpriced models score as the most expensive; unmeasured backends as the
+			// fastest, so that they get tried and measured
+			costTerm := 1.0
+			if candidate.Priced {
+				costTerm = 0
+				if maxCost > 0 {
+					costTerm = candidate.Cost / maxCost
+				}
+			}
+			var latencyTerm float64
+			if maxP50 > 0 {
+				latencyTerm = float64(candidate.P50) / float64(maxP50)
+			}
+			candidate.Score = weights.Cost*costTerm + weights.Latency*latencyTerm + weights.Errors*candidate.ErrorRate
+		}
+	}
+
+	// The sort is stable, so ties keep the configured order
+	slices.SortStableFunc(capable, func(a, b ranked) int {
+		return compareCandidates(r.policy.Strategy, a.candidate, b.candidate)
+	})
+
+	backends := make([]*smartBackend, len(capable))
+	for i, c := range capable {
+		backends[i] = c.backend
+		decision.Candidates = append(decision.Candidates, c.candidate)
+	}
+	decision.Candidates = append(decision.Candidates, excluded...)
+	return backends, decision, nil
+}
+
+// compareCandidates orders two capable candidates under a strategy
+func compareCandidates(strategy SmartStrategy, a, b RoutingCandidate) int {
+	byCost := func() int {
+		if a.Priced != b.Priced {
+			if a.Priced {
+				return -1
+			}
+			return 1
+		}
+		return compareFloat(a.Cost, b.Cost)
+	}
+	byLatency := func() int {
+		// Backends without samples go first so that they get measured
+		if (a.Samples == 0) != (b.Samples == 0) {
+			if a.Samples == 0 {
+				return -1
+			}
+			return 1
+		}
+		return compareFloat(float64(a.P50), float64(b.P50))
+	}
+
+	switch strategy {
+	case SmartFastest:
+		if c := byLatency(); c != 0 {
+			return c
+		}
+		return byCost()
+	case SmartWeighted:
+		return compareFloat(a.Score, b.Score)
+	default:
+		if c := byCost(); c != 0 {
+			return c
+		}
+		return byLatency()
+	}
+}
+
+// compareFloat compares two numbers for sorting
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// hasImages reports whether any message carries an image
+func hasImages(messages []InputMessage) bool {
+	for _, msg := range messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// exclusionReason returns why a model cannot serve a request, or "" if it can or is
+// unknown. contextLimit reports whether the reason is the size of the request. The
+// context window is checked separately by contextExclusion.
+func exclusionReason(model string, images, tools bool, config ModelConfig) (reason string, contextLimit bool) {
+	capabilities, ok := LookupModel(model)
+	if !ok {
+		return "", false
+	}
+
+	switch {
+	case images && !capabilities.Images:
+		return "no image input", false
+	case tools && !capabilities.Tools:
+		return "no tool calling", false
+	case capabilities.MaxOutputTokens > 0 && int(config.MaxTokens) > capabilities.MaxOutputTokens:
+		return fmt.Sprintf("MaxTokens %d exceeds the output limit of %d", config.MaxTokens, capabilities.MaxOutputTokens), true
+	}
+	return "", false
+}
+
+// contextExclusion returns why a backend's context window cannot hold a request, or ""
+// if it can or the backend cannot tell. The estimate is too rough to exclude on, so it
+// only decides whether to ask the backend for an exact count.
+func contextExclusion(ctx context.Context, backend *smartBackend, messages []InputMessage, estimate int, config ModelConfig) string {
+	if !nearContextWindow(backend.Model, estimate, config) {
+		return ""
+	}
+
+	tokens, err := backend.Client.CountTokens(ctx, messages, config)
+	if err != nil {
+		return ""
+	}
+	capabilities, _ := LookupModel(backend.Model)
+	if required := tokens + int(config.MaxTokens); required > capabilities.ContextWindow {
+		return fmt.Sprintf("needs %d tokens but the context window is %d", required, capabilities.ContextWindow)
+	}
+	return ""
+}
+
+// explain sets the backend and reason of a decision for the chosen candidate
+func (d *RoutingDecision) explain(chosen RoutingCandidate) {
+	d.Backend = chosen.Backend
+
+	var reason string
+	switch d.Strategy {
+	case SmartFastest:
+		if chosen.Samples == 0 {
+			reason = "no latency samples yet"
+		} else {
+			reason = fmt.Sprintf("lowest p50 latency of %s", chosen.P50.Round(time.Millisecond))
+		}
+	case SmartWeighted:
+		cost := "no price"
+		if chosen.Priced {
+			cost = fmt.Sprintf("estimated $%.6f", chosen.Cost)
+		}
+		reason = fmt.Sprintf("lowest weighted score %.3f (%s, p50 %s, error rate %.0f%%)",
+			chosen.Score, cost, chosen.P50.Round(time.Millisecond), chosen.ErrorRate*100)
+	default:
+		if chosen.Priced {
+			reason = fmt.Sprintf("cheapest capable backend at an estimated $%.6f", chosen.Cost)
+		} else {
+			reason = "no capable backend has a known price"
+		}
+	}
+
+	if len(d.Failed) > 0 {
+		reason = fmt.Sprintf("%s, after %s failed", reason, strings.Join(d.Failed, ", "))
+	}
+	d.Reason = reason
+}
+
+// decide records the choice of the backend at index i, in ranked order, and reports it
+func (r *SmartRouter) decide(decision *RoutingDecision, i int) {
+	decision.explain(decision.Candidates[i])
+	if r.policy.OnDecision != nil {
+		r.policy.OnDecision(*decision)
+	}
+}
+
+// fallback decides whether to move on to the next backend after err
+func (r *SmartRouter) fallback(ctx context.Context, err error) bool {
+	// Once the caller's own context is done, no other backend can do better
+	return ctx.Err() == nil && r.policy.ShouldFallback(err)
+}
+
+// Initialize is a no-op, since the router is built from already initialized clients
+func (r *SmartRouter) Initialize(ctx context.Context, opts ClientOptions) error {
+	return nil
+}
+
+// TextCompletion sends a text request to the best ranked backend able to serve it
+func (r *SmartRouter) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return r.route(ctx, messages, config, func(client Client) (Response, error) {
+		return client.TextCompletion(ctx, messages, config)
+	})
+}
+
+// ImageRecognition sends images with optional text to the best ranked backend able to serve them
+func (r *SmartRouter) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return r.route(ctx, messages, config, func(client Client) (Response, error) {
+		return client.ImageRecognition(ctx, messages, config)
+	})
+}
+
+// route tries the ranked backends in order. If every backend fails, the last error is returned.
+func (r *SmartRouter) route(ctx context.Context, messages []InputMessage, config ModelConfig, call func(Client) (Response, error)) (Response, error) {
+	backends, decision, err := r.rank(ctx, messages, config)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var response Response
+	for i, backend := range backends {
+		r.decide(&decision, i)
+
+		start := time.Now()
+		response, err = call(backend.Client)
+		r.observe(backend, time.Since(start), err)
+
+		response.Routing = &decision
+		if err == nil || !r.fallback(ctx, err) {
+			return response, err
+		}
+		decision.Failed = append(decision.Failed, backend.Name)
+	}
+
+	return response, err
+}
+
+// StreamCompletion streams from the best ranked backend that starts producing output.
+// Streams count towards error rates but not latency, since the time to the first event
+// is not comparable with the time to a complete response. Use OnDecision to audit them.
+func (r *SmartRouter) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	backends, decision, err := r.rank(ctx, messages, config)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, backend := range backends {
+		r.decide(&decision, i)
+
+		var events <-chan StreamEvent
+		events, err = openStream(ctx, func() (<-chan StreamEvent, error) {
+			return backend.Client.StreamCompletion(ctx, messages, config)
+		})
+		r.observe(backend, 0, err)
+
+		if err == nil || !r.fallback(ctx, err) {
+			return events, err
+		}
+		decision.Failed = append(decision.Failed, backend.Name)
+	}
+
+	return nil, err
+}
+
+// CountTokens counts prompt tokens with the backend the request would be sent to
+func (r *SmartRouter) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	backends, _, err := r.rank(ctx, messages, config)
+	if err != nil {
+		return 0, err
+	}
+	return backends[0].Client.CountTokens(ctx, messages, config)
+}
+
+// Close closes every backend
+func (r *SmartRouter) Close() error {
+	clients := make([]Client, len(r.backends))
+	for i, backend := range r.backends {
+		clients[i] = backend.Client
+	}
+	return closeAll(clients...)
+}
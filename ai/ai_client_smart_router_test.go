@@ -0,0 +1,252 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smartTestPrices prices the made-up models used by the routing tests
+var smartTestPrices = PriceTable{Prices: map[string]ModelPrice{
+	"model-cheap":  {Input: 1, Output: 1},
+	"model-pricey": {Input: 10, Output: 10},
+}}
+
+// backendNames returns the backend names of routing candidates, in order
+func backendNames(candidates []RoutingCandidate) []string {
+	names := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		names[i] = candidate.Backend
+	}
+	return names
+}
+
+func TestSmartRouterExclusions(t *testing.T) {
+	image := Image{Data: []byte("not decoded")}
+
+	tests := []struct {
+		name     string
+		backends []SmartBackend
+		messages []InputMessage
+		config   ModelConfig
+		want     string        // Backend that should serve the request
+		wantErr  ErrorCategory // Error when no backend can
+	}{
+		{"image input", []SmartBackend{{Name: "text", Model: "gpt-4"}, {Name: "vision", Model: "gpt-4o"}},
+			[]InputMessage{{Role: RoleUser, Content: "what is this?", Images: []Image{image}}}, ModelConfig{}, "vision", ""},
+		{"tool calling", []SmartBackend{{Name: "no-tools", Model: "o1-mini"}, {Name: "tools", Model: "gpt-4o-mini"}},
+			nil, ModelConfig{Tools: []Tool{{Name: "lookup"}}}, "tools", ""},
+		{"output limit", []SmartBackend{{Name: "short", Model: "gpt-4"}, {Name: "long", Model: "gpt-4o"}},
+			nil, ModelConfig{MaxTokens: 9000}, "long", ""},
+		{"unknown models are assumed capable", []SmartBackend{{Name: "unknown", Model: "model-unknown"}},
+			[]InputMessage{{Role: RoleUser, Images: []Image{image}}}, ModelConfig{Tools: []Tool{{Name: "lookup"}}}, "unknown", ""},
+		{"no capable backend", []SmartBackend{{Name: "text", Model: "gpt-4"}},
+			[]InputMessage{{Role: RoleUser, Images: []Image{image}}}, ModelConfig{}, "", ErrorCategoryInvalidRequest},
+		{"only the output limit", []SmartBackend{{Name: "short", Model: "gpt-4"}},
+			nil, ModelConfig{MaxTokens: 9000}, "", ErrorCategoryContextLength},
+	}
+
+	for _, tt := range tests {
+		for i := range tt.backends {
+			tt.backends[i].Client = servedBy(tt.backends[i].Name)
+		}
+		router := NewSmartRouter(SmartRouterPolicy{Prices: smartTestPrices}, tt.backends...)
+
+		response, err := router.TextCompletion(context.Background(), tt.messages, tt.config)
+		if tt.wantErr != "" {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || response.Provider != tt.want {
+			t.Errorf("%s: got %q, %v, want %s", tt.name, response.Provider, err, tt.want)
+		}
+	}
+}
+
+func TestSmartRouterContextWindow(t *testing.T) {
+	// About 4000 estimated tokens, near the 4096 window of Titan Text Lite
+	long := []InputMessage{{Role: RoleUser, Content: strings.Repeat("word ", 4000)}}
+	short := []InputMessage{{Role: RoleUser, Content: "hello"}}
+
+	tests := []struct {
+		name     string
+		messages []InputMessage
+		tokens   int   // What the small backend counts
+		countErr error // Error from counting
+		want     string
+		wantErr  ErrorCategory
+	}{
+		{"short prompts are not counted", short, 1_000_000, nil, "small", ""},
+		{"counted prompt that fits", long, 3000, nil, "small", ""},
+		{"counted prompt that does not fit", long, 5000, nil, "large", ""},
+		{"failed count keeps the backend", long, 0, errors.New("count failed"), "small", ""},
+	}
+
+	for _, tt := range tests {
+		small := &countingClient{fakeClient: fakeClient{response: Response{Provider: "small"}}, tokens: tt.tokens, err: tt.countErr}
+		router := NewSmartRouter(SmartRouterPolicy{Prices: smartTestPrices},
+			SmartBackend{Name: "small", Client: small, Model: "amazon.titan-text-lite-v1"},
+			SmartBackend{Name: "large", Client: servedBy("large"), Model: "amazon.titan-text-express-v1"})
+
+		response, err := router.TextCompletion(context.Background(), tt.messages, ModelConfig{})
+		if err != nil || response.Provider != tt.want {
+			t.Errorf("%s: got %q, %v, want %s", tt.name, response.Provider, err, tt.want)
+		}
+		if counted := small.counts > 0; counted != (tt.tokens != 1_000_000) {
+			t.Errorf("%s: counted %d times", tt.name, small.counts)
+		}
+	}
+
+	// Exclusion by exact count alone is a context length error
+	small := &countingClient{tokens: 5000}
+	router := NewSmartRouter(SmartRouterPolicy{}, SmartBackend{Name: "small", Client: small, Model: "amazon.titan-text-lite-v1"})
+	if _, err := router.TextCompletion(context.Background(), long, ModelConfig{}); !errors.Is(err, ErrorCategoryContextLength) {
+		t.Errorf("got %v, want a context length error", err)
+	}
+}
+
+// countingClient is a fakeClient whose CountTokens reports tokens or err and counts its calls
+type countingClient struct {
+	fakeClient
+	tokens int
+	err    error
+	counts int
+}
+
+func (c *countingClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	c.counts++
+	return c.tokens, c.err
+}
+
+func TestSmartRouterRanking(t *testing.T) {
+	backends := []SmartBackend{
+		{Name: "unpriced", Model: "model-unpriced"},
+		{Name: "pricey", Model: "model-pricey"},
+		{Name: "cheap", Model: "model-cheap"},
+	}
+	// Latencies observed before routing; unpriced has none
+	latencies := map[string]time.Duration{"pricey": 10 * time.Millisecond, "cheap": 50 * time.Millisecond}
+
+	tests := []struct {
+		name     string
+		strategy SmartStrategy
+		weights  SmartWeights
+		want     []string
+		reason   string
+	}{
+		{"cheapest", SmartCheapest, SmartWeights{}, []string{"cheap", "pricey", "unpriced"}, "cheapest capable backend"},
+		{"fastest measures new backends first", SmartFastest, SmartWeights{}, []string{"unpriced", "pricey", "cheap"}, "no latency samples yet"},
+		// Unpriced scores like the most expensive, and ties keep the configured order
+		{"weighted on cost", SmartWeighted, SmartWeights{Cost: 1}, []string{"cheap", "unpriced", "pricey"}, "lowest weighted score"},
+		{"weighted on latency", SmartWeighted, SmartWeights{Latency: 1}, []string{"unpriced", "pricey", "cheap"}, "lowest weighted score"},
+	}
+
+	for _, tt := range tests {
+		for i := range backends {
+			backends[i].Client = servedBy(backends[i].Name)
+		}
+		router := NewSmartRouter(SmartRouterPolicy{Strategy: tt.strategy, Weights: tt.weights, Prices: smartTestPrices}, backends...)
+		for _, backend := range router.backends {
+			if latency, ok := latencies[backend.Name]; ok {
+				router.observe(backend, latency, nil)
+			}
+		}
+
+		response, err := router.TextCompletion(context.Background(), []InputMessage{{Role: RoleUser, Content: "hi"}}, ModelConfig{MaxTokens: 100})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		routing := response.Routing
+		if got := backendNames(routing.Candidates); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got ranking %v, want %v", tt.name, got, tt.want)
+		}
+		if response.Provider != tt.want[0] || routing.Backend != tt.want[0] || !strings.Contains(routing.Reason, tt.reason) {
+			t.Errorf("%s: got %s with reason %q, want %s with %q", tt.name, routing.Backend, routing.Reason, tt.want[0], tt.reason)
+		}
+	}
+}
+
+func TestSmartRouterFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		want      string
+		wantErr   bool
+		wantRate  float64 // Error rate of the first backend afterwards
+		wantFails []string
+	}{
+		{"fallback error moves on", &Error{Category: ErrorCategoryServer}, "second", false, 1, []string{"first"}},
+		{"other errors are returned", &Error{Category: ErrorCategoryInvalidRequest}, "", true, 0, nil},
+		{"cancellation is returned", context.Canceled, "", true, 0, nil},
+	}
+
+	for _, tt := range tests {
+		var decisions []RoutingDecision
+		router := NewSmartRouter(SmartRouterPolicy{
+			Prices:     smartTestPrices,
+			OnDecision: func(decision RoutingDecision) { decisions = append(decisions, decision) },
+		},
+			SmartBackend{Name: "first", Client: &fakeClient{err: tt.err}, Model: "model-cheap"},
+			SmartBackend{Name: "second", Client: servedBy("second"), Model: "model-pricey"})
+
+		response, err := router.TextCompletion(context.Background(), nil, ModelConfig{})
+		if (err != nil) != tt.wantErr || response.Provider != tt.want {
+			t.Errorf("%s: got %q, %v", tt.name, response.Provider, err)
+		}
+		if !reflect.DeepEqual(response.Routing.Failed, tt.wantFails) {
+			t.Errorf("%s: got failed %v, want %v", tt.name, response.Routing.Failed, tt.wantFails)
+		}
+		if got := router.Stats()[0].ErrorRate; got != tt.wantRate {
+			t.Errorf("%s: got error rate %v, want %v", tt.name, got, tt.wantRate)
+		}
+		if len(decisions) != len(tt.wantFails)+1 {
+			t.Errorf("%s: got %d decisions, want one per backend tried", tt.name, len(decisions))
+		}
+	}
+}
+
+func TestSmartRouterStream(t *testing.T) {
+	var decisions []RoutingDecision
+	router := NewSmartRouter(SmartRouterPolicy{
+		Prices:     smartTestPrices,
+		OnDecision: func(decision RoutingDecision) { decisions = append(decisions, decision) },
+	},
+		SmartBackend{Name: "first", Client: failingWith(ErrorCategoryRateLimited), Model: "model-cheap"},
+		SmartBackend{Name: "second", Client: servedBy("second"), Model: "model-pricey"})
+
+	events, err := router.StreamCompletion(context.Background(), nil, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response, err := CollectStream(events); err != nil || response.Text != "from second" {
+		t.Errorf("got %q, %v, want the stream from second", response.Text, err)
+	}
+	if len(decisions) != 2 || decisions[1].Backend != "second" || !reflect.DeepEqual(decisions[1].Failed, []string{"first"}) {
+		t.Errorf("got decisions %+v", decisions)
+	}
+}
+
+func TestSmartRouterStatsWindow(t *testing.T) {
+	router := NewSmartRouter(SmartRouterPolicy{Window: 4}, SmartBackend{Name: "b", Client: servedBy("b")})
+	backend := router.backends[0]
+	failure := &Error{Category: ErrorCategoryServer}
+
+	for _, latency := range []time.Duration{10, 20, 30, 40} {
+		router.observe(backend, latency, nil)
+	}
+	// Failures push successes out of the window and add no latency
+	router.observe(backend, 1, failure)
+	router.observe(backend, 1, failure)
+	// Errors that are not the backend's fault are not recorded
+	router.observe(backend, 1, &Error{Category: ErrorCategoryInvalidRequest})
+
+	want := SmartBackendStats{Backend: "b", Requests: 4, P50: 20, ErrorRate: 0.5}
+	if got := router.Stats()[0]; got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"context"
+	"strings"
+)
+
+// sendStreamEvent delivers an event to the consumer, giving up if ctx is canceled first
+func sendStreamEvent(ctx context.Context, events chan<- StreamEvent, event StreamEvent) bool {
+	select {
+	case events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// prependStreamEvent re-emits an event that was read ahead of the consumer,
+// followed by the rest of the stream
+func prependStreamEvent(ctx context.Context, first StreamEvent, rest <-chan StreamEvent) <-chan StreamEvent {
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		if !sendStreamEvent(ctx, events, first) {
+			return
+		}
+		for event := range rest {
+			if !sendStreamEvent(ctx, events, event) {
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// openStream starts a stream and reads its first event, so that a failure before any
+// output reaches the consumer is returned as an error the caller can act on.
+// The returned channel replays the first event.
+func openStream(ctx context.Context, start func() (<-chan StreamEvent, error)) (<-chan StreamEvent, error) {
+	events, err := start()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case first, ok := <-events:
+		if !ok {
+			return events, nil
+		}
+		if first.Type == StreamEventError {
+			return nil, first.Err
+		}
+		return prependStreamEvent(ctx, first, events), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// CollectStream drains a stream returned by StreamCompletion into a single Response
+func CollectStream(events <-chan StreamEvent) (Response, error) {
+	var result Response
+	var text strings.Builder
+
+	for event := range events {
+		switch event.Type {
+		case StreamEventText:
+			text.WriteString(event.Text)
+		case StreamEventToolCall:
+			result.ToolCalls = append(result.ToolCalls, *event.ToolCall)
+		case StreamEventFinish:
+			result.FinishReason = event.FinishReason
+		case StreamEventUsage:
+			result.TokenUsage = event.TokenUsage
+			result.Provider = event.Provider
+			result.Model = event.Model
+			result.Cost = event.Cost
+		case StreamEventError:
+			result.Text = text.String()
+			return result, event.Err
+		}
+	}
+
+	result.Text = text.String()
+	return result, nil
+}
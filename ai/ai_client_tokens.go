@@ -0,0 +1,184 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	// Register decoders so image sizes can be read for token estimates
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// Chat formats wrap every message and the reply in a few control tokens
+const (
+	messageTokenOverhead = 3
+	replyTokenOverhead   = 3
+)
+
+// pretokenPattern splits text roughly the way BPE encodings do before applying merges:
+// contractions, words with an optional leading character, groups of up to three digits,
+// punctuation runs and whitespace
+var pretokenPattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// countTextTokens estimates the token count of text without a vocabulary. Text is split
+// into pieces like a BPE pre-tokenizer would, and the merges within each piece are guessed
+// from its length. It is used where no real encoding is available.
+func countTextTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	tokens := 0
+	for _, piece := range pretokenPattern.FindAllString(text, -1) {
+		tokens += pieceTokens(piece)
+	}
+	return tokens
+}
+
+// pieceTokens estimates how many vocabulary entries a pre-tokenized piece merges into
+func pieceTokens(piece string) int {
+	runes := utf8.RuneCountInString(piece)
+
+	// Outside ASCII, most characters are a token of their own
+	if len(piece) != runes {
+		ascii := 0
+		for _, r := range piece {
+			if r < utf8.RuneSelf {
+				ascii++
+			}
+		}
+		return (ascii+3)/4 + runes - ascii
+	}
+
+	last := rune(piece[len(piece)-1])
+	switch {
+	case strings.TrimSpace(piece) == "":
+		return 1
+	case isASCIILetter(last):
+		// Common words are single tokens, longer ones split into stems and affixes
+		if runes <= 9 {
+			return 1
+		}
+		return 1 + (runes-4)/6
+	case last >= '0' && last <= '9':
+		return 1
+	default:
+		// Runs of punctuation merge in pairs and triples
+		return 1 + (runes-1)/3
+	}
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// EstimateTokens approximates the prompt size of a request locally, without calling a
+// provider or loading a vocabulary. Use a client's CountTokens for a provider-specific count.
+func EstimateTokens(messages []InputMessage, config ModelConfig) int {
+	return estimateTokens(messages, config, countTextTokens, estimateImageTokens)
+}
+
+// estimateTokens sizes the prompt of a request, counting text with countText and
+// pricing images with imageTokens
+func estimateTokens(messages []InputMessage, config ModelConfig, countText func(string) int, imageTokens func(Image) int) int {
+	tokens := replyTokenOverhead
+
+	if config.SystemPrompt != "" {
+		tokens += messageTokenOverhead + countText(config.SystemPrompt)
+	}
+	if config.ResponseSchema != nil {
+		tokens += countText(schemaInstruction(config.ResponseSchema))
+	}
+	for _, tool := range config.Tools {
+		parameters, _ := json.Marshal(tool.Parameters)
+		tokens += countText(tool.Name) + countText(tool.Description) + countText(string(parameters))
+	}
+
+	for _, msg := range messages {
+		tokens += messageTokenOverhead + countText(msg.Content)
+		for _, img := range msg.Images {
+			tokens += imageTokens(img)
+		}
+		for _, call := range msg.ToolCalls {
+			tokens += countText(call.Name) + countText(call.Arguments)
+		}
+		if msg.ToolResult != nil {
+			tokens += countText(msg.ToolResult.Content)
+		}
+	}
+
+	return tokens
+}
+
+// imageDimensions reads the pixel size of an inline image without decoding it fully
+func imageDimensions(img Image) (width, height int, ok bool) {
+	if len(img.Data) == 0 {
+		return 0, 0, false
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return 0, 0, false
+	}
+	return config.Width, config.Height, true
+}
+
+// estimateImageTokens prices an image at roughly one token per 750 pixels, capped
+// where providers downscale large images. Images of unknown size get the cap.
+func estimateImageTokens(img Image) int {
+	const maxImageTokens = 1600
+
+	width, height, ok := imageDimensions(img)
+	if !ok {
+		return maxImageTokens
+	}
+	return min(width*height/750+1, maxImageTokens)
+}
+
+// checkContextWindow fails fast with a context length error when the prompt plus the
+// requested output cannot fit the model's context window. Unknown models are not checked.
+// inputTokens must be an exact count; estimates go through checkEstimatedContextWindow.
+func checkContextWindow(provider, modelID string, inputTokens int, config ModelConfig) error {
+	capabilities, ok := LookupModel(modelID)
+	if !ok || capabilities.ContextWindow == 0 {
+		return nil
+	}
+
+	required := inputTokens + int(config.MaxTokens)
+	if required <= capabilities.ContextWindow {
+		return nil
+	}
+
+	return &Error{
+		Category: ErrorCategoryContextLength,
+		Provider: provider,
+		Message: fmt.Sprintf("request needs about %d tokens (%d input, %d output) but %s has a context window of %d",
+			required, inputTokens, config.MaxTokens, modelID, capabilities.ContextWindow),
+	}
+}
+
+// estimateMargin is how far EstimateTokens may be off, as a fraction of the estimate
+const estimateMargin = 0.25
+
+// checkEstimatedContextWindow is checkContextWindow for an estimated prompt size. The
+// estimate is discounted by estimateMargin first, so that a request is only rejected
+// when it cannot fit even if the estimate counted too many tokens.
+func checkEstimatedContextWindow(provider, modelID string, estimate int, config ModelConfig) error {
+	return checkContextWindow(provider, modelID, int(float64(estimate)*(1-estimateMargin)), config)
+}
+
+// nearContextWindow reports whether an estimated prompt plus the requested output could
+// exceed the model's context window if the estimate counted too few tokens. Prompts that
+// are not near the window need no exact count to pass checkContextWindow.
+func nearContextWindow(modelID string, estimate int, config ModelConfig) bool {
+	capabilities, ok := LookupModel(modelID)
+	if !ok || capabilities.ContextWindow == 0 {
+		return false
+	}
+	return int(float64(estimate)*(1+estimateMargin))+int(config.MaxTokens) > capabilities.ContextWindow
+}
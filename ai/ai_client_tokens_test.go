@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCountTextTokens(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max int
+	}{
+		{"", 0, 0},
+		{"hello", 1, 1},
+		{"Hello, world!", 3, 5},
+		{"The quick brown fox jumps over the lazy dog.", 9, 12},
+		{"1234567", 3, 3},
+	}
+
+	for _, tt := range tests {
+		if got := countTextTokens(tt.text); got < tt.min || got > tt.max {
+			t.Errorf("%q: got %d tokens, want %d to %d", tt.text, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestContextWindowChecks(t *testing.T) {
+	RegisterModel("window-test", ModelCapabilities{ContextWindow: 1000})
+
+	tests := []struct {
+		name      string
+		modelID   string
+		tokens    int
+		maxTokens int32
+		exact     bool
+		estimated bool
+		near      bool
+	}{
+		{"well inside", "window-test", 500, 100, false, false, false},
+		{"near the window", "window-test", 750, 100, false, false, true},
+		{"just over", "window-test", 950, 100, true, false, true},
+		{"over even with the margin", "window-test", 1500, 0, true, true, true},
+		{"unknown model", "unknown-model", 1_000_000, 0, false, false, false},
+	}
+
+	for _, tt := range tests {
+		config := ModelConfig{MaxTokens: tt.maxTokens}
+		if err := checkContextWindow(ProviderOpenAI, tt.modelID, tt.tokens, config); (err != nil) != tt.exact {
+			t.Errorf("%s: exact check got %v", tt.name, err)
+		} else if err != nil && !errors.Is(err, ErrorCategoryContextLength) {
+			t.Errorf("%s: got %v, want a context length error", tt.name, err)
+		}
+		if err := checkEstimatedContextWindow(ProviderBedrock, tt.modelID, tt.tokens, config); (err != nil) != tt.estimated {
+			t.Errorf("%s: estimated check got %v", tt.name, err)
+		}
+		if got := nearContextWindow(tt.modelID, tt.tokens, config); got != tt.near {
+			t.Errorf("%s: got near %v, want %v", tt.name, got, tt.near)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	config := ModelConfig{SystemPrompt: "Be brief."}
+	short := EstimateTokens([]InputMessage{{Role: RoleUser, Content: "hi"}}, config)
+	long := EstimateTokens([]InputMessage{{Role: RoleUser, Content: strings.Repeat("word ", 100)}}, config)
+	withImage := EstimateTokens([]InputMessage{{Role: RoleUser, Content: "hi", Images: []Image{{URL: "https://example.com/a.png"}}}}, config)
+
+	if short <= 0 || long <= short+90 {
+		t.Errorf("got %d tokens for a short prompt and %d for a long one", short, long)
+	}
+	if withImage != short+estimateImageTokens(Image{}) {
+		t.Errorf("got %d tokens with an image of unknown size, want %d", withImage, short+estimateImageTokens(Image{}))
+	}
+}
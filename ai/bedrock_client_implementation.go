@@ -2,20 +2,29 @@ package ai
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 )
 
 // BedrockClient implements the Client interface for AWS Bedrock
 type BedrockClient struct {
-	client  *bedrockruntime.Client
-	options ClientOptions
-	modelID string
+	client      *bedrockruntime.Client
+	options     ClientOptions
+	modelID     string
+	useConverse bool
+	newCodec    func() bedrockCodec // InvokeModel payload codec for the model family
 }
 
 // NewBedrockClient creates a new AWS Bedrock client
@@ -25,65 +34,156 @@ func NewBedrockClient() *BedrockClient {
 
 // Initialize sets up the Bedrock client with AWS credentials
 func (c *BedrockClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	// Pick the API, defaulting to the native payload for families that Converse serves poorly
+	newCodec, invokeByDefault, hasCodec := bedrockCodecFor(opts.ModelID)
+	switch opts.BedrockAPI {
+	case BedrockAPIConverse:
+		c.useConverse = true
+	case BedrockAPIInvoke:
+		c.useConverse = false
+	case "":
+		c.useConverse = !invokeByDefault
+	default:
+		return fmt.Errorf("unsupported Bedrock API: %s", opts.BedrockAPI)
+	}
+	if !c.useConverse && !hasCodec {
+		return fmt.Errorf("no InvokeModel payload format known for model %s, use BedrockAPIConverse", opts.ModelID)
+	}
+	c.newCodec = newCodec
 
-	// Setup AWS credentials
-	var awsConfig aws.Config
-	var err error
-
-	cred := credentials.NewStaticCredentialsProvider(opts.AccessKey, opts.SecretKey, "")
-
-	awsConfig, err = config.LoadDefaultConfig(ctx,
-		config.WithCredentialsProvider(cred),
-		config.WithRegion(opts.Region),
-	)
-
+	awsConfig, err := bedrockConfig(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("unable to load AWS SDK config: %v", err)
+		return err
 	}
 
 	// Apply options
 	c.options = opts
-	c.client = bedrockruntime.NewFromConfig(awsConfig)
+	c.client = bedrockruntime.NewFromConfig(awsConfig, func(o *bedrockruntime.Options) {
+		// Point at a VPC or custom endpoint if provided
+		if opts.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(opts.EndpointURL)
+		}
+		// Retries are left to WithRetry, so that attempts do not multiply
+		o.Retryer = retry.AddWithMaxAttempts(retry.NewStandard(), 1)
+	})
 	c.modelID = opts.ModelID
 
 	return nil
 }
 
-// TextCompletion sends a text request to Bedrock
-func (c *BedrockClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
-	// Convert to Bedrock format messages
-	bedrockMessages := []map[string]interface{}{}
+// bedrockConfig resolves AWS credentials and region. Static keys take precedence;
+// without them the default chain covers environment variables, shared profiles, SSO,
+// web identity (EKS/IRSA) and ECS or EC2 instance roles.
+func bedrockConfig(ctx context.Context, opts ClientOptions) (aws.Config, error) {
+	// Reject combinations that would otherwise drop part of the configuration silently
+	switch {
+	case (opts.AccessKey == "") != (opts.SecretKey == ""):
+		return aws.Config{}, fmt.Errorf("AccessKey and SecretKey must be set together")
+	case opts.SessionToken != "" && opts.AccessKey == "":
+		return aws.Config{}, fmt.Errorf("SessionToken requires AccessKey and SecretKey")
+	case opts.Profile != "" && opts.AccessKey != "":
+		return aws.Config{}, fmt.Errorf("Profile cannot be combined with AccessKey and SecretKey")
+	}
 
-	for _, msg := range messages {
-		content := []map[string]interface{}{
-			{"text": msg.Content},
-		}
+	configOptions := []func(*config.LoadOptions) error{
+		config.WithRegion(opts.Region),
+	}
+
+	if opts.AccessKey != "" {
+		cred := credentials.NewStaticCredentialsProvider(opts.AccessKey, opts.SecretKey, opts.SessionToken)
+		configOptions = append(configOptions, config.WithCredentialsProvider(cred))
+	}
+
+	if opts.Profile != "" {
+		configOptions = append(configOptions, config.WithSharedConfigProfile(opts.Profile))
+	}
 
-		bedrockMessages = append(bedrockMessages, map[string]interface{}{
-			"role":    msg.Role,
-			"content": content,
+	// Apply the timeout to the wait for every response
+	if opts.Timeout > 0 {
+		httpClient := awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+			transport.ResponseHeaderTimeout = opts.requestTimeout()
 		})
+		configOptions = append(configOptions, config.WithHTTPClient(httpClient))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, configOptions...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load AWS SDK config: %w", err)
+	}
+
+	// Assume a role using the credentials resolved above
+	if opts.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsConfig), opts.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if opts.ExternalID != "" {
+				o.ExternalID = aws.String(opts.ExternalID)
+			}
+			if opts.RoleSessionName != "" {
+				o.RoleSessionName = opts.RoleSessionName
+			}
+		})
+		awsConfig.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return awsConfig, nil
+}
+
+// inlineImages downloads URL-only images, since InvokeModel only accepts inline image bytes
+func (c *BedrockClient) inlineImages(ctx context.Context, messages []InputMessage) ([]InputMessage, error) {
+	inlined := messages
+	copied := false
+
+	for i, msg := range messages {
+		imagesCopied := false
+		for j, img := range msg.Images {
+			if len(img.Data) > 0 || img.URL == "" {
+				continue
+			}
+
+			data, err := c.options.downloadImage(ctx, img.URL)
+			if err != nil {
+				return nil, err
+			}
+
+			// Copy before modifying so the caller's messages are left untouched
+			if !copied {
+				inlined = append([]InputMessage(nil), messages...)
+				copied = true
+			}
+			if !imagesCopied {
+				inlined[i].Images = append([]Image(nil), msg.Images...)
+				imagesCopied = true
+			}
+			inlined[i].Images[j].Data = data
+		}
+	}
+
+	return inlined, nil
+}
+
+// TextCompletion sends a text request to Bedrock
+func (c *BedrockClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	// Fail fast when the conversation cannot fit the model, even allowing for the
+	// estimate counting too many tokens
+	if err := checkEstimatedContextWindow(ProviderBedrock, c.modelID, EstimateTokens(messages, config), config); err != nil {
+		return Response{}, err
 	}
 
-	// Create request payload
-	requestPayload := map[string]interface{}{
-		"schemaVersion": "messages-v1",
-		"messages":      bedrockMessages,
-		"system": []map[string]string{
-			{"text": config.SystemPrompt},
-		},
-		"inferenceConfig": map[string]interface{}{
-			"maxTokens":   config.MaxTokens,
-			"topP":        config.TopP,
-			"topK":        config.TopK,
-			"temperature": config.Temperature,
-		},
+	if c.useConverse {
+		return c.converse(ctx, messages, config)
 	}
 
-	// Marshal request body to JSON
-	jsonBytes, err := json.Marshal(requestPayload)
+	ctx, cancel := c.options.withTimeout(ctx)
+	defer cancel()
+
+	messages, err := c.inlineImages(ctx, messages)
 	if err != nil {
-		return Response{}, fmt.Errorf("error marshaling request: %v", err)
+		return Response{}, err
+	}
+
+	codec := c.newCodec()
+	jsonBytes, err := codec.encode(messages, config, false)
+	if err != nil {
+		return Response{}, err
 	}
 
 	// Prepare the Bedrock API request
@@ -96,152 +196,172 @@ func (c *BedrockClient) TextCompletion(ctx context.Context, messages []InputMess
 	// Call the Bedrock API
 	response, err := c.client.InvokeModel(ctx, input)
 	if err != nil {
-		return Response{}, fmt.Errorf("error calling Bedrock API: %v", err)
+		return Response{}, bedrockError(err)
 	}
 
-	// Parse the response
-	var responseBody struct {
-		Output struct {
-			Message struct {
-				Content []struct {
-					Text string `json:"text"`
-				} `json:"content"`
-			} `json:"message"`
-		} `json:"output"`
-		Usage struct {
-			InputTokens  int `json:"inputTokens"`
-			OutputTokens int `json:"outputTokens"`
-		} `json:"usage"`
-	}
-
-	if err := json.Unmarshal(response.Body, &responseBody); err != nil {
-		return Response{}, fmt.Errorf("error unmarshaling response: %v", err)
-	}
-
-	// Format the standard response
-	result := Response{
-		Raw: responseBody,
-		TokenUsage: TokenUsage{
-			InputTokens:  responseBody.Usage.InputTokens,
-			OutputTokens: responseBody.Usage.OutputTokens,
-			TotalTokens:  responseBody.Usage.InputTokens + responseBody.Usage.OutputTokens,
-		},
+	result, err := codec.decode(response.Body)
+	if err != nil {
+		return Response{}, err
 	}
 
-	// Extract the text from the response
-	if len(responseBody.Output.Message.Content) > 0 {
-		result.Text = responseBody.Output.Message.Content[0].Text
+	// Some families only report usage in the response headers
+	if result.TokenUsage.TotalTokens == 0 {
+		result.TokenUsage = headerUsage(response.ResultMetadata)
 	}
+	result.Provider = ProviderBedrock
+	result.Model = c.modelID
 
 	return result, nil
 }
 
-// ImageRecognition sends images with optional text to Bedrock
-func (c *BedrockClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
-	// We'll only use the last message for simplicity
-	if len(messages) == 0 {
-		return Response{}, fmt.Errorf("no messages provided")
+// StreamCompletion streams a text response from Bedrock
+func (c *BedrockClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	// Fail fast when the conversation cannot fit the model, even allowing for the
+	// estimate counting too many tokens
+	if err := checkEstimatedContextWindow(ProviderBedrock, c.modelID, EstimateTokens(messages, config), config); err != nil {
+		return nil, err
 	}
 
-	message := messages[len(messages)-1]
-
-	// Create content array for the request
-	var contentArray []map[string]interface{}
-
-	// Add images to content array
-	for _, img := range message.Images {
-		imgContent := map[string]interface{}{
-			"image": map[string]interface{}{
-				"format": img.Format,
-				"source": map[string]string{
-					"bytes": base64.StdEncoding.EncodeToString(img.Data),
-				},
-			},
-		}
-		contentArray = append(contentArray, imgContent)
+	if c.useConverse {
+		return c.converseStream(ctx, messages, config)
 	}
 
-	// Add text to content array if present
-	if message.Content != "" {
-		contentArray = append(contentArray, map[string]interface{}{
-			"text": message.Content,
-		})
+	messages, err := c.inlineImages(ctx, messages)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create request payload
-	requestPayload := map[string]interface{}{
-		"schemaVersion": "messages-v1",
-		"messages": []map[string]interface{}{
-			{
-				"role":    message.Role,
-				"content": contentArray,
-			},
-		},
-		"system": []map[string]string{
-			{"text": config.SystemPrompt},
-		},
-		"inferenceConfig": map[string]interface{}{
-			"maxTokens":   config.MaxTokens,
-			"topP":        config.TopP,
-			"topK":        config.TopK,
-			"temperature": config.Temperature,
-		},
-	}
-
-	// Marshal request body to JSON
-	jsonBytes, err := json.Marshal(requestPayload)
+	codec := c.newCodec()
+	jsonBytes, err := codec.encode(messages, config, true)
 	if err != nil {
-		return Response{}, fmt.Errorf("error marshaling request: %v", err)
+		return nil, err
 	}
 
 	// Prepare the Bedrock API request
-	input := &bedrockruntime.InvokeModelInput{
+	input := &bedrockruntime.InvokeModelWithResponseStreamInput{
 		ModelId:     aws.String(c.modelID),
 		Body:        jsonBytes,
 		ContentType: aws.String("application/json"),
 	}
 
 	// Call the Bedrock API
-	response, err := c.client.InvokeModel(ctx, input)
+	response, err := c.client.InvokeModelWithResponseStream(ctx, input)
 	if err != nil {
-		return Response{}, fmt.Errorf("error calling Bedrock API: %v", err)
+		return nil, bedrockError(err)
 	}
 
-	// Parse the response
-	var responseBody struct {
-		Output struct {
-			Message struct {
-				Content []struct {
-					Text string `json:"text"`
-				} `json:"content"`
-			} `json:"message"`
-		} `json:"output"`
-		Usage struct {
-			InputTokens  int `json:"inputTokens"`
-			OutputTokens int `json:"outputTokens"`
-		} `json:"usage"`
-	}
+	stream := response.GetStream()
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close()
+
+		// Families without usage in their own events fall back to the invocation metrics
+		usageSent := false
+
+		for {
+			var event types.ResponseStream
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok = <-stream.Events():
+			}
+
+			if !ok {
+				if err := stream.Err(); err != nil {
+					sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: bedrockError(err)})
+				}
+				return
+			}
+
+			chunk, isChunk := event.(*types.ResponseStreamMemberChunk)
+			if !isChunk {
+				continue
+			}
+
+			streamEvents, err := codec.decodeChunk(chunk.Value.Bytes)
+			if err != nil {
+				sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: err})
+				return
+			}
+			if usage, found := streamUsage(chunk.Value.Bytes); found {
+				streamEvents = append(streamEvents, StreamEvent{Type: StreamEventUsage, TokenUsage: usage})
+			}
+
+			for _, streamEvent := range streamEvents {
+				if streamEvent.Type == StreamEventUsage {
+					if usageSent {
+						continue
+					}
+					usageSent = true
+					streamEvent.Provider = ProviderBedrock
+					streamEvent.Model = c.modelID
+				}
+				if !sendStreamEvent(ctx, events, streamEvent) {
+					return
+				}
+			}
+		}
+	}()
 
-	if err := json.Unmarshal(response.Body, &responseBody); err != nil {
-		return Response{}, fmt.Errorf("error unmarshaling response: %v", err)
-	}
+	return events, nil
+}
+
+// ImageRecognition sends images with optional text to Bedrock
+func (c *BedrockClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	// Images travel with their messages, so this is a regular completion
+	return c.TextCompletion(ctx, messages, config)
+}
 
-	// Format the standard response
-	result := Response{
-		Raw: responseBody,
-		TokenUsage: TokenUsage{
-			InputTokens:  responseBody.Usage.InputTokens,
-			OutputTokens: responseBody.Usage.OutputTokens,
-			TotalTokens:  responseBody.Usage.InputTokens + responseBody.Usage.OutputTokens,
-		},
+// CountTokens estimates prompt tokens locally, since Bedrock has no counting endpoint
+// for every model family
+func (c *BedrockClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	return EstimateTokens(messages, config), nil
+}
+
+// bedrockError translates an AWS SDK failure into an *Error
+func bedrockError(err error) error {
+	aiErr := newError(ProviderBedrock, err)
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		var header http.Header
+		if respErr.Response != nil && respErr.Response.Response != nil {
+			header = respErr.Response.Header
+		}
+		aiErr.setStatus(respErr.HTTPStatusCode(), header)
+		aiErr.RequestID = respErr.ServiceRequestID()
 	}
 
-	// Extract the text from the response
-	if len(responseBody.Output.Message.Content) > 0 {
-		result.Text = responseBody.Output.Message.Content[0].Text
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if message := apiErr.ErrorMessage(); message != "" {
+			aiErr.Message = message
+		}
+
+		switch apiErr.ErrorCode() {
+		case "ThrottlingException", "TooManyRequestsException":
+			aiErr.Category = ErrorCategoryRateLimited
+		case "ServiceQuotaExceededException":
+			aiErr.Category = ErrorCategoryQuota
+		case "AccessDeniedException", "UnrecognizedClientException", "ExpiredTokenException",
+			"InvalidSignatureException", "IncompleteSignature":
+			aiErr.Category = ErrorCategoryAuth
+		case "ValidationException", "ResourceNotFoundException":
+			aiErr.Category = ErrorCategoryInvalidRequest
+		case "ModelTimeoutException":
+			aiErr.Category = ErrorCategoryTimeout
+		case "InternalServerException", "ServiceUnavailableException", "ModelNotReadyException",
+			"ModelErrorException", "ModelStreamErrorException":
+			aiErr.Category = ErrorCategoryServer
+		}
 	}
 
-	return result, nil
+	aiErr.refine()
+	return aiErr
 }
 
 // Close releases any resources
@@ -0,0 +1,193 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBedrockTestClient points a Bedrock Converse client at handler with static credentials
+func newBedrockTestClient(t *testing.T, handler http.HandlerFunc, timeout int) *BedrockClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewBedrockClient()
+	err := client.Initialize(context.Background(), ClientOptions{
+		ModelID:     "anthropic.claude-3-haiku-20240307-v1:0",
+		Region:      "us-east-1",
+		AccessKey:   "AKIDTEST",
+		SecretKey:   "secret",
+		EndpointURL: server.URL,
+		BedrockAPI:  BedrockAPIConverse,
+		Timeout:     timeout,
+	})
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	return client
+}
+
+func TestBedrockEndpointURL(t *testing.T) {
+	var path string
+	client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{
+			"output": {"message": {"role": "assistant", "content": [{"text": "hello"}]}},
+			"stopReason": "end_turn",
+			"usage": {"inputTokens": 3, "outputTokens": 1, "totalTokens": 4}
+		}`)
+	}, 0)
+
+	response, err := client.TextCompletion(context.Background(), []InputMessage{{Role: RoleUser, Content: "hi"}}, ModelConfig{MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(path, "/converse") {
+		t.Errorf("request went to %q", path)
+	}
+	if response.Text != "hello" || response.TokenUsage.TotalTokens != 4 {
+		t.Errorf("unexpected response: %+v", response)
+	}
+}
+
+func TestBedrockTimeout(t *testing.T) {
+	release := make(chan struct{})
+	client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, 1)
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	_, err := client.TextCompletion(context.Background(), []InputMessage{{Role: RoleUser, Content: "hi"}}, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryTimeout) {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("request took %s despite a 1s timeout", elapsed)
+	}
+}
+
+func TestBedrockErrorClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		errorType  string
+		retryAfter string
+		want       ErrorCategory
+	}{
+		{"throttling", http.StatusTooManyRequests, "ThrottlingException", "3", ErrorCategoryRateLimited},
+		{"quota", http.StatusBadRequest, "ServiceQuotaExceededException", "", ErrorCategoryQuota},
+		{"access denied", http.StatusForbidden, "AccessDeniedException", "", ErrorCategoryAuth},
+		{"validation", http.StatusBadRequest, "ValidationException", "", ErrorCategoryInvalidRequest},
+		{"model timeout", http.StatusRequestTimeout, "ModelTimeoutException", "", ErrorCategoryTimeout},
+		{"unavailable", http.StatusServiceUnavailable, "ServiceUnavailableException", "", ErrorCategoryServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Amzn-ErrorType", tt.errorType)
+				w.Header().Set("X-Amzn-RequestId", "req-"+tt.name)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, `{"message": "failed: `+tt.name+`"}`)
+			}, 0)
+
+			_, err := client.TextCompletion(context.Background(), []InputMessage{{Role: RoleUser, Content: "hi"}}, ModelConfig{})
+			var aiErr *Error
+			if !errors.As(err, &aiErr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if aiErr.Category != tt.want || aiErr.StatusCode != tt.status || aiErr.Provider != ProviderBedrock {
+				t.Errorf("got %s (status %d, provider %s), want %s (status %d)", aiErr.Category, aiErr.StatusCode, aiErr.Provider, tt.want, tt.status)
+			}
+			if aiErr.RequestID != "req-"+tt.name {
+				t.Errorf("got request ID %q", aiErr.RequestID)
+			}
+			if tt.retryAfter == "3" && aiErr.RetryAfter != 3*time.Second {
+				t.Errorf("got RetryAfter %s, want 3s", aiErr.RetryAfter)
+			}
+			// The SDK retryer is capped at one attempt, so WithRetry owns retries
+			if requests != 1 {
+				t.Errorf("got %d requests, want 1", requests)
+			}
+		})
+	}
+}
+
+func TestBedrockCredentialOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    ClientOptions
+		wantErr bool
+	}{
+		{"static keys", ClientOptions{AccessKey: "AKIDTEST", SecretKey: "secret"}, false},
+		{"temporary keys", ClientOptions{AccessKey: "ASIATEST", SecretKey: "secret", SessionToken: "token"}, false},
+		{"access key only", ClientOptions{AccessKey: "AKIDTEST"}, true},
+		{"secret key only", ClientOptions{SecretKey: "secret"}, true},
+		{"token without keys", ClientOptions{SessionToken: "token"}, true},
+		{"keys and profile", ClientOptions{AccessKey: "AKIDTEST", SecretKey: "secret", Profile: "dev"}, true},
+	}
+
+	for _, tt := range tests {
+		tt.opts.Region = "us-east-1"
+		awsConfig, err := bedrockConfig(context.Background(), tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		creds, err := awsConfig.Credentials.Retrieve(context.Background())
+		if err != nil || creds.AccessKeyID != tt.opts.AccessKey || creds.SessionToken != tt.opts.SessionToken {
+			t.Errorf("%s: got credentials %+v, %v", tt.name, creds, err)
+		}
+	}
+}
+
+func TestBedrockContextWindowPreflight(t *testing.T) {
+	var requests int
+	client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"output": {"message": {"role": "assistant", "content": [{"text": "ok"}]}}, "stopReason": "end_turn"}`)
+	}, 0)
+
+	// claude-3-haiku has a 200k context window
+	tests := []struct {
+		name    string
+		words   int
+		wantErr bool
+	}{
+		{"fits", 1000, false},
+		{"estimate near the window", 210000, false},
+		{"too large", 400000, true},
+	}
+
+	for _, tt := range tests {
+		requests = 0
+		messages := []InputMessage{{Role: RoleUser, Content: strings.Repeat("word ", tt.words)}}
+		_, err := client.TextCompletion(context.Background(), messages, ModelConfig{})
+		if tt.wantErr {
+			if !errors.Is(err, ErrorCategoryContextLength) || requests != 0 {
+				t.Errorf("%s: got %v after %d requests, want a context length error before sending", tt.name, err, requests)
+			}
+		} else if err != nil || requests != 1 {
+			t.Errorf("%s: got %v after %d requests, want the request sent", tt.name, err, requests)
+		}
+	}
+}
@@ -0,0 +1,1036 @@
+package ai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// bedrockCodec translates between the standard types and one model family's native
+// InvokeModel payload. A codec is created per request, so it may keep state across
+// the chunks of a stream.
+type bedrockCodec interface {
+	// encode builds the request body, asking for a streamed response when stream is set
+	encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error)
+	// decode parses a complete response body
+	decode(body []byte) (Response, error)
+	// decodeChunk converts one streamed chunk into zero or more events
+	decodeChunk(chunk []byte) ([]StreamEvent, error)
+}
+
+// bedrockCodecs maps model ID fragments to codecs, most specific first. Families whose
+// chat support in Converse is limited default to InvokeModel. Families without a codec,
+// such as Llama 2 and Llama 4 with their own prompt templates, always use Converse.
+var bedrockCodecs = []struct {
+	family          string
+	invokeByDefault bool
+	newCodec        func() bedrockCodec
+}{
+	{"amazon.nova", true, func() bedrockCodec { return &novaCodec{} }},
+	{"anthropic.claude", false, func() bedrockCodec { return &claudeCodec{} }},
+	{"meta.llama3", false, func() bedrockCodec { return &llamaCodec{} }},
+	{"mistral.", false, func() bedrockCodec { return &mistralCodec{} }},
+	{"cohere.command-r", false, func() bedrockCodec { return &cohereChatCodec{} }},
+	{"cohere.command", true, func() bedrockCodec { return &cohereCodec{} }},
+	{"amazon.titan-text", true, func() bedrockCodec { return &titanCodec{} }},
+}
+
+// bedrockCodecFor finds the codec for a model ID, inference profile or ARN
+func bedrockCodecFor(modelID string) (newCodec func() bedrockCodec, invokeByDefault bool, ok bool) {
+	for _, entry := range bedrockCodecs {
+		if strings.Contains(modelID, entry.family) {
+			return entry.newCodec, entry.invokeByDefault, true
+		}
+	}
+	return nil, false, false
+}
+
+// bedrockDefaultMaxTokens is used for families that require an explicit output limit
+const bedrockDefaultMaxTokens = 1024
+
+// bedrockSystemPrompt merges the configured system prompt and system messages. InvokeModel
+// has no native structured output, so the schema is described in the system prompt as well.
+func bedrockSystemPrompt(messages []InputMessage, config ModelConfig) string {
+	var parts []string
+	if config.SystemPrompt != "" {
+		parts = append(parts, config.SystemPrompt)
+	}
+	for _, msg := range messages {
+		if msg.Role == RoleSystem && msg.Content != "" {
+			parts = append(parts, msg.Content)
+		}
+	}
+	if config.ResponseSchema != nil {
+		parts = append(parts, schemaInstruction(config.ResponseSchema))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// requireTextOnly rejects requests that a text-only payload format cannot express
+func requireTextOnly(family string, messages []InputMessage, config ModelConfig) error {
+	if len(config.Tools) > 0 {
+		return fmt.Errorf("%s models do not support tools through InvokeModel, use BedrockAPIConverse", family)
+	}
+	for _, msg := range messages {
+		if len(msg.Images) > 0 {
+			return fmt.Errorf("%s models do not support images through InvokeModel", family)
+		}
+		if msg.Role == RoleTool || len(msg.ToolCalls) > 0 {
+			return fmt.Errorf("%s models do not support tool messages through InvokeModel, use BedrockAPIConverse", family)
+		}
+	}
+	return nil
+}
+
+// textTranscript flattens a conversation into a plain prompt for completion-style models
+func textTranscript(messages []InputMessage, config ModelConfig, userLabel, assistantLabel string) string {
+	var b strings.Builder
+	if system := bedrockSystemPrompt(messages, config); system != "" {
+		b.WriteString(system + "\n\n")
+	}
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			continue
+		case RoleAssistant:
+			fmt.Fprintf(&b, "%s: %s\n", assistantLabel, msg.Content)
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", userLabel, msg.Content)
+		}
+	}
+	b.WriteString(assistantLabel + ":")
+	return b.String()
+}
+
+// toolInputSchema returns the parameters of a tool. Bedrock rejects a missing schema,
+// so tools without arguments get an empty object.
+func toolInputSchema(tool Tool) map[string]interface{} {
+	if tool.Parameters == nil {
+		return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	return tool.Parameters
+}
+
+// bedrockImageFormat returns the image format name Bedrock accepts, which has no "jpg"
+func bedrockImageFormat(img Image) string {
+	format := strings.ToLower(img.Format)
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+// marshalRequest encodes a request payload
+func marshalRequest(payload map[string]interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+// unmarshalResponse decodes a response body or stream chunk
+func unmarshalResponse(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error unmarshaling response: %w", err)
+	}
+	return nil
+}
+
+// newTokenUsage builds a usage record from input and output counts
+func newTokenUsage(input, output int) TokenUsage {
+	return TokenUsage{InputTokens: input, OutputTokens: output, TotalTokens: input + output}
+}
+
+// invocationMetrics is attached by Bedrock to the last chunk of every stream
+type invocationMetrics struct {
+	Metrics *struct {
+		InputTokenCount  int `json:"inputTokenCount"`
+		OutputTokenCount int `json:"outputTokenCount"`
+	} `json:"amazon-bedrock-invocationMetrics"`
+}
+
+// streamUsage reads the invocation metrics from a stream chunk, if present
+func streamUsage(chunk []byte) (TokenUsage, bool) {
+	var metrics invocationMetrics
+	if err := json.Unmarshal(chunk, &metrics); err != nil || metrics.Metrics == nil {
+		return TokenUsage{}, false
+	}
+	return newTokenUsage(metrics.Metrics.InputTokenCount, metrics.Metrics.OutputTokenCount), true
+}
+
+// headerUsage reads the token counts Bedrock reports in InvokeModel response headers,
+// for families whose response body carries no usage
+func headerUsage(metadata middleware.Metadata) TokenUsage {
+	raw, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response)
+	if !ok || raw == nil {
+		return TokenUsage{}
+	}
+	input, _ := strconv.Atoi(raw.Header.Get("X-Amzn-Bedrock-Input-Token-Count"))
+	output, _ := strconv.Atoi(raw.Header.Get("X-Amzn-Bedrock-Output-Token-Count"))
+	return newTokenUsage(input, output)
+}
+
+// novaCodec speaks the Amazon Nova messages-v1 format
+type novaCodec struct {
+	// Tool use blocks stream their input in fragments until the block stops
+	toolCall *ToolCall
+}
+
+func (c *novaCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	// Convert to Bedrock format messages
+	bedrockMessages := []map[string]interface{}{}
+
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			continue
+		}
+
+		role := msg.Role
+		content := []map[string]interface{}{}
+
+		for _, img := range msg.Images {
+			content = append(content, map[string]interface{}{
+				"image": map[string]interface{}{
+					"format": bedrockImageFormat(img),
+					"source": map[string]string{
+						"bytes": base64.StdEncoding.EncodeToString(img.Data),
+					},
+				},
+			})
+		}
+
+		if msg.Content != "" && msg.Role != RoleTool {
+			content = append(content, map[string]interface{}{"text": msg.Content})
+		}
+
+		// Tool calls made by the model in an earlier turn
+		for _, call := range msg.ToolCalls {
+			input := json.RawMessage(call.Arguments)
+			if call.Arguments == "" {
+				input = json.RawMessage("{}")
+			}
+			content = append(content, map[string]interface{}{
+				"toolUse": map[string]interface{}{
+					"toolUseId": call.ID,
+					"name":      call.Name,
+					"input":     input,
+				},
+			})
+		}
+
+		// Nova expects tool results inside a user turn
+		if msg.Role == RoleTool {
+			if msg.ToolResult == nil {
+				return nil, fmt.Errorf("tool message without a tool result")
+			}
+			role = RoleUser
+			content = append(content, map[string]interface{}{
+				"toolResult": map[string]interface{}{
+					"toolUseId": msg.ToolResult.ToolCallID,
+					"content": []map[string]string{
+						{"text": msg.ToolResult.Content},
+					},
+				},
+			})
+		}
+
+		// Nova requires alternating roles, so consecutive turns are merged
+		if last := len(bedrockMessages) - 1; last >= 0 && bedrockMessages[last]["role"] == role {
+			bedrockMessages[last]["content"] = append(bedrockMessages[last]["content"].([]map[string]interface{}), content...)
+			continue
+		}
+		bedrockMessages = append(bedrockMessages, map[string]interface{}{
+			"role":    role,
+			"content": content,
+		})
+	}
+
+	inferenceConfig := map[string]interface{}{
+		"maxTokens":   config.MaxTokens,
+		"topP":        config.TopP,
+		"topK":        config.TopK,
+		"temperature": config.Temperature,
+	}
+	if len(config.StopSequences) > 0 {
+		inferenceConfig["stopSequences"] = config.StopSequences
+	}
+
+	// Create request payload
+	requestPayload := map[string]interface{}{
+		"schemaVersion":   "messages-v1",
+		"messages":        bedrockMessages,
+		"inferenceConfig": inferenceConfig,
+	}
+	if system := bedrockSystemPrompt(messages, config); system != "" {
+		requestPayload["system"] = []map[string]string{
+			{"text": system},
+		}
+	}
+
+	// Add tool definitions if provided
+	if len(config.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(config.Tools))
+		for _, tool := range config.Tools {
+			tools = append(tools, map[string]interface{}{
+				"toolSpec": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"inputSchema": map[string]interface{}{
+						"json": toolInputSchema(tool),
+					},
+				},
+			})
+		}
+		requestPayload["toolConfig"] = map[string]interface{}{
+			"tools": tools,
+		}
+	}
+
+	return marshalRequest(requestPayload)
+}
+
+// novaResponse is the body of a Nova InvokeModel response
+type novaResponse struct {
+	Output struct {
+		Message struct {
+			Content []struct {
+				Text    string `json:"text"`
+				ToolUse *struct {
+					ToolUseID string          `json:"toolUseId"`
+					Name      string          `json:"name"`
+					Input     json.RawMessage `json:"input"`
+				} `json:"toolUse"`
+			} `json:"content"`
+		} `json:"message"`
+	} `json:"output"`
+	StopReason string `json:"stopReason"`
+	Usage      struct {
+		InputTokens  int `json:"inputTokens"`
+		OutputTokens int `json:"outputTokens"`
+	} `json:"usage"`
+}
+
+func (c *novaCodec) decode(body []byte) (Response, error) {
+	var responseBody novaResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	result := Response{
+		Raw:          responseBody,
+		FinishReason: responseBody.StopReason,
+		TokenUsage:   newTokenUsage(responseBody.Usage.InputTokens, responseBody.Usage.OutputTokens),
+	}
+
+	// Extract the text and tool calls from the response
+	for _, content := range responseBody.Output.Message.Content {
+		result.Text += content.Text
+		if content.ToolUse != nil {
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:        content.ToolUse.ToolUseID,
+				Name:      content.ToolUse.Name,
+				Arguments: string(content.ToolUse.Input),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// novaStreamChunk is a single chunk of a Nova InvokeModelWithResponseStream response
+type novaStreamChunk struct {
+	ContentBlockStart *struct {
+		Start struct {
+			ToolUse *struct {
+				ToolUseID string `json:"toolUseId"`
+				Name      string `json:"name"`
+			} `json:"toolUse"`
+		} `json:"start"`
+	} `json:"contentBlockStart"`
+	ContentBlockDelta *struct {
+		Delta struct {
+			Text    string `json:"text"`
+			ToolUse *struct {
+				Input string `json:"input"`
+			} `json:"toolUse"`
+		} `json:"delta"`
+	} `json:"contentBlockDelta"`
+	ContentBlockStop *struct{} `json:"contentBlockStop"`
+	MessageStop      *struct {
+		StopReason string `json:"stopReason"`
+	} `json:"messageStop"`
+	Metadata *struct {
+		Usage struct {
+			InputTokens  int `json:"inputTokens"`
+			OutputTokens int `json:"outputTokens"`
+		} `json:"usage"`
+	} `json:"metadata"`
+}
+
+func (c *novaCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload novaStreamChunk
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case payload.ContentBlockStart != nil && payload.ContentBlockStart.Start.ToolUse != nil:
+		c.toolCall = &ToolCall{
+			ID:   payload.ContentBlockStart.Start.ToolUse.ToolUseID,
+			Name: payload.ContentBlockStart.Start.ToolUse.Name,
+		}
+	case payload.ContentBlockDelta != nil && payload.ContentBlockDelta.Delta.ToolUse != nil:
+		if c.toolCall != nil {
+			c.toolCall.Arguments += payload.ContentBlockDelta.Delta.ToolUse.Input
+		}
+	case payload.ContentBlockDelta != nil && payload.ContentBlockDelta.Delta.Text != "":
+		return []StreamEvent{{Type: StreamEventText, Text: payload.ContentBlockDelta.Delta.Text}}, nil
+	case payload.ContentBlockStop != nil && c.toolCall != nil:
+		call := c.toolCall
+		c.toolCall = nil
+		return []StreamEvent{{Type: StreamEventToolCall, ToolCall: call}}, nil
+	case payload.MessageStop != nil:
+		return []StreamEvent{{Type: StreamEventFinish, FinishReason: payload.MessageStop.StopReason}}, nil
+	case payload.Metadata != nil:
+		usage := payload.Metadata.Usage
+		return []StreamEvent{{Type: StreamEventUsage, TokenUsage: newTokenUsage(usage.InputTokens, usage.OutputTokens)}}, nil
+	}
+
+	return nil, nil
+}
+
+// claudeCodec speaks the Anthropic Messages format
+type claudeCodec struct {
+	inputTokens int
+	toolCall    *ToolCall
+}
+
+func (c *claudeCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	claudeMessages := []map[string]interface{}{}
+
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			continue
+		}
+
+		// Tool results are sent in user turns
+		role := RoleUser
+		if msg.Role == RoleAssistant {
+			role = RoleAssistant
+		}
+
+		content := []map[string]interface{}{}
+
+		for _, img := range msg.Images {
+			content = append(content, map[string]interface{}{
+				"type": "image",
+				"source": map[string]string{
+					"type":       "base64",
+					"media_type": img.MIMEType(),
+					"data":       base64.StdEncoding.EncodeToString(img.Data),
+				},
+			})
+		}
+
+		if msg.Content != "" && msg.Role != RoleTool {
+			content = append(content, map[string]interface{}{"type": "text", "text": msg.Content})
+		}
+
+		// Tool calls made by the model in an earlier turn
+		for _, call := range msg.ToolCalls {
+			input := json.RawMessage(call.Arguments)
+			if call.Arguments == "" {
+				input = json.RawMessage("{}")
+			}
+			content = append(content, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    call.ID,
+				"name":  call.Name,
+				"input": input,
+			})
+		}
+
+		if msg.Role == RoleTool {
+			if msg.ToolResult == nil {
+				return nil, fmt.Errorf("tool message without a tool result")
+			}
+			content = append(content, map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolResult.ToolCallID,
+				"content":     msg.ToolResult.Content,
+				"is_error":    msg.ToolResult.IsError,
+			})
+		}
+
+		if len(content) == 0 {
+			continue
+		}
+
+		// Claude requires alternating roles, so consecutive turns are merged
+		if last := len(claudeMessages) - 1; last >= 0 && claudeMessages[last]["role"] == role {
+			claudeMessages[last]["content"] = append(claudeMessages[last]["content"].([]map[string]interface{}), content...)
+			continue
+		}
+		claudeMessages = append(claudeMessages, map[string]interface{}{
+			"role":    role,
+			"content": content,
+		})
+	}
+
+	// Claude requires an explicit output limit
+	maxTokens := config.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = bedrockDefaultMaxTokens
+	}
+
+	requestPayload := map[string]interface{}{
+		"anthropic_version": "bedrock-2023-05-31",
+		"messages":          claudeMessages,
+		"max_tokens":        maxTokens,
+	}
+	if system := bedrockSystemPrompt(messages, config); system != "" {
+		requestPayload["system"] = system
+	}
+	requestPayload["temperature"] = config.Temperature
+	if config.TopP > 0 {
+		requestPayload["top_p"] = config.TopP
+	}
+	if config.TopK > 0 {
+		requestPayload["top_k"] = config.TopK
+	}
+	if len(config.StopSequences) > 0 {
+		requestPayload["stop_sequences"] = config.StopSequences
+	}
+
+	// Add tool definitions if provided
+	if len(config.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(config.Tools))
+		for _, tool := range config.Tools {
+			tools = append(tools, map[string]interface{}{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": toolInputSchema(tool),
+			})
+		}
+		requestPayload["tools"] = tools
+	}
+
+	return marshalRequest(requestPayload)
+}
+
+// claudeResponse is the body of an Anthropic Messages response
+type claudeResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		ID    string          `json:"id"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (c *claudeCodec) decode(body []byte) (Response, error) {
+	var responseBody claudeResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	result := Response{
+		Raw:          responseBody,
+		FinishReason: responseBody.StopReason,
+		TokenUsage:   newTokenUsage(responseBody.Usage.InputTokens, responseBody.Usage.OutputTokens),
+	}
+
+	for _, content := range responseBody.Content {
+		switch content.Type {
+		case "text":
+			result.Text += content.Text
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:        content.ID,
+				Name:      content.Name,
+				Arguments: string(content.Input),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// claudeStreamChunk is a single Anthropic Messages stream event
+type claudeStreamChunk struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (c *claudeCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload claudeStreamChunk
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+
+	switch payload.Type {
+	case "message_start":
+		c.inputTokens = payload.Message.Usage.InputTokens
+	case "content_block_start":
+		if payload.ContentBlock.Type == "tool_use" {
+			c.toolCall = &ToolCall{ID: payload.ContentBlock.ID, Name: payload.ContentBlock.Name}
+		}
+	case "content_block_delta":
+		switch payload.Delta.Type {
+		case "text_delta":
+			if payload.Delta.Text != "" {
+				return []StreamEvent{{Type: StreamEventText, Text: payload.Delta.Text}}, nil
+			}
+		case "input_json_delta":
+			if c.toolCall != nil {
+				c.toolCall.Arguments += payload.Delta.PartialJSON
+			}
+		}
+	case "content_block_stop":
+		if c.toolCall != nil {
+			call := c.toolCall
+			c.toolCall = nil
+			return []StreamEvent{{Type: StreamEventToolCall, ToolCall: call}}, nil
+		}
+	case "message_delta":
+		return []StreamEvent{
+			{Type: StreamEventFinish, FinishReason: payload.Delta.StopReason},
+			{Type: StreamEventUsage, TokenUsage: newTokenUsage(c.inputTokens, payload.Usage.OutputTokens)},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// llamaCodec speaks the Meta Llama 3 prompt format, shared by Llama 3.1, 3.2 and 3.3
+type llamaCodec struct{}
+
+func (c *llamaCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	if err := requireTextOnly("Llama", messages, config); err != nil {
+		return nil, err
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("<|begin_of_text|>")
+	writeTurn := func(role, content string) {
+		fmt.Fprintf(&prompt, "<|start_header_id|>%s<|end_header_id|>\n\n%s<|eot_id|>", role, content)
+	}
+	if system := bedrockSystemPrompt(messages, config); system != "" {
+		writeTurn("system", system)
+	}
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			continue
+		case RoleAssistant:
+			writeTurn("assistant", msg.Content)
+		default:
+			writeTurn("user", msg.Content)
+		}
+	}
+	prompt.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+
+	requestPayload := map[string]interface{}{
+		"prompt": prompt.String(),
+	}
+	if config.MaxTokens > 0 {
+		requestPayload["max_gen_len"] = config.MaxTokens
+	}
+	requestPayload["temperature"] = config.Temperature
+	if config.TopP > 0 {
+		requestPayload["top_p"] = config.TopP
+	}
+
+	return marshalRequest(requestPayload)
+}
+
+// llamaResponse is the body of a Llama response and of each of its stream chunks
+type llamaResponse struct {
+	Generation           string `json:"generation"`
+	PromptTokenCount     int    `json:"prompt_token_count"`
+	GenerationTokenCount int    `json:"generation_token_count"`
+	StopReason           string `json:"stop_reason"`
+}
+
+func (c *llamaCodec) decode(body []byte) (Response, error) {
+	var responseBody llamaResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Text:         responseBody.Generation,
+		Raw:          responseBody,
+		FinishReason: responseBody.StopReason,
+		TokenUsage:   newTokenUsage(responseBody.PromptTokenCount, responseBody.GenerationTokenCount),
+	}, nil
+}
+
+func (c *llamaCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload llamaResponse
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+	return textChunkEvents(payload.Generation, payload.StopReason), nil
+}
+
+// textChunkEvents builds the events for a stream chunk carrying text and an optional finish reason
+func textChunkEvents(text, finishReason string) []StreamEvent {
+	var events []StreamEvent
+	if text != "" {
+		events = append(events, StreamEvent{Type: StreamEventText, Text: text})
+	}
+	if finishReason != "" {
+		events = append(events, StreamEvent{Type: StreamEventFinish, FinishReason: finishReason})
+	}
+	return events
+}
+
+// mistralCodec speaks the Mistral instruct prompt format
+type mistralCodec struct{}
+
+func (c *mistralCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	if err := requireTextOnly("Mistral", messages, config); err != nil {
+		return nil, err
+	}
+
+	// The system prompt has no turn of its own and is prefixed to the first instruction
+	system := bedrockSystemPrompt(messages, config)
+
+	var prompt strings.Builder
+	prompt.WriteString("<s>")
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			continue
+		case RoleAssistant:
+			fmt.Fprintf(&prompt, " %s</s>", msg.Content)
+		default:
+			content := msg.Content
+			if system != "" {
+				content = system + "\n\n" + content
+				system = ""
+			}
+			fmt.Fprintf(&prompt, "[INST] %s [/INST]", content)
+		}
+	}
+
+	requestPayload := map[string]interface{}{
+		"prompt": prompt.String(),
+	}
+	if config.MaxTokens > 0 {
+		requestPayload["max_tokens"] = config.MaxTokens
+	}
+	requestPayload["temperature"] = config.Temperature
+	if config.TopP > 0 {
+		requestPayload["top_p"] = config.TopP
+	}
+	if config.TopK > 0 {
+		requestPayload["top_k"] = config.TopK
+	}
+	if len(config.StopSequences) > 0 {
+		requestPayload["stop"] = config.StopSequences
+	}
+
+	return marshalRequest(requestPayload)
+}
+
+// mistralResponse is the body of a Mistral response and of each of its stream chunks
+type mistralResponse struct {
+	Outputs []struct {
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"outputs"`
+}
+
+func (c *mistralCodec) decode(body []byte) (Response, error) {
+	var responseBody mistralResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	result := Response{Raw: responseBody}
+	for _, output := range responseBody.Outputs {
+		result.Text += output.Text
+		if output.StopReason != "" {
+			result.FinishReason = output.StopReason
+		}
+	}
+
+	return result, nil
+}
+
+func (c *mistralCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload mistralResponse
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+
+	var events []StreamEvent
+	for _, output := range payload.Outputs {
+		events = append(events, textChunkEvents(output.Text, output.StopReason)...)
+	}
+	return events, nil
+}
+
+// cohereCodec speaks the Cohere Command generate format
+type cohereCodec struct{}
+
+func (c *cohereCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	if err := requireTextOnly("Cohere Command", messages, config); err != nil {
+		return nil, err
+	}
+
+	requestPayload := map[string]interface{}{
+		"prompt": textTranscript(messages, config, "User", "Chatbot"),
+		"stream": stream,
+	}
+	if config.MaxTokens > 0 {
+		requestPayload["max_tokens"] = config.MaxTokens
+	}
+	requestPayload["temperature"] = config.Temperature
+	if config.TopP > 0 {
+		requestPayload["p"] = config.TopP
+	}
+	if config.TopK > 0 {
+		requestPayload["k"] = config.TopK
+	}
+	if len(config.StopSequences) > 0 {
+		requestPayload["stop_sequences"] = config.StopSequences
+	}
+
+	return marshalRequest(requestPayload)
+}
+
+// cohereResponse is the body of a Cohere Command generate response
+type cohereResponse struct {
+	Generations []struct {
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"generations"`
+}
+
+func (c *cohereCodec) decode(body []byte) (Response, error) {
+	var responseBody cohereResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	result := Response{Raw: responseBody}
+	if len(responseBody.Generations) > 0 {
+		result.Text = responseBody.Generations[0].Text
+		result.FinishReason = responseBody.Generations[0].FinishReason
+	}
+
+	return result, nil
+}
+
+// cohereStreamChunk is a single chunk of a Cohere Command generate stream
+type cohereStreamChunk struct {
+	Text         string `json:"text"`
+	IsFinished   bool   `json:"is_finished"`
+	FinishReason string `json:"finish_reason"`
+}
+
+func (c *cohereCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload cohereStreamChunk
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+
+	// Only the last chunk carries a finish reason
+	finishReason := ""
+	if payload.IsFinished {
+		finishReason = payload.FinishReason
+		if finishReason == "" {
+			finishReason = "COMPLETE"
+		}
+	}
+	return textChunkEvents(payload.Text, finishReason), nil
+}
+
+// cohereChatCodec speaks the Cohere Command R chat format
+type cohereChatCodec struct{}
+
+func (c *cohereChatCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	if err := requireTextOnly("Cohere Command R", messages, config); err != nil {
+		return nil, err
+	}
+
+	var turns []InputMessage
+	for _, msg := range messages {
+		if msg.Role != RoleSystem {
+			turns = append(turns, msg)
+		}
+	}
+	if len(turns) == 0 || turns[len(turns)-1].Role != RoleUser {
+		return nil, fmt.Errorf("the last message must have the user role")
+	}
+
+	// Earlier turns go into the chat history and the last one is the new message
+	history := []map[string]string{}
+	for _, msg := range turns[:len(turns)-1] {
+		role := "USER"
+		if msg.Role == RoleAssistant {
+			role = "CHATBOT"
+		}
+		history = append(history, map[string]string{"role": role, "message": msg.Content})
+	}
+
+	requestPayload := map[string]interface{}{
+		"message":      turns[len(turns)-1].Content,
+		"chat_history": history,
+	}
+	if system := bedrockSystemPrompt(messages, config); system != "" {
+		requestPayload["preamble"] = system
+	}
+	if config.MaxTokens > 0 {
+		requestPayload["max_tokens"] = config.MaxTokens
+	}
+	requestPayload["temperature"] = config.Temperature
+	if config.TopP > 0 {
+		requestPayload["p"] = config.TopP
+	}
+	if config.TopK > 0 {
+		requestPayload["k"] = config.TopK
+	}
+	if len(config.StopSequences) > 0 {
+		requestPayload["stop_sequences"] = config.StopSequences
+	}
+
+	return marshalRequest(requestPayload)
+}
+
+// cohereChatResponse is the body of a Command R response and of each of its stream chunks
+type cohereChatResponse struct {
+	EventType    string `json:"event_type"`
+	Text         string `json:"text"`
+	FinishReason string `json:"finish_reason"`
+}
+
+func (c *cohereChatCodec) decode(body []byte) (Response, error) {
+	var responseBody cohereChatResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Text:         responseBody.Text,
+		Raw:          responseBody,
+		FinishReason: responseBody.FinishReason,
+	}, nil
+}
+
+func (c *cohereChatCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload cohereChatResponse
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+
+	switch payload.EventType {
+	case "text-generation":
+		return textChunkEvents(payload.Text, ""), nil
+	case "stream-end":
+		return textChunkEvents("", payload.FinishReason), nil
+	}
+	return nil, nil
+}
+
+// titanCodec speaks the Amazon Titan Text format
+type titanCodec struct{}
+
+func (c *titanCodec) encode(messages []InputMessage, config ModelConfig, stream bool) ([]byte, error) {
+	if err := requireTextOnly("Titan Text", messages, config); err != nil {
+		return nil, err
+	}
+
+	generationConfig := map[string]interface{}{}
+	if config.MaxTokens > 0 {
+		generationConfig["maxTokenCount"] = config.MaxTokens
+	}
+	generationConfig["temperature"] = config.Temperature
+	if config.TopP > 0 {
+		generationConfig["topP"] = config.TopP
+	}
+	if len(config.StopSequences) > 0 {
+		generationConfig["stopSequences"] = config.StopSequences
+	}
+
+	return marshalRequest(map[string]interface{}{
+		"inputText":            textTranscript(messages, config, "User", "Bot"),
+		"textGenerationConfig": generationConfig,
+	})
+}
+
+// titanResponse is the body of a Titan Text response
+type titanResponse struct {
+	InputTextTokenCount int `json:"inputTextTokenCount"`
+	Results             []struct {
+		TokenCount       int    `json:"tokenCount"`
+		OutputText       string `json:"outputText"`
+		CompletionReason string `json:"completionReason"`
+	} `json:"results"`
+}
+
+func (c *titanCodec) decode(body []byte) (Response, error) {
+	var responseBody titanResponse
+	if err := unmarshalResponse(body, &responseBody); err != nil {
+		return Response{}, err
+	}
+
+	result := Response{Raw: responseBody}
+	outputTokens := 0
+	for _, output := range responseBody.Results {
+		result.Text += output.OutputText
+		result.FinishReason = output.CompletionReason
+		outputTokens += output.TokenCount
+	}
+	result.TokenUsage = newTokenUsage(responseBody.InputTextTokenCount, outputTokens)
+
+	return result, nil
+}
+
+// titanStreamChunk is a single chunk of a Titan Text stream
+type titanStreamChunk struct {
+	OutputText       string `json:"outputText"`
+	CompletionReason string `json:"completionReason"`
+}
+
+func (c *titanCodec) decodeChunk(chunk []byte) ([]StreamEvent, error) {
+	var payload titanStreamChunk
+	if err := unmarshalResponse(chunk, &payload); err != nil {
+		return nil, err
+	}
+	return textChunkEvents(payload.OutputText, payload.CompletionReason), nil
+}
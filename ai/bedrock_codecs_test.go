@@ -0,0 +1,307 @@
+package ai
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBedrockCodecFor(t *testing.T) {
+	tests := []struct {
+		modelID         string
+		codec           bedrockCodec
+		invokeByDefault bool
+	}{
+		{"amazon.nova-pro-v1:0", &novaCodec{}, true},
+		{"us.amazon.nova-lite-v1:0", &novaCodec{}, true},
+		{"anthropic.claude-3-haiku-20240307-v1:0", &claudeCodec{}, false},
+		{"meta.llama3-8b-instruct-v1:0", &llamaCodec{}, false},
+		{"us.meta.llama3-1-70b-instruct-v1:0", &llamaCodec{}, false},
+		{"meta.llama2-13b-chat-v1", nil, false},
+		{"meta.llama4-scout-17b-instruct-v1:0", nil, false},
+		{"mistral.mistral-7b-instruct-v0:2", &mistralCodec{}, false},
+		{"cohere.command-r-plus-v1:0", &cohereChatCodec{}, false},
+		{"cohere.command-text-v14", &cohereCodec{}, true},
+		{"amazon.titan-text-express-v1", &titanCodec{}, true},
+		{"ai21.jamba-instruct-v1:0", nil, false},
+	}
+
+	for _, tt := range tests {
+		newCodec, invokeByDefault, ok := bedrockCodecFor(tt.modelID)
+		if ok != (tt.codec != nil) {
+			t.Errorf("%s: got codec %v, want %v", tt.modelID, ok, tt.codec != nil)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got := newCodec(); reflect.TypeOf(got) != reflect.TypeOf(tt.codec) {
+			t.Errorf("%s: got %T, want %T", tt.modelID, got, tt.codec)
+		}
+		if invokeByDefault != tt.invokeByDefault {
+			t.Errorf("%s: got invokeByDefault %v", tt.modelID, invokeByDefault)
+		}
+	}
+}
+
+func TestBedrockImageFormat(t *testing.T) {
+	for format, want := range map[string]string{"jpg": "jpeg", "JPG": "jpeg", "jpeg": "jpeg", "PNG": "png", "webp": "webp"} {
+		if got := bedrockImageFormat(Image{Format: format}); got != want {
+			t.Errorf("%s: got %s, want %s", format, got, want)
+		}
+	}
+}
+
+// encodePayload encodes a request with codec and decodes it into a generic map
+func encodePayload(t *testing.T, codec bedrockCodec, messages []InputMessage, config ModelConfig) map[string]interface{} {
+	t.Helper()
+
+	body, err := codec.encode(messages, config, false)
+	if err != nil {
+		t.Fatalf("%T: encode: %v", codec, err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("%T: invalid JSON: %v", codec, err)
+	}
+	return payload
+}
+
+func TestNovaEncodeImage(t *testing.T) {
+	messages := []InputMessage{{Role: RoleUser, Content: "what is this?", Images: []Image{{Data: []byte{1, 2, 3}, Format: "jpg"}}}}
+	payload := encodePayload(t, &novaCodec{}, messages, ModelConfig{})
+
+	content := payload["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+	image := content[0].(map[string]interface{})["image"].(map[string]interface{})
+	if image["format"] != "jpeg" {
+		t.Errorf("got image format %v, want jpeg", image["format"])
+	}
+	if content[1].(map[string]interface{})["text"] != "what is this?" {
+		t.Errorf("unexpected content: %v", content)
+	}
+}
+
+func TestCodecsSendZeroTemperature(t *testing.T) {
+	messages := []InputMessage{{Role: RoleUser, Content: "hi"}}
+	tests := []struct {
+		codec bedrockCodec
+		path  []string
+	}{
+		{&claudeCodec{}, []string{"temperature"}},
+		{&llamaCodec{}, []string{"temperature"}},
+		{&mistralCodec{}, []string{"temperature"}},
+		{&cohereCodec{}, []string{"temperature"}},
+		{&cohereChatCodec{}, []string{"temperature"}},
+		{&titanCodec{}, []string{"textGenerationConfig", "temperature"}},
+		{&novaCodec{}, []string{"inferenceConfig", "temperature"}},
+	}
+
+	for _, tt := range tests {
+		var value interface{} = encodePayload(t, tt.codec, messages, ModelConfig{Temperature: 0})
+		for _, key := range tt.path {
+			value = value.(map[string]interface{})[key]
+		}
+		if value != 0.0 {
+			t.Errorf("%T: got temperature %v, want 0", tt.codec, value)
+		}
+	}
+}
+
+func TestLlamaEncodePrompt(t *testing.T) {
+	messages := []InputMessage{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Hi"},
+		{Role: RoleAssistant, Content: "Hello"},
+		{Role: RoleUser, Content: "Bye"},
+	}
+	payload := encodePayload(t, &llamaCodec{}, messages, ModelConfig{MaxTokens: 32})
+
+	want := "<|begin_of_text|>" +
+		"<|start_header_id|>system<|end_header_id|>\n\nBe brief.<|eot_id|>" +
+		"<|start_header_id|>user<|end_header_id|>\n\nHi<|eot_id|>" +
+		"<|start_header_id|>assistant<|end_header_id|>\n\nHello<|eot_id|>" +
+		"<|start_header_id|>user<|end_header_id|>\n\nBye<|eot_id|>" +
+		"<|start_header_id|>assistant<|end_header_id|>\n\n"
+	if payload["prompt"] != want {
+		t.Errorf("got prompt %q", payload["prompt"])
+	}
+	if payload["max_gen_len"] != 32.0 {
+		t.Errorf("got max_gen_len %v", payload["max_gen_len"])
+	}
+}
+
+func TestTextOnlyCodecsRejectImagesAndTools(t *testing.T) {
+	withImage := []InputMessage{{Role: RoleUser, Content: "hi", Images: []Image{{Data: []byte{1}, Format: "png"}}}}
+	withTool := ModelConfig{Tools: []Tool{{Name: "lookup"}}}
+
+	for _, codec := range []bedrockCodec{&llamaCodec{}, &mistralCodec{}, &cohereCodec{}, &cohereChatCodec{}, &titanCodec{}} {
+		if _, err := codec.encode(withImage, ModelConfig{}, false); err == nil {
+			t.Errorf("%T: accepted an image", codec)
+		}
+		if _, err := codec.encode([]InputMessage{{Role: RoleUser, Content: "hi"}}, withTool, false); err == nil {
+			t.Errorf("%T: accepted a tool", codec)
+		}
+	}
+}
+
+func TestClaudeEncodeMergesToolResults(t *testing.T) {
+	messages := []InputMessage{
+		{Role: RoleUser, Content: "weather?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "t1", Name: "weather", Arguments: `{"city":"Oslo"}`}}},
+		{Role: RoleTool, ToolResult: &ToolResult{ToolCallID: "t1", Content: "rain"}},
+		{Role: RoleUser, Content: "thanks"},
+	}
+	payload := encodePayload(t, &claudeCodec{}, messages, ModelConfig{})
+
+	turns := payload["messages"].([]interface{})
+	if len(turns) != 3 {
+		t.Fatalf("got %d turns, want 3 with the tool result merged into the last user turn", len(turns))
+	}
+	last := turns[2].(map[string]interface{})["content"].([]interface{})
+	if last[0].(map[string]interface{})["type"] != "tool_result" || last[1].(map[string]interface{})["text"] != "thanks" {
+		t.Errorf("unexpected last turn: %v", last)
+	}
+	if payload["max_tokens"] != float64(bedrockDefaultMaxTokens) {
+		t.Errorf("got max_tokens %v", payload["max_tokens"])
+	}
+}
+
+func TestCodecDecode(t *testing.T) {
+	tests := []struct {
+		codec  bedrockCodec
+		body   string
+		text   string
+		finish string
+		usage  int
+	}{
+		{&novaCodec{}, `{"output": {"message": {"content": [{"text": "hi"}]}}, "stopReason": "end_turn", "usage": {"inputTokens": 2, "outputTokens": 1}}`, "hi", "end_turn", 3},
+		{&claudeCodec{}, `{"content": [{"type": "text", "text": "hi"}], "stop_reason": "end_turn", "usage": {"input_tokens": 2, "output_tokens": 1}}`, "hi", "end_turn", 3},
+		{&llamaCodec{}, `{"generation": "hi", "prompt_token_count": 2, "generation_token_count": 1, "stop_reason": "stop"}`, "hi", "stop", 3},
+		{&mistralCodec{}, `{"outputs": [{"text": "hi", "stop_reason": "stop"}]}`, "hi", "stop", 0},
+		{&cohereCodec{}, `{"generations": [{"text": "hi", "finish_reason": "COMPLETE"}]}`, "hi", "COMPLETE", 0},
+		{&cohereChatCodec{}, `{"text": "hi", "finish_reason": "COMPLETE"}`, "hi", "COMPLETE", 0},
+		{&titanCodec{}, `{"inputTextTokenCount": 2, "results": [{"outputText": "hi", "tokenCount": 1, "completionReason": "FINISH"}]}`, "hi", "FINISH", 3},
+	}
+
+	for _, tt := range tests {
+		response, err := tt.codec.decode([]byte(tt.body))
+		if err != nil {
+			t.Errorf("%T: %v", tt.codec, err)
+			continue
+		}
+		if response.Text != tt.text || response.FinishReason != tt.finish || response.TokenUsage.TotalTokens != tt.usage {
+			t.Errorf("%T: got %q, %q, %d tokens", tt.codec, response.Text, response.FinishReason, response.TokenUsage.TotalTokens)
+		}
+	}
+
+	if _, err := (&claudeCodec{}).decode([]byte("not json")); err == nil {
+		t.Error("decoded invalid JSON")
+	}
+}
+
+// decodeChunks feeds stream chunks to a codec and collects the events
+func decodeChunks(t *testing.T, codec bedrockCodec, chunks ...string) Response {
+	t.Helper()
+
+	events := make(chan StreamEvent, 100)
+	for _, chunk := range chunks {
+		decoded, err := codec.decodeChunk([]byte(chunk))
+		if err != nil {
+			t.Fatalf("%T: %v", codec, err)
+		}
+		for _, event := range decoded {
+			events <- event
+		}
+	}
+	close(events)
+
+	response, err := CollectStream(events)
+	if err != nil {
+		t.Fatalf("%T: %v", codec, err)
+	}
+	return response
+}
+
+func TestClaudeDecodeChunks(t *testing.T) {
+	response := decodeChunks(t, &claudeCodec{},
+		`{"type": "message_start", "message": {"usage": {"input_tokens": 5}}}`,
+		`{"type": "content_block_start", "content_block": {"type": "text"}}`,
+		`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Let me check. "}}`,
+		`{"type": "content_block_stop"}`,
+		`{"type": "content_block_start", "content_block": {"type": "tool_use", "id": "t1", "name": "weather"}}`,
+		`{"type": "content_block_delta", "delta": {"type": "input_json_delta", "partial_json": "{\"city\":"}}`,
+		`{"type": "content_block_delta", "delta": {"type": "input_json_delta", "partial_json": "\"Oslo\"}"}}`,
+		`{"type": "content_block_stop"}`,
+		`{"type": "message_delta", "delta": {"stop_reason": "tool_use"}, "usage": {"output_tokens": 7}}`,
+	)
+
+	if response.Text != "Let me check. " || response.FinishReason != "tool_use" || response.TokenUsage.TotalTokens != 12 {
+		t.Errorf("got %q, %q, %d tokens", response.Text, response.FinishReason, response.TokenUsage.TotalTokens)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].Arguments != `{"city":"Oslo"}` {
+		t.Errorf("got tool calls %+v", response.ToolCalls)
+	}
+}
+
+func TestNovaDecodeChunks(t *testing.T) {
+	response := decodeChunks(t, &novaCodec{},
+		`{"contentBlockDelta": {"delta": {"text": "Hel"}}}`,
+		`{"contentBlockDelta": {"delta": {"text": "lo"}}}`,
+		`{"contentBlockStart": {"start": {"toolUse": {"toolUseId": "t1", "name": "lookup"}}}}`,
+		`{"contentBlockDelta": {"delta": {"toolUse": {"input": "{}"}}}}`,
+		`{"contentBlockStop": {}}`,
+		`{"messageStop": {"stopReason": "tool_use"}}`,
+		`{"metadata": {"usage": {"inputTokens": 3, "outputTokens": 2}}}`,
+	)
+
+	if response.Text != "Hello" || response.FinishReason != "tool_use" || response.TokenUsage.TotalTokens != 5 {
+		t.Errorf("got %q, %q, %d tokens", response.Text, response.FinishReason, response.TokenUsage.TotalTokens)
+	}
+	if len(response.ToolCalls) != 1 || response.ToolCalls[0].Name != "lookup" {
+		t.Errorf("got tool calls %+v", response.ToolCalls)
+	}
+}
+
+func TestTextCodecsDecodeChunks(t *testing.T) {
+	tests := []struct {
+		codec  bedrockCodec
+		chunks []string
+		finish string
+	}{
+		{&llamaCodec{}, []string{`{"generation": "a"}`, `{"generation": "b", "stop_reason": "stop"}`}, "stop"},
+		{&mistralCodec{}, []string{`{"outputs": [{"text": "a"}]}`, `{"outputs": [{"text": "b", "stop_reason": "stop"}]}`}, "stop"},
+		{&cohereCodec{}, []string{`{"text": "a"}`, `{"text": "b"}`, `{"is_finished": true}`}, "COMPLETE"},
+		{&cohereChatCodec{}, []string{`{"event_type": "stream-start"}`, `{"event_type": "text-generation", "text": "a"}`, `{"event_type": "text-generation", "text": "b"}`, `{"event_type": "stream-end", "finish_reason": "COMPLETE"}`}, "COMPLETE"},
+		{&titanCodec{}, []string{`{"outputText": "a"}`, `{"outputText": "b", "completionReason": "FINISH"}`}, "FINISH"},
+	}
+
+	for _, tt := range tests {
+		response := decodeChunks(t, tt.codec, tt.chunks...)
+		if response.Text != "ab" || response.FinishReason != tt.finish {
+			t.Errorf("%T: got %q, %q", tt.codec, response.Text, response.FinishReason)
+		}
+	}
+}
+
+func TestStreamUsage(t *testing.T) {
+	usage, ok := streamUsage([]byte(`{"generation": "", "amazon-bedrock-invocationMetrics": {"inputTokenCount": 4, "outputTokenCount": 6}}`))
+	if !ok || usage.TotalTokens != 10 {
+		t.Errorf("got %+v, %v", usage, ok)
+	}
+	if _, ok := streamUsage([]byte(`{"generation": "a"}`)); ok {
+		t.Error("found usage in a chunk without metrics")
+	}
+}
+
+func TestTextTranscript(t *testing.T) {
+	messages := []InputMessage{{Role: RoleUser, Content: "Hi"}, {Role: RoleAssistant, Content: "Hello"}, {Role: RoleUser, Content: "Bye"}}
+	got := textTranscript(messages, ModelConfig{SystemPrompt: "Be brief."}, "User", "Bot")
+	want := "Be brief.\n\nUser: Hi\nBot: Hello\nUser: Bye\nBot:"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if !strings.HasSuffix(textTranscript(nil, ModelConfig{}, "User", "Chatbot"), "Chatbot:") {
+		t.Error("transcript does not end with the assistant label")
+	}
+}
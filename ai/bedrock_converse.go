@@ -0,0 +1,361 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// Bedrock APIs selectable through ClientOptions.BedrockAPI
+const (
+	BedrockAPIInvoke   = "invoke"   // InvokeModel with the model family's native payload
+	BedrockAPIConverse = "converse" // Converse, which works with every chat model family
+)
+
+// isNovaModel reports whether a model ID, inference profile or ARN refers to Amazon Nova
+func isNovaModel(modelID string) bool {
+	return strings.Contains(modelID, "amazon.nova")
+}
+
+// converseInput maps messages and config onto the unified Converse request types
+func (c *BedrockClient) converseInput(ctx context.Context, messages []InputMessage, config ModelConfig) (*bedrockruntime.ConverseInput, error) {
+	var system []types.SystemContentBlock
+	if config.SystemPrompt != "" {
+		system = append(system, &types.SystemContentBlockMemberText{Value: config.SystemPrompt})
+	}
+
+	var converseMessages []types.Message
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			if msg.Content != "" {
+				system = append(system, &types.SystemContentBlockMemberText{Value: msg.Content})
+			}
+			continue
+		}
+
+		// Tool results are sent in user turns
+		role := types.ConversationRoleUser
+		if msg.Role == RoleAssistant {
+			role = types.ConversationRoleAssistant
+		}
+
+		content, err := c.converseContent(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		// Converse requires alternating roles, so consecutive turns are merged
+		if last := len(converseMessages) - 1; last >= 0 && converseMessages[last].Role == role {
+			converseMessages[last].Content = append(converseMessages[last].Content, content...)
+			continue
+		}
+		converseMessages = append(converseMessages, types.Message{Role: role, Content: content})
+	}
+
+	// Converse has no native structured output, so the schema is described in the system prompt
+	if config.ResponseSchema != nil {
+		system = append(system, &types.SystemContentBlockMemberText{Value: schemaInstruction(config.ResponseSchema)})
+	}
+
+	input := &bedrockruntime.ConverseInput{
+		ModelId:         aws.String(c.modelID),
+		Messages:        converseMessages,
+		System:          system,
+		InferenceConfig: converseInferenceConfig(config),
+		ToolConfig:      converseToolConfig(config.Tools),
+	}
+
+	// TopK is not part of the unified inference config and is named differently per family
+	if config.TopK > 0 {
+		switch {
+		case strings.Contains(c.modelID, "anthropic."):
+			input.AdditionalModelRequestFields = document.NewLazyDocument(map[string]interface{}{
+				"top_k": config.TopK,
+			})
+		case isNovaModel(c.modelID):
+			input.AdditionalModelRequestFields = document.NewLazyDocument(map[string]interface{}{
+				"inferenceConfig": map[string]interface{}{"topK": config.TopK},
+			})
+		}
+	}
+
+	return input, nil
+}
+
+// converseContent converts the text, images and tool content of a message into content blocks
+func (c *BedrockClient) converseContent(ctx context.Context, msg InputMessage) ([]types.ContentBlock, error) {
+	var content []types.ContentBlock
+
+	for _, img := range msg.Images {
+		data := img.Data
+		if len(data) == 0 && img.URL != "" {
+			// Converse only accepts inline image bytes
+			var err error
+			if data, err = c.options.downloadImage(ctx, img.URL); err != nil {
+				return nil, err
+			}
+		}
+
+		content = append(content, &types.ContentBlockMemberImage{
+			Value: types.ImageBlock{
+				Format: types.ImageFormat(bedrockImageFormat(img)),
+				Source: &types.ImageSourceMemberBytes{Value: data},
+			},
+		})
+	}
+
+	if msg.Content != "" && msg.Role != RoleTool {
+		content = append(content, &types.ContentBlockMemberText{Value: msg.Content})
+	}
+
+	// Tool calls made by the model in an earlier turn
+	for _, call := range msg.ToolCalls {
+		input := map[string]interface{}{}
+		if call.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Arguments), &input); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool call %s: %w", call.Name, err)
+			}
+		}
+		content = append(content, &types.ContentBlockMemberToolUse{
+			Value: types.ToolUseBlock{
+				ToolUseId: aws.String(call.ID),
+				Name:      aws.String(call.Name),
+				Input:     document.NewLazyDocument(input),
+			},
+		})
+	}
+
+	if msg.Role == RoleTool {
+		if msg.ToolResult == nil {
+			return nil, fmt.Errorf("tool message without a tool result")
+		}
+
+		result := types.ToolResultBlock{
+			ToolUseId: aws.String(msg.ToolResult.ToolCallID),
+			Content: []types.ToolResultContentBlock{
+				&types.ToolResultContentBlockMemberText{Value: msg.ToolResult.Content},
+			},
+		}
+		if msg.ToolResult.IsError {
+			result.Status = types.ToolResultStatusError
+		}
+
+		content = append(content, &types.ContentBlockMemberToolResult{Value: result})
+	}
+
+	return content, nil
+}
+
+// converseInferenceConfig maps the model config onto Converse inference parameters.
+// Temperature is always sent, as with the other providers. Other zero values are left
+// unset so that the model's own defaults apply; some models reject top_p together with
+// temperature.
+func converseInferenceConfig(config ModelConfig) *types.InferenceConfiguration {
+	inferenceConfig := &types.InferenceConfiguration{
+		StopSequences: config.StopSequences,
+		Temperature:   aws.Float32(config.Temperature),
+	}
+	if config.MaxTokens > 0 {
+		inferenceConfig.MaxTokens = aws.Int32(config.MaxTokens)
+	}
+	if config.TopP > 0 {
+		inferenceConfig.TopP = aws.Float32(config.TopP)
+	}
+	return inferenceConfig
+}
+
+// converseToolConfig declares tools in the Converse format
+func converseToolConfig(tools []Tool) *types.ToolConfiguration {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	toolConfig := &types.ToolConfiguration{}
+	for _, tool := range tools {
+		spec := types.ToolSpecification{
+			Name:        aws.String(tool.Name),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(toolInputSchema(tool))},
+		}
+		if tool.Description != "" {
+			spec.Description = aws.String(tool.Description)
+		}
+		toolConfig.Tools = append(toolConfig.Tools, &types.ToolMemberToolSpec{Value: spec})
+	}
+
+	return toolConfig
+}
+
+// converseUsage converts Converse token usage
+func converseUsage(usage *types.TokenUsage) TokenUsage {
+	if usage == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{
+		InputTokens:  int(aws.ToInt32(usage.InputTokens)),
+		OutputTokens: int(aws.ToInt32(usage.OutputTokens)),
+		TotalTokens:  int(aws.ToInt32(usage.TotalTokens)),
+	}
+}
+
+// converse sends a conversation through the Converse API
+func (c *BedrockClient) converse(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	ctx, cancel := c.options.withTimeout(ctx)
+	defer cancel()
+
+	input, err := c.converseInput(ctx, messages, config)
+	if err != nil {
+		return Response{}, err
+	}
+
+	// Call the Bedrock API
+	output, err := c.client.Converse(ctx, input)
+	if err != nil {
+		return Response{}, bedrockError(err)
+	}
+
+	// Format the standard response
+	result := Response{
+		Raw:          output,
+		FinishReason: string(output.StopReason),
+		TokenUsage:   converseUsage(output.Usage),
+		Provider:     ProviderBedrock,
+		Model:        c.modelID,
+	}
+
+	// Extract the text and tool calls from the response
+	message, ok := output.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return result, nil
+	}
+
+	for _, block := range message.Value.Content {
+		switch b := block.(type) {
+		case *types.ContentBlockMemberText:
+			result.Text += b.Value
+		case *types.ContentBlockMemberToolUse:
+			call := ToolCall{
+				ID:   aws.ToString(b.Value.ToolUseId),
+				Name: aws.ToString(b.Value.Name),
+			}
+			if b.Value.Input != nil {
+				args, err := b.Value.Input.MarshalSmithyDocument()
+				if err != nil {
+					return Response{}, fmt.Errorf("error decoding tool input: %w", err)
+				}
+				call.Arguments = string(args)
+			}
+			result.ToolCalls = append(result.ToolCalls, call)
+		}
+	}
+
+	return result, nil
+}
+
+// converseStream streams a conversation through the ConverseStream API
+func (c *BedrockClient) converseStream(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	input, err := c.converseInput(ctx, messages, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Call the Bedrock API
+	output, err := c.client.ConverseStream(ctx, &bedrockruntime.ConverseStreamInput{
+		ModelId:                      input.ModelId,
+		Messages:                     input.Messages,
+		System:                       input.System,
+		InferenceConfig:              input.InferenceConfig,
+		ToolConfig:                   input.ToolConfig,
+		AdditionalModelRequestFields: input.AdditionalModelRequestFields,
+	})
+	if err != nil {
+		return nil, bedrockError(err)
+	}
+
+	stream := output.GetStream()
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close()
+
+		// Tool use blocks stream their input in fragments until the block stops
+		var toolCall *ToolCall
+
+		for {
+			var event types.ConverseStreamOutput
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok = <-stream.Events():
+			}
+
+			if !ok {
+				if err := stream.Err(); err != nil {
+					sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: bedrockError(err)})
+				}
+				return
+			}
+
+			var streamEvent StreamEvent
+			switch e := event.(type) {
+			case *types.ConverseStreamOutputMemberContentBlockStart:
+				if start, isToolUse := e.Value.Start.(*types.ContentBlockStartMemberToolUse); isToolUse {
+					toolCall = &ToolCall{
+						ID:   aws.ToString(start.Value.ToolUseId),
+						Name: aws.ToString(start.Value.Name),
+					}
+				}
+				continue
+			case *types.ConverseStreamOutputMemberContentBlockDelta:
+				switch delta := e.Value.Delta.(type) {
+				case *types.ContentBlockDeltaMemberText:
+					if delta.Value == "" {
+						continue
+					}
+					streamEvent = StreamEvent{Type: StreamEventText, Text: delta.Value}
+				case *types.ContentBlockDeltaMemberToolUse:
+					if toolCall != nil {
+						toolCall.Arguments += aws.ToString(delta.Value.Input)
+					}
+					continue
+				default:
+					continue
+				}
+			case *types.ConverseStreamOutputMemberContentBlockStop:
+				if toolCall == nil {
+					continue
+				}
+				streamEvent = StreamEvent{Type: StreamEventToolCall, ToolCall: toolCall}
+				toolCall = nil
+			case *types.ConverseStreamOutputMemberMessageStop:
+				streamEvent = StreamEvent{Type: StreamEventFinish, FinishReason: string(e.Value.StopReason)}
+			case *types.ConverseStreamOutputMemberMetadata:
+				streamEvent = StreamEvent{
+					Type:       StreamEventUsage,
+					TokenUsage: converseUsage(e.Value.Usage),
+					Provider:   ProviderBedrock,
+					Model:      c.modelID,
+				}
+			default:
+				continue
+			}
+
+			if !sendStreamEvent(ctx, events, streamEvent) {
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestConverseInferenceConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      ModelConfig
+		temperature float32
+		topP        *float32
+		maxTokens   *int32
+	}{
+		{"zero config", ModelConfig{}, 0, nil, nil},
+		{"deterministic", ModelConfig{Temperature: 0, MaxTokens: 64}, 0, nil, ptr[int32](64)},
+		{"sampling", ModelConfig{Temperature: 0.7, TopP: 0.9}, 0.7, ptr[float32](0.9), nil},
+	}
+
+	for _, tt := range tests {
+		got := converseInferenceConfig(tt.config)
+		if got.Temperature == nil || *got.Temperature != tt.temperature {
+			t.Errorf("%s: got temperature %v, want %v", tt.name, got.Temperature, tt.temperature)
+		}
+		if (got.TopP == nil) != (tt.topP == nil) || (got.TopP != nil && *got.TopP != *tt.topP) {
+			t.Errorf("%s: got top_p %v, want %v", tt.name, got.TopP, tt.topP)
+		}
+		if (got.MaxTokens == nil) != (tt.maxTokens == nil) || (got.MaxTokens != nil && *got.MaxTokens != *tt.maxTokens) {
+			t.Errorf("%s: got max tokens %v, want %v", tt.name, got.MaxTokens, tt.maxTokens)
+		}
+	}
+}
+
+func TestConverseSendsZeroTemperature(t *testing.T) {
+	var request struct {
+		InferenceConfig map[string]interface{} `json:"inferenceConfig"`
+	}
+	client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"output": {"message": {"role": "assistant", "content": [{"text": "ok"}]}}, "stopReason": "end_turn"}`)
+	}, 0)
+
+	_, err := client.TextCompletion(context.Background(), []InputMessage{{Role: RoleUser, Content: "hi"}}, ModelConfig{Temperature: 0})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if temperature, ok := request.InferenceConfig["temperature"]; !ok || temperature != 0.0 {
+		t.Errorf("got inference config %v, want temperature 0", request.InferenceConfig)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+)
+
+const (
+	bedrockDefaultEmbeddingModel = "amazon.titan-embed-text-v2:0"
+	cohereMaxEmbeddingBatch      = 96 // Texts accepted by one Cohere embed request
+)
+
+// Embed creates embeddings with the Amazon Titan or Cohere embedding models on Bedrock.
+// Titan embeds one text per request; Cohere takes batches of up to 96 texts.
+func (c *BedrockClient) Embed(ctx context.Context, inputs []string, opts EmbedOptions) ([][]float32, TokenUsage, error) {
+	modelID := opts.Model
+	if modelID == "" {
+		modelID = bedrockDefaultEmbeddingModel
+	}
+
+	var size int
+	var encode func(batch []string) ([]byte, error)
+	var decode func(body []byte) ([][]float32, TokenUsage, error)
+
+	switch {
+	case strings.Contains(modelID, "amazon.titan-embed-text"):
+		size = 1
+		encode = func(batch []string) ([]byte, error) {
+			payload := map[string]interface{}{"inputText": batch[0]}
+			// Titan v2 shortens vectors server-side to one of its supported sizes
+			if strings.Contains(modelID, "v2") {
+				switch opts.Dimensions {
+				case 256, 512, 1024:
+					payload["dimensions"] = opts.Dimensions
+				}
+			}
+			return marshalRequest(payload)
+		}
+		decode = func(body []byte) ([][]float32, TokenUsage, error) {
+			var response struct {
+				Embedding           []float32 `json:"embedding"`
+				InputTextTokenCount int       `json:"inputTextTokenCount"`
+			}
+			if err := unmarshalResponse(body, &response); err != nil {
+				return nil, TokenUsage{}, err
+			}
+			return [][]float32{response.Embedding}, newTokenUsage(response.InputTextTokenCount, 0), nil
+		}
+	case strings.Contains(modelID, "cohere.embed"):
+		size = cohereMaxEmbeddingBatch
+		inputType := "search_document"
+		if opts.InputType == EmbedInputQuery {
+			inputType = "search_query"
+		}
+		encode = func(batch []string) ([]byte, error) {
+			return marshalRequest(map[string]interface{}{
+				"texts":      batch,
+				"input_type": inputType,
+				"truncate":   "END",
+			})
+		}
+		decode = func(body []byte) ([][]float32, TokenUsage, error) {
+			var response struct {
+				Embeddings [][]float32 `json:"embeddings"`
+			}
+			if err := unmarshalResponse(body, &response); err != nil {
+				return nil, TokenUsage{}, err
+			}
+			return response.Embeddings, TokenUsage{}, nil
+		}
+	default:
+		return nil, TokenUsage{}, fmt.Errorf("no embedding payload format known for model %s", modelID)
+	}
+
+	vectors, usage, err := embedInBatches(ctx, inputs, size, func(ctx context.Context, batch []string) ([][]float32, TokenUsage, error) {
+		ctx, cancel := c.options.withTimeout(ctx)
+		defer cancel()
+
+		body, err := encode(batch)
+		if err != nil {
+			return nil, TokenUsage{}, err
+		}
+
+		response, err := c.client.InvokeModel(ctx, &bedrockruntime.InvokeModelInput{
+			ModelId:     aws.String(modelID),
+			Body:        body,
+			ContentType: aws.String("application/json"),
+		})
+		if err != nil {
+			return nil, TokenUsage{}, bedrockError(err)
+		}
+
+		vectors, usage, err := decode(response.Body)
+		if err != nil {
+			return nil, TokenUsage{}, err
+		}
+		if len(vectors) != len(batch) {
+			return nil, TokenUsage{}, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(vectors))
+		}
+
+		// Cohere only reports usage in the response headers
+		if usage.TotalTokens == 0 {
+			usage = headerUsage(response.ResultMetadata)
+		}
+		return vectors, usage, nil
+	})
+	if err != nil {
+		return nil, usage, err
+	}
+
+	return shapeEmbeddings(vectors, opts), usage, nil
+}
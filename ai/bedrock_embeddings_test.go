@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBedrockEmbed(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      EmbedOptions
+		inputs    int
+		requests  int
+		inputType string
+	}{
+		{"titan embeds one text per request", EmbedOptions{Dimensions: 256}, 3, 3, ""},
+		{"cohere batches", EmbedOptions{Model: "cohere.embed-english-v3", InputType: EmbedInputQuery}, cohereMaxEmbeddingBatch + 1, 2, "search_query"},
+		{"cohere documents by default", EmbedOptions{Model: "cohere.embed-multilingual-v3"}, 2, 1, "search_document"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			var modelPath string
+			client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				modelPath, _ = url.PathUnescape(r.URL.EscapedPath())
+				var request map[string]interface{}
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &request)
+				requests = append(requests, request)
+
+				w.Header().Set("Content-Type", "application/json")
+				if text, ok := request["inputText"].(string); ok {
+					fmt.Fprintf(w, `{"embedding": [%s, 1], "inputTextTokenCount": 2}`, text)
+					return
+				}
+				var embeddings []string
+				for _, text := range request["texts"].([]interface{}) {
+					embeddings = append(embeddings, fmt.Sprintf("[%s, 1]", text))
+				}
+				w.Header().Set("X-Amzn-Bedrock-Input-Token-Count", "4")
+				fmt.Fprintf(w, `{"embeddings": [%s]}`, strings.Join(embeddings, ","))
+			}, 0)
+
+			inputs := make([]string, tt.inputs)
+			for i := range inputs {
+				inputs[i] = fmt.Sprint(i)
+			}
+
+			vectors, usage, err := client.Embed(context.Background(), inputs, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(requests) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(requests), tt.requests)
+			}
+			for i, vector := range vectors {
+				if vector[0] != float32(i) {
+					t.Fatalf("vector %d belongs to input %v", i, vector[0])
+				}
+			}
+			if len(vectors) != tt.inputs || usage.InputTokens == 0 {
+				t.Errorf("got %d vectors and %d tokens", len(vectors), usage.InputTokens)
+			}
+
+			if tt.inputType != "" {
+				if requests[0]["input_type"] != tt.inputType {
+					t.Errorf("got input type %v, want %s", requests[0]["input_type"], tt.inputType)
+				}
+			} else if requests[0]["dimensions"] != 256.0 || !strings.Contains(modelPath, bedrockDefaultEmbeddingModel) {
+				t.Errorf("got dimensions %v for %s", requests[0]["dimensions"], modelPath)
+			}
+		})
+	}
+}
+
+func TestBedrockEmbedUnknownModel(t *testing.T) {
+	client := newBedrockTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request")
+	}, 0)
+
+	if _, _, err := client.Embed(context.Background(), []string{"a"}, EmbedOptions{Model: "amazon.nova-pro-v1:0"}); err == nil {
+		t.Error("embedded with a model that has no embedding format")
+	}
+}
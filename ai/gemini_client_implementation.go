@@ -2,18 +2,21 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strings"
 
 	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
 // GeminiClient implements the Client interface for Google Gemini
 type GeminiClient struct {
 	client  *genai.Client
-	model   *genai.GenerativeModel
 	options ClientOptions
 }
 
@@ -24,127 +27,285 @@ func NewGeminiClient() *GeminiClient {
 
 // Initialize sets up the Gemini client
 func (c *GeminiClient) Initialize(ctx context.Context, opts ClientOptions) error {
+	clientOptions := []option.ClientOption{option.WithAPIKey(opts.APIKey)}
+
+	// Point at a Gemini proxy if provided
+	if opts.EndpointURL != "" {
+		clientOptions = append(clientOptions, option.WithEndpoint(opts.EndpointURL))
+	}
+
+	// A custom HTTP client replaces the SDK's authenticated transport,
+	// so the API key has to be attached to each request by hand
+	if opts.Timeout > 0 {
+		httpClient := &http.Client{Transport: &geminiAPIKeyTransport{apiKey: opts.APIKey, base: opts.httpTransport()}}
+		clientOptions = append(clientOptions, option.WithHTTPClient(httpClient))
+	}
+
 	// Create the Gemini client
-	client, err := genai.NewClient(ctx, option.WithAPIKey(opts.APIKey))
+	client, err := genai.NewClient(ctx, clientOptions...)
 	if err != nil {
-		return fmt.Errorf("failed to create Gemini client: %v", err)
+		return fmt.Errorf("failed to create Gemini client: %w", err)
 	}
 
 	// Apply options
 	c.options = opts
 	c.client = client
-	c.model = client.GenerativeModel(opts.ModelID)
 
 	return nil
 }
 
-// TextCompletion sends a text request to Gemini
-func (c *GeminiClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+// geminiAPIKeyTransport authenticates requests sent through a custom HTTP client
+type geminiAPIKeyTransport struct {
+	apiKey string
+	base   http.RoundTripper
+}
+
+// RoundTrip adds the API key header to a copy of the request
+func (t *geminiAPIKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("x-goog-api-key", t.apiKey)
+	return t.base.RoundTrip(req)
+}
+
+// generativeModel creates a model handle configured for a single request,
+// so concurrent requests with different configs don't interfere.
+// The system instruction is set by startChat, which also merges system messages.
+func (c *GeminiClient) generativeModel(config ModelConfig) *genai.GenerativeModel {
+	model := c.client.GenerativeModel(c.options.ModelID)
+
 	// Apply configuration
-	c.model.SetTemperature(float32(config.Temperature))
-	c.model.SetTopP(float32(config.TopP))
-	c.model.SetTopK(config.TopK)
-	c.model.SetMaxOutputTokens(config.MaxTokens)
+	model.SetTemperature(float32(config.Temperature))
+	model.SetTopP(float32(config.TopP))
+	model.SetTopK(config.TopK)
+	model.SetMaxOutputTokens(config.MaxTokens)
 
-	// Set system prompt if provided
-	if config.SystemPrompt != "" {
-		c.model.SystemInstruction = genai.NewUserContent(genai.Text(config.SystemPrompt))
+	// Declare tools if provided
+	if len(config.Tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, 0, len(config.Tools))
+		for _, tool := range config.Tools {
+			declarations = append(declarations, &genai.FunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  geminiSchema(tool.Parameters),
+			})
+		}
+		model.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
 	}
 
-	// Extract the last message for prompt
-	if len(messages) == 0 {
-		return Response{}, fmt.Errorf("no messages provided")
+	// Request structured output if a schema is provided
+	if config.ResponseSchema != nil {
+		model.ResponseMIMEType = "application/json"
+		model.ResponseSchema = geminiSchema(config.ResponseSchema.Schema)
 	}
 
-	prompt := messages[len(messages)-1].Content
+	return model
+}
 
-	// Generate content
-	resp, err := c.model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil {
-		return Response{}, fmt.Errorf("failed to generate content: %v", err)
+// geminiSchema converts a JSON Schema document into Gemini's schema type
+func geminiSchema(schema map[string]interface{}) *genai.Schema {
+	if schema == nil {
+		return nil
 	}
 
-	// Format the standard response
-	result := Response{
-		Raw: resp,
-	}
+	result := &genai.Schema{}
+	result.Nullable, _ = schema["nullable"].(bool)
 
-	// Extract usage information if available
-	if resp.UsageMetadata != nil {
-		result.TokenUsage = TokenUsage{
-			InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
-			OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-			TotalTokens:  int(resp.UsageMetadata.TotalTokenCount),
+	// A type may be a list such as ["string", "null"], which Gemini expresses as nullable
+	var schemaType string
+	types := schemaStrings(schema["type"])
+	if single, ok := schema["type"].(string); ok {
+		types = []string{single}
+	}
+	for _, t := range types {
+		if t == "null" {
+			result.Nullable = true
+		} else if schemaType == "" {
+			schemaType = t
 		}
 	}
 
-	// Extract text from response
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil && len(resp.Candidates[0].Content.Parts) > 0 {
-		part := resp.Candidates[0].Content.Parts[0]
-		if str, ok := part.(genai.Text); ok {
-			result.Text = string(str)
-		}
+	switch schemaType {
+	case "object":
+		result.Type = genai.TypeObject
+	case "array":
+		result.Type = genai.TypeArray
+	case "string":
+		result.Type = genai.TypeString
+	case "integer":
+		result.Type = genai.TypeInteger
+	case "number":
+		result.Type = genai.TypeNumber
+	case "boolean":
+		result.Type = genai.TypeBoolean
 	}
 
-	return result, nil
-}
+	result.Description, _ = schema["description"].(string)
+	result.Format, _ = schema["format"].(string)
+	result.Enum = schemaStrings(schema["enum"])
+	result.Required = schemaStrings(schema["required"])
 
-// ImageRecognition sends images with optional text to Gemini
-func (c *GeminiClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
-	// Apply configuration
-	c.model.SetTemperature(float32(config.Temperature))
-	c.model.SetTopP(float32(config.TopP))
-	c.model.SetTopK(config.TopK)
-	c.model.SetMaxOutputTokens(config.MaxTokens)
-
-	// Set system prompt if provided
-	if config.SystemPrompt != "" {
-		c.model.SystemInstruction = genai.NewUserContent(genai.Text(config.SystemPrompt))
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		result.Items = geminiSchema(items)
 	}
 
-	// Extract the last message
-	if len(messages) == 0 {
-		return Response{}, fmt.Errorf("no messages provided")
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		result.Properties = make(map[string]*genai.Schema, len(properties))
+		for name, property := range properties {
+			if propertySchema, ok := property.(map[string]interface{}); ok {
+				result.Properties[name] = geminiSchema(propertySchema)
+			}
+		}
 	}
 
-	message := messages[len(messages)-1]
+	return result
+}
 
-	// Create parts for the request
+// geminiParts converts the text, images and tool content of a message into Gemini parts
+func (c *GeminiClient) geminiParts(ctx context.Context, msg InputMessage) ([]genai.Part, error) {
 	var parts []genai.Part
 
 	// Add images to parts
-	for _, img := range message.Images {
+	for _, img := range msg.Images {
 		if len(img.Data) > 0 {
 			// Use inline image data
 			parts = append(parts, genai.ImageData(img.Format, img.Data))
 		} else if img.URL != "" {
 			// Download from URL
-			resp, err := http.Get(img.URL)
+			imgBytes, err := c.options.downloadImage(ctx, img.URL)
 			if err != nil {
-				return Response{}, fmt.Errorf("failed to download image: %v", err)
+				return nil, err
 			}
-			defer resp.Body.Close()
+			parts = append(parts, genai.ImageData(img.Format, imgBytes))
+		}
+	}
 
-			imgBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return Response{}, fmt.Errorf("failed to read image data: %v", err)
+	// Add text to parts if present
+	if msg.Content != "" && msg.Role != RoleTool {
+		parts = append(parts, genai.Text(msg.Content))
+	}
+
+	// Tool calls made by the model in an earlier turn
+	for _, call := range msg.ToolCalls {
+		var args map[string]interface{}
+		if call.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool call %s: %w", call.Name, err)
 			}
+		}
+		parts = append(parts, genai.FunctionCall{Name: call.Name, Args: args})
+	}
 
-			parts = append(parts, genai.ImageData(img.Format, imgBytes))
+	// Tool results must be JSON objects, so plain output is wrapped
+	if msg.Role == RoleTool {
+		if msg.ToolResult == nil {
+			return nil, fmt.Errorf("tool message without a tool result")
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.ToolResult.Content), &response); err != nil || response == nil {
+			response = map[string]interface{}{"content": msg.ToolResult.Content}
+		}
+		if msg.ToolResult.IsError {
+			response = map[string]interface{}{"error": msg.ToolResult.Content}
+		}
+
+		if msg.ToolResult.Name == "" {
+			return nil, fmt.Errorf("tool result %s has no name and matches no earlier tool call", msg.ToolResult.ToolCallID)
 		}
+		parts = append(parts, genai.FunctionResponse{Name: msg.ToolResult.Name, Response: response})
 	}
 
-	// Add text to parts if present
-	if message.Content != "" {
-		parts = append(parts, genai.Text(message.Content))
+	return parts, nil
+}
+
+// chatContents maps the message history onto Gemini contents for a model configured
+// from config. System messages are merged into the model's system instruction.
+func (c *GeminiClient) chatContents(ctx context.Context, messages []InputMessage, config ModelConfig) (*genai.GenerativeModel, []*genai.Content, error) {
+	if len(messages) == 0 {
+		return nil, nil, fmt.Errorf("no messages provided")
 	}
 
-	// Generate content
-	resp, err := c.model.GenerateContent(ctx, parts...)
+	model := c.generativeModel(config)
+
+	var systemParts []genai.Part
+	if config.SystemPrompt != "" {
+		systemParts = append(systemParts, genai.Text(config.SystemPrompt))
+	}
+
+	var contents []*genai.Content
+	toolNames := map[string]string{} // Tool call IDs from earlier turns, for results without a name
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Name
+		}
+		if msg.ToolResult != nil && msg.ToolResult.Name == "" {
+			result := *msg.ToolResult
+			result.Name = toolNames[result.ToolCallID]
+			msg.ToolResult = &result
+		}
+
+		if msg.Role == RoleSystem {
+			if msg.Content != "" {
+				systemParts = append(systemParts, genai.Text(msg.Content))
+			}
+			continue
+		}
+
+		// Gemini calls the assistant "model"; tool results are sent in user turns
+		role := "user"
+		if msg.Role == RoleAssistant {
+			role = "model"
+		}
+
+		parts, err := c.geminiParts(ctx, msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		// Consecutive turns from the same side are merged, so that several tool
+		// results answer the model's function calls in a single turn
+		if last := len(contents) - 1; last >= 0 && contents[last].Role == role {
+			contents[last].Parts = append(contents[last].Parts, parts...)
+			continue
+		}
+		contents = append(contents, &genai.Content{Role: role, Parts: parts})
+	}
+
+	if len(systemParts) > 0 {
+		model.SystemInstruction = genai.NewUserContent(systemParts...)
+	}
+
+	if len(contents) == 0 {
+		return nil, nil, fmt.Errorf("no messages provided")
+	}
+
+	return model, contents, nil
+}
+
+// startChat maps the message history onto a Gemini chat session. Earlier turns become
+// the session history and the parts of the final user turn are returned for sending.
+func (c *GeminiClient) startChat(ctx context.Context, messages []InputMessage, config ModelConfig) (*genai.ChatSession, []genai.Part, error) {
+	model, contents, err := c.chatContents(ctx, messages, config)
 	if err != nil {
-		return Response{}, fmt.Errorf("failed to generate content: %v", err)
+		return nil, nil, err
 	}
 
+	final := contents[len(contents)-1]
+	if final.Role != "user" {
+		return nil, nil, fmt.Errorf("the last message must come from the user or a tool")
+	}
+
+	chat := model.StartChat()
+	chat.History = contents[:len(contents)-1]
+
+	return chat, final.Parts, nil
+}
+
+// geminiResponse converts a Gemini response into the standard response
+func geminiResponse(resp *genai.GenerateContentResponse) Response {
 	// Format the standard response
 	result := Response{
 		Raw: resp,
@@ -156,20 +317,250 @@ func (c *GeminiClient) ImageRecognition(ctx context.Context, messages []InputMes
 			InputTokens:  int(resp.UsageMetadata.PromptTokenCount),
 			OutputTokens: int(resp.UsageMetadata.CandidatesTokenCount),
 			TotalTokens:  int(resp.UsageMetadata.TotalTokenCount),
+			CachedTokens: int(resp.UsageMetadata.CachedContentTokenCount),
 		}
 	}
 
-	// Extract text from response
-	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil && len(resp.Candidates[0].Content.Parts) > 0 {
-		part := resp.Candidates[0].Content.Parts[0]
-		if str, ok := part.(genai.Text); ok {
-			result.Text = string(str)
+	if len(resp.Candidates) == 0 {
+		return result
+	}
+
+	candidate := resp.Candidates[0]
+	if candidate.FinishReason != genai.FinishReasonUnspecified {
+		result.FinishReason = candidate.FinishReason.String()
+	}
+
+	if candidate.Content == nil {
+		return result
+	}
+
+	// Extract text and function calls from response
+	for _, part := range candidate.Content.Parts {
+		switch p := part.(type) {
+		case genai.Text:
+			result.Text += string(p)
+		case genai.FunctionCall:
+			result.ToolCalls = append(result.ToolCalls, geminiToolCall(p, len(result.ToolCalls)))
 		}
 	}
 
+	return result
+}
+
+// geminiToolCall converts a Gemini function call. Gemini doesn't assign call IDs,
+// so one is derived from the function name and position.
+func geminiToolCall(call genai.FunctionCall, index int) ToolCall {
+	args, _ := json.Marshal(call.Args)
+	return ToolCall{
+		ID:        fmt.Sprintf("%s_%d", call.Name, index),
+		Name:      call.Name,
+		Arguments: string(args),
+	}
+}
+
+// checkContextWindow fails fast when the conversation cannot fit the model. Prompts whose
+// estimate is well inside the context window skip the countTokens round trip, and the rest
+// are counted exactly. If counting fails, the request is sent and the provider decides.
+func (c *GeminiClient) checkContextWindow(ctx context.Context, messages []InputMessage, config ModelConfig) error {
+	estimate := estimateTokens(messages, config, countTextTokens, geminiImageTokens)
+	if !nearContextWindow(c.options.ModelID, estimate, config) {
+		return nil
+	}
+
+	tokens, err := c.CountTokens(ctx, messages, config)
+	if err != nil {
+		return nil
+	}
+	return checkContextWindow(ProviderGemini, c.options.ModelID, tokens, config)
+}
+
+// TextCompletion sends a conversation to Gemini
+func (c *GeminiClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	ctx, cancel := c.options.withTimeout(ctx)
+	defer cancel()
+
+	if err := c.checkContextWindow(ctx, messages, config); err != nil {
+		return Response{}, err
+	}
+
+	chat, parts, err := c.startChat(ctx, messages, config)
+	if err != nil {
+		return Response{}, err
+	}
+
+	// Generate content
+	resp, err := chat.SendMessage(ctx, parts...)
+	if err != nil {
+		return Response{}, geminiError(err)
+	}
+
+	result := geminiResponse(resp)
+	result.Provider = ProviderGemini
+	result.Model = c.options.ModelID
+
 	return result, nil
 }
 
+// StreamCompletion streams a text response from Gemini
+func (c *GeminiClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	if err := c.checkContextWindow(ctx, messages, config); err != nil {
+		return nil, err
+	}
+
+	// The iterator has no Close, so the request is canceled when the stream ends
+	ctx, cancel := context.WithCancel(ctx)
+
+	chat, parts, err := c.startChat(ctx, messages, config)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	iter := chat.SendMessageStream(ctx, parts...)
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer cancel()
+
+		// Gemini reports cumulative usage and the finish reason on every chunk,
+		// so only the values from the last chunk are emitted
+		var usage *genai.UsageMetadata
+		var finishReason genai.FinishReason
+		var toolCalls int
+
+		for {
+			resp, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: geminiError(err)})
+				return
+			}
+
+			if resp.UsageMetadata != nil {
+				usage = resp.UsageMetadata
+			}
+
+			if len(resp.Candidates) == 0 {
+				continue
+			}
+
+			candidate := resp.Candidates[0]
+			if candidate.FinishReason != genai.FinishReasonUnspecified {
+				finishReason = candidate.FinishReason
+			}
+
+			if candidate.Content == nil {
+				continue
+			}
+
+			for _, part := range candidate.Content.Parts {
+				var event StreamEvent
+				switch p := part.(type) {
+				case genai.Text:
+					if p == "" {
+						continue
+					}
+					event = StreamEvent{Type: StreamEventText, Text: string(p)}
+				case genai.FunctionCall:
+					call := geminiToolCall(p, toolCalls)
+					toolCalls++
+					event = StreamEvent{Type: StreamEventToolCall, ToolCall: &call}
+				default:
+					continue
+				}
+				if !sendStreamEvent(ctx, events, event) {
+					return
+				}
+			}
+		}
+
+		if finishReason != genai.FinishReasonUnspecified {
+			if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventFinish, FinishReason: finishReason.String()}) {
+				return
+			}
+		}
+
+		if usage != nil {
+			sendStreamEvent(ctx, events, StreamEvent{
+				Type: StreamEventUsage,
+				TokenUsage: TokenUsage{
+					InputTokens:  int(usage.PromptTokenCount),
+					OutputTokens: int(usage.CandidatesTokenCount),
+					TotalTokens:  int(usage.TotalTokenCount),
+					CachedTokens: int(usage.CachedContentTokenCount),
+				},
+				Provider: ProviderGemini,
+				Model:    c.options.ModelID,
+			})
+		}
+	}()
+
+	return events, nil
+}
+
+// ImageRecognition sends images with optional text to Gemini.
+// Images are part of the message history, so this shares the TextCompletion path.
+func (c *GeminiClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	return c.TextCompletion(ctx, messages, config)
+}
+
+// CountTokens counts prompt tokens with the Gemini countTokens endpoint, including the
+// system instruction and tool declarations
+func (c *GeminiClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	ctx, cancel := c.options.withTimeout(ctx)
+	defer cancel()
+
+	model, contents, err := c.chatContents(ctx, messages, config)
+	if err != nil {
+		return 0, err
+	}
+
+	// The count covers every turn, so the whole history is sent as one sequence of parts
+	var parts []genai.Part
+	for _, content := range contents {
+		parts = append(parts, content.Parts...)
+	}
+
+	resp, err := model.CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, geminiError(err)
+	}
+
+	return int(resp.TotalTokens), nil
+}
+
+// geminiImageTokens is the fixed cost Gemini charges for an image
+func geminiImageTokens(Image) int {
+	return 258
+}
+
+// geminiError translates a Gemini SDK failure into an *Error
+func geminiError(err error) error {
+	aiErr := newError(ProviderGemini, err)
+
+	var blocked *genai.BlockedError
+	var apiErr *googleapi.Error
+	switch {
+	case errors.As(err, &blocked):
+		aiErr.Category = ErrorCategoryContentFiltered
+	case errors.As(err, &apiErr):
+		aiErr.setStatus(apiErr.Code, apiErr.Header)
+		if apiErr.Message != "" {
+			aiErr.Message = apiErr.Message
+		}
+		// Gemini reports invalid API keys as bad requests
+		if strings.Contains(strings.ToLower(aiErr.Message), "api key not valid") {
+			aiErr.Category = ErrorCategoryAuth
+		}
+	}
+
+	aiErr.refine()
+	return aiErr
+}
+
 // Close releases resources
 func (c *GeminiClient) Close() error {
 	if c.client != nil {
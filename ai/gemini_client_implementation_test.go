@@ -0,0 +1,221 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SDK reads every response, unary or not, as a streamed JSON array
+const geminiTestResponse = `[{
+	"candidates": [{"content": {"role": "model", "parts": [{"text": "hello"}]}, "finishReason": 1}],
+	"usageMetadata": {"promptTokenCount": 3, "candidatesTokenCount": 1, "totalTokenCount": 4}
+}]`
+
+// gaxFindsStreamEnd reports whether gax-go can detect the closing bracket of a streamed
+// JSON array. It calls json.Decoder.Token after a failed Decode, which toolchains built
+// with the json v2 implementation reject, so there every Gemini response ends with a
+// syntax error after its last chunk.
+func gaxFindsStreamEnd() bool {
+	decoder := json.NewDecoder(strings.NewReader(`[{}]`))
+	decoder.Token()
+	var raw json.RawMessage
+	decoder.Decode(&raw)
+	if decoder.Decode(&raw) == nil {
+		return false
+	}
+	token, _ := decoder.Token()
+	return token == json.Delim(']')
+}
+
+// newGeminiTestClient points a Gemini client at handler
+func newGeminiTestClient(t *testing.T, handler http.HandlerFunc, timeout int) *GeminiClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewGeminiClient()
+	err := client.Initialize(context.Background(), ClientOptions{
+		APIKey:      "test-key",
+		ModelID:     "gemini-test",
+		EndpointURL: server.URL,
+		Timeout:     timeout,
+	})
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+var geminiTestMessages = []InputMessage{{Role: RoleUser, Content: "hi"}}
+
+func TestGeminiEndpointURL(t *testing.T) {
+	// Without a timeout the SDK authenticates requests, with one geminiAPIKeyTransport does
+	for _, timeout := range []int{0, 30} {
+		var path, apiKey string
+		client := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			apiKey = r.Header.Get("x-goog-api-key")
+			if apiKey == "" {
+				apiKey = r.URL.Query().Get("key")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, geminiTestResponse)
+		}, timeout)
+
+		response, err := client.TextCompletion(context.Background(), geminiTestMessages, ModelConfig{})
+		if !strings.HasSuffix(path, "/models/gemini-test:streamGenerateContent") {
+			t.Errorf("timeout %d: request went to %q", timeout, path)
+		}
+		if apiKey != "test-key" {
+			t.Errorf("timeout %d: got API key %q", timeout, apiKey)
+		}
+		if !gaxFindsStreamEnd() {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("timeout %d: unexpected error: %v", timeout, err)
+		}
+		if response.Text != "hello" || response.TokenUsage.TotalTokens != 4 {
+			t.Errorf("timeout %d: unexpected response: %+v", timeout, response)
+		}
+	}
+}
+
+func TestGeminiTimeout(t *testing.T) {
+	release := make(chan struct{})
+	client := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, 1)
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	_, err := client.TextCompletion(context.Background(), geminiTestMessages, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryTimeout) {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("request took %s despite a 1s timeout", elapsed)
+	}
+}
+
+func TestGeminiStreamOutlivesTimeout(t *testing.T) {
+	var apiKey string
+	client := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("x-goog-api-key")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "[")
+		for i, word := range []string{"one ", "two ", "three"} {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			io.WriteString(w, `{"candidates": [{"content": {"role": "model", "parts": [{"text": "`+word+`"}]}}]}`)
+			w.(http.Flusher).Flush()
+			time.Sleep(600 * time.Millisecond)
+		}
+		io.WriteString(w, "]")
+	}, 1)
+
+	events, err := client.StreamCompletion(context.Background(), geminiTestMessages, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The stream runs past the 1s timeout without being cut off
+	var text strings.Builder
+	for event := range events {
+		switch event.Type {
+		case StreamEventText:
+			text.WriteString(event.Text)
+		case StreamEventError:
+			err = event.Err
+		}
+	}
+	if text.String() != "one two three" || errors.Is(err, ErrorCategoryTimeout) {
+		t.Errorf("got %q, %v, want the whole stream", text.String(), err)
+	}
+	if err != nil && gaxFindsStreamEnd() {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if apiKey != "test-key" {
+		t.Errorf("got API key %q", apiKey)
+	}
+}
+
+func TestGeminiErrorClassification(t *testing.T) {
+	client := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED"}}`)
+	}, 30)
+
+	_, err := client.TextCompletion(context.Background(), geminiTestMessages, ModelConfig{})
+	var aiErr *Error
+	if !errors.As(err, &aiErr) {
+		t.Fatalf("got %v, want an *Error", err)
+	}
+	if aiErr.Category != ErrorCategoryRateLimited || aiErr.Provider != ProviderGemini {
+		t.Errorf("got %s from %s, want rate_limited from gemini", aiErr.Category, aiErr.Provider)
+	}
+}
+
+func TestGeminiContextWindowPreflight(t *testing.T) {
+	RegisterModel("gemini-test", ModelCapabilities{ContextWindow: 100})
+
+	tests := []struct {
+		name       string
+		words      int
+		counted    int
+		countFails bool
+		wantCount  bool
+		wantErr    bool
+	}{
+		{"far from the window", 1, 0, false, false, false},
+		{"counted and fits", 90, 90, false, true, false},
+		{"counted and too large", 90, 150, false, true, true},
+		{"count fails", 90, 0, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var counts, generates int
+			client := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if strings.HasSuffix(r.URL.Path, ":countTokens") {
+					counts++
+					if tt.countFails {
+						w.WriteHeader(http.StatusInternalServerError)
+						io.WriteString(w, `{"error": {"code": 500, "message": "internal", "status": "INTERNAL"}}`)
+						return
+					}
+					fmt.Fprintf(w, `{"totalTokens": %d}`, tt.counted)
+					return
+				}
+				generates++
+				io.WriteString(w, geminiTestResponse)
+			}, 0)
+
+			messages := []InputMessage{{Role: RoleUser, Content: strings.Repeat("word ", tt.words)}}
+			_, err := client.TextCompletion(context.Background(), messages, ModelConfig{})
+			if (counts > 0) != tt.wantCount {
+				t.Errorf("got %d countTokens calls, want a call %v", counts, tt.wantCount)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrorCategoryContextLength) || generates != 0 {
+					t.Errorf("got %v after %d requests, want a context length error before sending", err, generates)
+				}
+			} else if generates != 1 {
+				t.Errorf("got %d requests, want the request sent", generates)
+			}
+		})
+	}
+}
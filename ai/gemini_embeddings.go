@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	geminiDefaultEmbeddingModel = "text-embedding-004"
+	geminiMaxEmbeddingBatch     = 100 // Requests accepted by one BatchEmbedContents call
+)
+
+// Embed creates embeddings with EmbedContent for a single input and BatchEmbedContents
+// otherwise. The API does not report token usage for embeddings.
+func (c *GeminiClient) Embed(ctx context.Context, inputs []string, opts EmbedOptions) ([][]float32, TokenUsage, error) {
+	name := opts.Model
+	if name == "" {
+		name = geminiDefaultEmbeddingModel
+	}
+
+	model := c.client.EmbeddingModel(name)
+	switch opts.InputType {
+	case EmbedInputDocument:
+		model.TaskType = genai.TaskTypeRetrievalDocument
+	case EmbedInputQuery:
+		model.TaskType = genai.TaskTypeRetrievalQuery
+	}
+
+	vectors, usage, err := embedInBatches(ctx, inputs, geminiMaxEmbeddingBatch, func(ctx context.Context, batch []string) ([][]float32, TokenUsage, error) {
+		ctx, cancel := c.options.withTimeout(ctx)
+		defer cancel()
+
+		if len(batch) == 1 {
+			resp, err := model.EmbedContent(ctx, genai.Text(batch[0]))
+			if err != nil {
+				return nil, TokenUsage{}, geminiError(err)
+			}
+			if resp.Embedding == nil {
+				return nil, TokenUsage{}, fmt.Errorf("no embedding in response")
+			}
+			return [][]float32{resp.Embedding.Values}, TokenUsage{}, nil
+		}
+
+		request := model.NewBatch()
+		for _, text := range batch {
+			request.AddContent(genai.Text(text))
+		}
+
+		resp, err := model.BatchEmbedContents(ctx, request)
+		if err != nil {
+			return nil, TokenUsage{}, geminiError(err)
+		}
+		if len(resp.Embeddings) != len(batch) {
+			return nil, TokenUsage{}, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(resp.Embeddings))
+		}
+
+		vectors := make([][]float32, len(batch))
+		for i, embedding := range resp.Embeddings {
+			if embedding != nil {
+				vectors[i] = embedding.Values
+			}
+		}
+		return vectors, TokenUsage{}, nil
+	})
+	if err != nil {
+		return nil, usage, err
+	}
+
+	return shapeEmbeddings(vectors, opts), usage, nil
+}
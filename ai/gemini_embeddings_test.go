@@ -0,0 +1,81 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGeminiEmbed(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   int
+		opts     EmbedOptions
+		paths    []string
+		taskType int // Sent as the enum number, 1 for queries and 2 for documents
+	}{
+		{"single input", 1, EmbedOptions{InputType: EmbedInputQuery}, []string{":embedContent"}, 1},
+		{"batches", geminiMaxEmbeddingBatch + 1, EmbedOptions{InputType: EmbedInputDocument}, []string{":batchEmbedContents", ":embedContent"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			var taskType int
+			client := newGeminiTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.URL.Path[strings.LastIndex(r.URL.Path, ":"):])
+				body, _ := io.ReadAll(r.Body)
+				w.Header().Set("Content-Type", "application/json")
+
+				var single struct {
+					TaskType int `json:"taskType"`
+					Content  struct {
+						Parts []struct{ Text string } `json:"parts"`
+					} `json:"content"`
+				}
+				var batch struct {
+					Requests []json.RawMessage `json:"requests"`
+				}
+				if strings.HasSuffix(r.URL.Path, ":batchEmbedContents") {
+					json.Unmarshal(body, &batch)
+					var embeddings []string
+					for _, request := range batch.Requests {
+						json.Unmarshal(request, &single)
+						embeddings = append(embeddings, fmt.Sprintf(`{"values": [%s, 1]}`, single.Content.Parts[0].Text))
+					}
+					taskType = single.TaskType
+					fmt.Fprintf(w, `{"embeddings": [%s]}`, strings.Join(embeddings, ","))
+					return
+				}
+				json.Unmarshal(body, &single)
+				taskType = single.TaskType
+				fmt.Fprintf(w, `{"embedding": {"values": [%s, 1]}}`, single.Content.Parts[0].Text)
+			}, 0)
+
+			inputs := make([]string, tt.inputs)
+			for i := range inputs {
+				inputs[i] = fmt.Sprint(i)
+			}
+
+			vectors, _, err := client.Embed(context.Background(), inputs, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(paths, " ") != strings.Join(tt.paths, " ") || taskType != tt.taskType {
+				t.Errorf("got requests %v with task type %d", paths, taskType)
+			}
+			for i, vector := range vectors {
+				if vector[0] != float32(i) {
+					t.Fatalf("vector %d belongs to input %v", i, vector[0])
+				}
+			}
+			if len(vectors) != tt.inputs {
+				t.Errorf("got %d vectors, want %d", len(vectors), tt.inputs)
+			}
+		})
+	}
+}
@@ -2,17 +2,23 @@ package ai
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/pkoukk/tiktoken-go"
 )
 
 // OpenAIClient implements the Client interface for OpenAI
 type OpenAIClient struct {
-	client  *openai.Client
-	options ClientOptions
-	modelID string
+	client   *openai.Client
+	options  ClientOptions
+	modelID  string
+	encoding *tiktoken.Tiktoken // nil when the model has no known encoding
 }
 
 // NewOpenAIClient creates a new OpenAI client
@@ -25,14 +31,34 @@ func (c *OpenAIClient) Initialize(ctx context.Context, opts ClientOptions) error
 	// Apply options
 	c.options = opts
 	c.modelID = opts.ModelID
+	// Retries are left to WithRetry, so that attempts do not multiply
+	requestOptions := []option.RequestOption{option.WithAPIKey(opts.APIKey), option.WithMaxRetries(0)}
+
+	// Point at an OpenAI-compatible gateway if provided
+	if opts.EndpointURL != "" {
+		requestOptions = append(requestOptions, option.WithBaseURL(opts.EndpointURL))
+	}
+
+	// Apply the timeout to the wait for every response
+	if opts.Timeout > 0 {
+		requestOptions = append(requestOptions, option.WithHTTPClient(opts.httpClient()))
+	}
+
 	// Create the OpenAI client
-	c.client = openai.NewClient(option.WithAPIKey(opts.APIKey))
+	c.client = openai.NewClient(requestOptions...)
+
+	// Load the BPE ranks up front, so that requests never wait on a download
+	encoding, err := loadEncoding(opts.ModelID)
+	if err != nil {
+		return err
+	}
+	c.encoding = encoding
 
 	return nil
 }
 
-// TextCompletion sends a text request to OpenAI
-func (c *OpenAIClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+// chatParams converts messages and config into OpenAI chat completion parameters
+func (c *OpenAIClient) chatParams(messages []InputMessage, config ModelConfig) (openai.ChatCompletionNewParams, error) {
 	// Convert to OpenAI format messages
 	var openAIMessages []openai.ChatCompletionMessageParamUnion
 
@@ -44,12 +70,17 @@ func (c *OpenAIClient) TextCompletion(ctx context.Context, messages []InputMessa
 	// Add input messages
 	for _, msg := range messages {
 		switch msg.Role {
-		case "system":
+		case RoleSystem:
 			openAIMessages = append(openAIMessages, openai.SystemMessage(msg.Content))
-		case "assistant":
-			openAIMessages = append(openAIMessages, openai.AssistantMessage(msg.Content))
+		case RoleAssistant:
+			openAIMessages = append(openAIMessages, openAIAssistantMessage(msg))
+		case RoleTool:
+			if msg.ToolResult == nil {
+				return openai.ChatCompletionNewParams{}, fmt.Errorf("tool message without a tool result")
+			}
+			openAIMessages = append(openAIMessages, openai.ToolMessage(msg.ToolResult.ToolCallID, msg.ToolResult.Content))
 		default: // Default to user message
-			openAIMessages = append(openAIMessages, openai.UserMessage(msg.Content))
+			openAIMessages = append(openAIMessages, openAIUserMessage(msg))
 		}
 	}
 
@@ -67,35 +98,314 @@ func (c *OpenAIClient) TextCompletion(ctx context.Context, messages []InputMessa
 	// 	params.Stop = openai.F(config.StopSequences)
 	// }
 
+	// Add tool definitions if provided
+	if len(config.Tools) > 0 {
+		tools := make([]openai.ChatCompletionToolParam, 0, len(config.Tools))
+		for _, tool := range config.Tools {
+			tools = append(tools, openai.ChatCompletionToolParam{
+				Type: openai.F(openai.ChatCompletionToolTypeFunction),
+				Function: openai.F(openai.FunctionDefinitionParam{
+					Name:        openai.String(tool.Name),
+					Description: openai.String(tool.Description),
+					Parameters:  openai.F(openai.FunctionParameters(tool.Parameters)),
+				}),
+			})
+		}
+		params.Tools = openai.F(tools)
+	}
+
+	// Request structured output if a schema is provided
+	if config.ResponseSchema != nil {
+		params.ResponseFormat = openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](openai.ResponseFormatJSONSchemaParam{
+			Type: openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+			JSONSchema: openai.F(openai.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:   openai.F(config.ResponseSchema.schemaName()),
+				Schema: openai.F[interface{}](config.ResponseSchema.Schema),
+			}),
+		})
+	}
+
+	return params, nil
+}
+
+// openAIUserMessage converts a user turn, sending any images as content parts
+func openAIUserMessage(msg InputMessage) openai.ChatCompletionUserMessageParam {
+	if len(msg.Images) == 0 {
+		return openai.UserMessageParts(openai.TextPart(msg.Content))
+	}
+
+	var parts []openai.ChatCompletionContentPartUnionParam
+	if msg.Content != "" {
+		parts = append(parts, openai.TextPart(msg.Content))
+	}
+
+	for _, img := range msg.Images {
+		// Inline data is sent as a data URL, otherwise the URL is passed through
+		url := img.URL
+		if len(img.Data) > 0 {
+			url = fmt.Sprintf("data:%s;base64,%s", img.MIMEType(), base64.StdEncoding.EncodeToString(img.Data))
+		}
+
+		imageURL := openai.ChatCompletionContentPartImageImageURLParam{
+			URL: openai.F(url),
+		}
+		if img.Detail != "" {
+			imageURL.Detail = openai.F(openai.ChatCompletionContentPartImageImageURLDetail(img.Detail))
+		}
+
+		parts = append(parts, openai.ChatCompletionContentPartImageParam{
+			Type:     openai.F(openai.ChatCompletionContentPartImageTypeImageURL),
+			ImageURL: openai.F(imageURL),
+		})
+	}
+
+	return openai.UserMessageParts(parts...)
+}
+
+// openAIAssistantMessage converts an assistant turn, including any tool calls it made
+func openAIAssistantMessage(msg InputMessage) openai.ChatCompletionAssistantMessageParam {
+	assistant := openai.ChatCompletionAssistantMessageParam{
+		Role: openai.F(openai.ChatCompletionAssistantMessageParamRoleAssistant),
+	}
+	if msg.Content != "" {
+		assistant = openai.AssistantMessage(msg.Content)
+	}
+
+	if len(msg.ToolCalls) > 0 {
+		toolCalls := make([]openai.ChatCompletionMessageToolCallParam, 0, len(msg.ToolCalls))
+		for _, call := range msg.ToolCalls {
+			toolCalls = append(toolCalls, openai.ChatCompletionMessageToolCallParam{
+				ID:   openai.F(call.ID),
+				Type: openai.F(openai.ChatCompletionMessageToolCallTypeFunction),
+				Function: openai.F(openai.ChatCompletionMessageToolCallFunctionParam{
+					Name:      openai.F(call.Name),
+					Arguments: openai.F(call.Arguments),
+				}),
+			})
+		}
+		assistant.ToolCalls = openai.F(toolCalls)
+	}
+
+	return assistant
+}
+
+// TextCompletion sends a text request to OpenAI
+func (c *OpenAIClient) TextCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
+	// Fail fast when the conversation cannot fit the model, if it can be counted exactly
+	if tokens, exact := c.promptTokens(messages, config); exact {
+		if err := checkContextWindow(ProviderOpenAI, c.modelID, tokens, config); err != nil {
+			return Response{}, err
+		}
+	}
+
+	params, err := c.chatParams(messages, config)
+	if err != nil {
+		return Response{}, err
+	}
+
+	ctx, cancel := c.options.withTimeout(ctx)
+	defer cancel()
+
 	// Send request
 	response, err := c.client.Chat.Completions.New(ctx, params)
 	if err != nil {
-		return Response{}, fmt.Errorf("error getting response: %v", err)
+		return Response{}, openAIError(err)
 	}
 
 	// Format the standard response
 	result := Response{
-		Raw: response,
+		Raw:      response,
+		Provider: ProviderOpenAI,
+		Model:    response.Model,
 		TokenUsage: TokenUsage{
 			InputTokens:  int(response.Usage.PromptTokens),
 			OutputTokens: int(response.Usage.CompletionTokens),
 			TotalTokens:  int(response.Usage.TotalTokens),
+			CachedTokens: int(response.Usage.PromptTokensDetails.CachedTokens),
 		},
 	}
 
-	// Extract text from response
+	// Extract text and tool calls from response
 	if len(response.Choices) > 0 {
-		result.Text = response.Choices[0].Message.Content
+		choice := response.Choices[0]
+		result.Text = choice.Message.Content
+		result.FinishReason = string(choice.FinishReason)
+		for _, call := range choice.Message.ToolCalls {
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
 	}
 
 	return result, nil
 }
 
-// ImageRecognition sends images with optional text to OpenAI
+// StreamCompletion streams a chat completion from OpenAI
+func (c *OpenAIClient) StreamCompletion(ctx context.Context, messages []InputMessage, config ModelConfig) (<-chan StreamEvent, error) {
+	// Fail fast when the conversation cannot fit the model, if it can be counted exactly
+	if tokens, exact := c.promptTokens(messages, config); exact {
+		if err := checkContextWindow(ProviderOpenAI, c.modelID, tokens, config); err != nil {
+			return nil, err
+		}
+	}
+
+	params, err := c.chatParams(messages, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for a final usage chunk at the end of the stream
+	params.StreamOptions = openai.F(openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.F(true),
+	})
+
+	stream := c.client.Chat.Completions.NewStreaming(ctx, params)
+
+	events := make(chan StreamEvent)
+	go func() {
+		defer close(events)
+		defer stream.Close()
+
+		// Tool call arguments arrive in fragments keyed by index
+		var toolCalls []*ToolCall
+
+		for stream.Next() {
+			chunk := stream.Current()
+
+			if len(chunk.Choices) > 0 {
+				choice := chunk.Choices[0]
+				if choice.Delta.Content != "" {
+					if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventText, Text: choice.Delta.Content}) {
+						return
+					}
+				}
+				for _, delta := range choice.Delta.ToolCalls {
+					for int(delta.Index) >= len(toolCalls) {
+						toolCalls = append(toolCalls, &ToolCall{})
+					}
+					call := toolCalls[delta.Index]
+					if delta.ID != "" {
+						call.ID = delta.ID
+					}
+					call.Name += delta.Function.Name
+					call.Arguments += delta.Function.Arguments
+				}
+				if choice.FinishReason != "" {
+					for _, call := range toolCalls {
+						if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventToolCall, ToolCall: call}) {
+							return
+						}
+					}
+					toolCalls = nil
+					if !sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventFinish, FinishReason: string(choice.FinishReason)}) {
+						return
+					}
+				}
+			}
+
+			// The usage chunk arrives last with an empty choices list
+			if chunk.Usage.TotalTokens > 0 {
+				usage := TokenUsage{
+					InputTokens:  int(chunk.Usage.PromptTokens),
+					OutputTokens: int(chunk.Usage.CompletionTokens),
+					TotalTokens:  int(chunk.Usage.TotalTokens),
+					CachedTokens: int(chunk.Usage.PromptTokensDetails.CachedTokens),
+				}
+				event := StreamEvent{Type: StreamEventUsage, TokenUsage: usage, Provider: ProviderOpenAI, Model: chunk.Model}
+				if !sendStreamEvent(ctx, events, event) {
+					return
+				}
+			}
+		}
+
+		if err := stream.Err(); err != nil {
+			sendStreamEvent(ctx, events, StreamEvent{Type: StreamEventError, Err: openAIError(err)})
+		}
+	}()
+
+	return events, nil
+}
+
+// ImageRecognition sends images with optional text to OpenAI.
+// Images are sent as content parts of the chat history, so this shares the TextCompletion path.
 func (c *OpenAIClient) ImageRecognition(ctx context.Context, messages []InputMessage, config ModelConfig) (Response, error) {
-	// OpenAI API requires a different endpoint for image analysis
-	// We're using GPT-4 Vision for this example
-	return Response{}, fmt.Errorf("method not implemented for OpenAI client yet")
+	return c.TextCompletion(ctx, messages, config)
+}
+
+// CountTokens counts prompt tokens locally with the model's cl100k_base or o200k_base
+// BPE encoding, including the chat format overhead and image tiles. Models without a
+// known encoding get a local estimate.
+func (c *OpenAIClient) CountTokens(ctx context.Context, messages []InputMessage, config ModelConfig) (int, error) {
+	tokens, _ := c.promptTokens(messages, config)
+	return tokens, nil
+}
+
+// promptTokens counts prompt tokens with the model's BPE encoding. exact is false when
+// the model has no known encoding and the count is an estimate.
+func (c *OpenAIClient) promptTokens(messages []InputMessage, config ModelConfig) (tokens int, exact bool) {
+	if c.encoding != nil {
+		return estimateTokens(messages, config, bpeCounter(c.encoding), openAIImageTokens), true
+	}
+	return estimateTokens(messages, config, countTextTokens, openAIImageTokens), false
+}
+
+// openAIImageTokens prices an image the way OpenAI vision models do: a base cost plus a cost
+// per 512px tile, after fitting the image within 2048px and its short side within 768px.
+// Images of unknown size are priced as 1024x1024.
+func openAIImageTokens(img Image) int {
+	const baseTokens, tileTokens = 85, 170
+
+	if img.Detail == "low" {
+		return baseTokens
+	}
+
+	width, height, ok := imageDimensions(img)
+	if !ok {
+		width, height = 1024, 1024
+	}
+
+	w, h := float64(width), float64(height)
+	if scale := 2048 / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := 768 / math.Min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+
+	tiles := int(math.Ceil(w/512) * math.Ceil(h/512))
+	return baseTokens + tileTokens*tiles
+}
+
+// openAIError translates an OpenAI SDK failure into an *Error
+func openAIError(err error) error {
+	aiErr := newError(ProviderOpenAI, err)
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+			aiErr.RequestID = header.Get("x-request-id")
+		}
+		aiErr.setStatus(apiErr.StatusCode, header)
+		if apiErr.Message != "" {
+			aiErr.Message = apiErr.Message
+		}
+
+		switch apiErr.Code {
+		case "context_length_exceeded":
+			aiErr.Category = ErrorCategoryContextLength
+		case "insufficient_quota":
+			aiErr.Category = ErrorCategoryQuota
+		case "content_filter", "content_policy_violation":
+			aiErr.Category = ErrorCategoryContentFiltered
+		}
+	}
+
+	aiErr.refine()
+	return aiErr
 }
 
 // Close releases resources
@@ -0,0 +1,272 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+const openAITestCompletion = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"created": 1700000000,
+	"model": "test-model",
+	"choices": [{"index": 0, "message": {"role": "assistant", "content": "hello"}, "finish_reason": "stop"}],
+	"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}
+}`
+
+// newOpenAITestClient points an OpenAI client at handler
+func newOpenAITestClient(t *testing.T, handler http.HandlerFunc, timeout int) *OpenAIClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewOpenAIClient()
+	// A model without a known encoding keeps tokenizer downloads out of the tests
+	err := client.Initialize(context.Background(), ClientOptions{
+		APIKey:      "test-key",
+		ModelID:     "test-model",
+		EndpointURL: server.URL,
+		Timeout:     timeout,
+	})
+	if err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	return client
+}
+
+var openAITestMessages = []InputMessage{{Role: RoleUser, Content: "hi"}}
+
+func TestOpenAIEndpointURL(t *testing.T) {
+	var path, auth, body string
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, openAITestCompletion)
+	}, 0)
+
+	response, err := client.TextCompletion(context.Background(), openAITestMessages, ModelConfig{MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasSuffix(path, "/chat/completions") {
+		t.Errorf("request went to %q", path)
+	}
+	if auth != "Bearer test-key" {
+		t.Errorf("got Authorization %q", auth)
+	}
+	if !strings.Contains(body, `"test-model"`) {
+		t.Errorf("request body does not name the model: %s", body)
+	}
+	if response.Text != "hello" || response.Provider != ProviderOpenAI || response.TokenUsage.TotalTokens != 4 {
+		t.Errorf("unexpected response: %+v", response)
+	}
+}
+
+func TestOpenAITimeout(t *testing.T) {
+	release := make(chan struct{})
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}, 1)
+	// Registered after the server, so it runs before the server waits for the handler
+	t.Cleanup(func() { close(release) })
+
+	start := time.Now()
+	_, err := client.TextCompletion(context.Background(), openAITestMessages, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryTimeout) {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("request took %s despite a 1s timeout", elapsed)
+	}
+}
+
+func TestOpenAIStreamOutlivesTimeout(t *testing.T) {
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range []string{"one ", "two ", "three"} {
+			io.WriteString(w, `data: {"id": "chatcmpl-1", "object": "chat.completion.chunk", "created": 1700000000, "model": "test-model", "choices": [{"index": 0, "delta": {"content": "`+word+`"}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(600 * time.Millisecond)
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}, 1)
+
+	// The response starts at once, so the stream may run past the 1s timeout
+	events, err := client.StreamCompletion(context.Background(), openAITestMessages, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	response, err := CollectStream(events)
+	if err != nil || response.Text != "one two three" {
+		t.Errorf("got %q, %v, want the whole stream", response.Text, err)
+	}
+}
+
+func TestOpenAIErrorClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		header     map[string]string
+		body       string
+		want       ErrorCategory
+		retryAfter time.Duration
+	}{
+		{
+			name:       "rate limit",
+			status:     http.StatusTooManyRequests,
+			header:     map[string]string{"Retry-After": "12", "x-request-id": "req-429"},
+			body:       `{"error": {"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"}}`,
+			want:       ErrorCategoryRateLimited,
+			retryAfter: 12 * time.Second,
+		},
+		{
+			name:   "quota",
+			status: http.StatusTooManyRequests,
+			body:   `{"error": {"message": "You exceeded your current quota", "type": "insufficient_quota", "code": "insufficient_quota"}}`,
+			want:   ErrorCategoryQuota,
+		},
+		{
+			name:   "context length",
+			status: http.StatusBadRequest,
+			body:   `{"error": {"message": "This model's maximum context length is 8192 tokens", "type": "invalid_request_error", "code": "context_length_exceeded"}}`,
+			want:   ErrorCategoryContextLength,
+		},
+		{
+			name:   "auth",
+			status: http.StatusUnauthorized,
+			body:   `{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error", "code": "invalid_api_key"}}`,
+			want:   ErrorCategoryAuth,
+		},
+		{
+			name:   "server",
+			status: http.StatusServiceUnavailable,
+			body:   `{"error": {"message": "The server is overloaded", "type": "server_error"}}`,
+			want:   ErrorCategoryServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.header {
+					w.Header().Set(key, value)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}, 0)
+
+			_, err := client.TextCompletion(context.Background(), openAITestMessages, ModelConfig{})
+			var aiErr *Error
+			if !errors.As(err, &aiErr) {
+				t.Fatalf("got %v, want an *Error", err)
+			}
+			if aiErr.Category != tt.want || aiErr.StatusCode != tt.status || aiErr.Provider != ProviderOpenAI {
+				t.Errorf("got %s (status %d, provider %s), want %s (status %d)", aiErr.Category, aiErr.StatusCode, aiErr.Provider, tt.want, tt.status)
+			}
+			if aiErr.RetryAfter != tt.retryAfter {
+				t.Errorf("got RetryAfter %s, want %s", aiErr.RetryAfter, tt.retryAfter)
+			}
+			if requestID := tt.header["x-request-id"]; aiErr.RequestID != requestID {
+				t.Errorf("got request ID %q, want %q", aiErr.RequestID, requestID)
+			}
+		})
+	}
+}
+
+func TestOpenAIRetryOwnsAttempts(t *testing.T) {
+	var requests atomic.Int32
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `{"error": {"message": "internal error", "type": "server_error"}}`)
+			return
+		}
+		io.WriteString(w, openAITestCompletion)
+	}, 0)
+
+	response, err := WithRetry(client, fastRetry).TextCompletion(context.Background(), openAITestMessages, ModelConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Each attempt is a single HTTP request, since the SDK does not retry on its own
+	if response.Attempts != 3 || requests.Load() != 3 {
+		t.Errorf("got %d attempts and %d requests, want 3 of each", response.Attempts, requests.Load())
+	}
+}
+
+func TestOpenAIRetryGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, `{"error": {"message": "bad gateway", "type": "server_error"}}`)
+	}, 0)
+
+	policy := fastRetry
+	policy.MaxAttempts = 2
+	_, err := WithRetry(client, policy).TextCompletion(context.Background(), openAITestMessages, ModelConfig{})
+	if !errors.Is(err, ErrorCategoryServer) || requests.Load() != 2 {
+		t.Errorf("got %v after %d requests, want a server error after 2", err, requests.Load())
+	}
+}
+
+// failingBpeLoader stands in for an offline host without cached ranks
+type failingBpeLoader struct{ calls int }
+
+func (l *failingBpeLoader) LoadTiktokenBpe(string) (map[string]int, error) {
+	l.calls++
+	return nil, errors.New("network unreachable")
+}
+
+func TestOpenAIInitializeLoadsEncoding(t *testing.T) {
+	loader := &failingBpeLoader{}
+	tiktoken.SetBpeLoader(loader)
+	t.Cleanup(func() { tiktoken.SetBpeLoader(tiktoken.NewDefaultBpeLoader()) })
+
+	tests := []struct {
+		modelID string
+		wantErr bool
+	}{
+		{"gpt-4o-mini", true},
+		{"gpt-3.5-turbo", true},
+		{"test-model", false},
+	}
+
+	for _, tt := range tests {
+		loader.calls = 0
+		err := NewOpenAIClient().Initialize(context.Background(), ClientOptions{APIKey: "test-key", ModelID: tt.modelID})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want error %v", tt.modelID, err, tt.wantErr)
+		}
+		if (loader.calls > 0) != tt.wantErr {
+			t.Errorf("%s: loader called %d times", tt.modelID, loader.calls)
+		}
+	}
+
+	// Requests never load ranks, so an unknown model keeps using the estimate
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, openAITestCompletion)
+	}, 0)
+	loader.calls = 0
+	if _, err := client.TextCompletion(context.Background(), openAITestMessages, ModelConfig{}); err != nil || loader.calls != 0 {
+		t.Errorf("got %v with %d rank loads during a request", err, loader.calls)
+	}
+}
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+const (
+	openAIDefaultEmbeddingModel = "text-embedding-3-small"
+	openAIMaxEmbeddingBatch     = 2048 // Inputs accepted by one embeddings request
+)
+
+// Embed creates embeddings with the OpenAI embeddings API. text-embedding-3 models
+// shorten vectors server-side when Dimensions is set; older models are truncated locally.
+func (c *OpenAIClient) Embed(ctx context.Context, inputs []string, opts EmbedOptions) ([][]float32, TokenUsage, error) {
+	model := opts.Model
+	if model == "" {
+		model = openAIDefaultEmbeddingModel
+	}
+
+	vectors, usage, err := embedInBatches(ctx, inputs, openAIMaxEmbeddingBatch, func(ctx context.Context, batch []string) ([][]float32, TokenUsage, error) {
+		// The timeout applies to each request, not to all batches together
+		ctx, cancel := c.options.withTimeout(ctx)
+		defer cancel()
+
+		params := openai.EmbeddingNewParams{
+			Input:          openai.F[openai.EmbeddingNewParamsInputUnion](openai.EmbeddingNewParamsInputArrayOfStrings(batch)),
+			Model:          openai.F(openai.EmbeddingModel(model)),
+			EncodingFormat: openai.F(openai.EmbeddingNewParamsEncodingFormatFloat),
+		}
+		if opts.Dimensions > 0 && strings.HasPrefix(model, "text-embedding-3") {
+			params.Dimensions = openai.Int(int64(opts.Dimensions))
+		}
+
+		response, err := c.client.Embeddings.New(ctx, params)
+		if err != nil {
+			return nil, TokenUsage{}, openAIError(err)
+		}
+		if len(response.Data) != len(batch) {
+			return nil, TokenUsage{}, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(response.Data))
+		}
+
+		// Data is ordered by index, which is not guaranteed to be the response order
+		vectors := make([][]float32, len(batch))
+		for _, data := range response.Data {
+			if data.Index < 0 || int(data.Index) >= len(batch) {
+				return nil, TokenUsage{}, fmt.Errorf("embedding index %d out of range", data.Index)
+			}
+			vector := make([]float32, len(data.Embedding))
+			for i, v := range data.Embedding {
+				vector[i] = float32(v)
+			}
+			vectors[data.Index] = vector
+		}
+
+		return vectors, newTokenUsage(int(response.Usage.PromptTokens), 0), nil
+	})
+	if err != nil {
+		return nil, usage, err
+	}
+
+	return shapeEmbeddings(vectors, opts), usage, nil
+}
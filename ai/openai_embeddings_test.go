@@ -0,0 +1,98 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIEmbed(t *testing.T) {
+	var requests []map[string]interface{}
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		requests = append(requests, request)
+
+		// Answer in reverse order, so that only the index ties a vector to its input
+		inputs := request["input"].([]interface{})
+		var data []string
+		for i := len(inputs) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"object": "embedding", "index": %d, "embedding": [%d, 0]}`, i, len(inputs[i].(string))))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"object": "list", "model": "text-embedding-3-small", "data": [%s], "usage": {"prompt_tokens": %d, "total_tokens": %d}}`,
+			strings.Join(data, ","), len(inputs), len(inputs))
+	}, 0)
+
+	inputs := make([]string, openAIMaxEmbeddingBatch+1)
+	for i := range inputs {
+		inputs[i] = strings.Repeat("x", i%7+1)
+	}
+
+	vectors, usage, err := client.Embed(context.Background(), inputs, EmbedOptions{Dimensions: 256, Normalize: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 2 || len(requests[0]["input"].([]interface{})) != openAIMaxEmbeddingBatch {
+		t.Fatalf("got %d requests, want a full batch and one more", len(requests))
+	}
+	if requests[0]["model"] != openAIDefaultEmbeddingModel || requests[0]["dimensions"] != 256.0 {
+		t.Errorf("unexpected request: model %v, dimensions %v", requests[0]["model"], requests[0]["dimensions"])
+	}
+	if len(vectors) != len(inputs) || usage.InputTokens != len(inputs) {
+		t.Fatalf("got %d vectors and %d tokens", len(vectors), usage.InputTokens)
+	}
+	// The raw vectors were [len(input), 0], so normalized they are all [1, 0]
+	for i, vector := range vectors {
+		if vector[0] != 1 || vector[1] != 0 {
+			t.Fatalf("vector %d is %v", i, vector)
+		}
+	}
+}
+
+func TestOpenAIEmbedOrdersByIndex(t *testing.T) {
+	client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object": "list", "data": [
+			{"object": "embedding", "index": 2, "embedding": [2]},
+			{"object": "embedding", "index": 0, "embedding": [0]},
+			{"object": "embedding", "index": 1, "embedding": [1]}
+		], "usage": {"prompt_tokens": 3, "total_tokens": 3}}`)
+	}, 0)
+
+	vectors, _, err := client.Embed(context.Background(), []string{"a", "b", "c"}, EmbedOptions{Model: "text-embedding-ada-002", Dimensions: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, vector := range vectors {
+		if len(vector) != 1 || vector[0] != float32(i) {
+			t.Errorf("vector %d is %v", i, vector)
+		}
+	}
+}
+
+func TestOpenAIEmbedRejectsBadResponses(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"missing embedding", `[{"object": "embedding", "index": 0, "embedding": [0]}]`},
+		{"index out of range", `[{"object": "embedding", "index": 0, "embedding": [0]}, {"object": "embedding", "index": 5, "embedding": [1]}]`},
+	}
+
+	for _, tt := range tests {
+		client := newOpenAITestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"object": "list", "data": %s, "usage": {"prompt_tokens": 2, "total_tokens": 2}}`, tt.data)
+		}, 0)
+
+		if _, _, err := client.Embed(context.Background(), []string{"a", "b"}, EmbedOptions{}); err == nil {
+			t.Errorf("%s: accepted the response", tt.name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// openAIEncodings maps OpenAI model ID prefixes to their BPE encoding
+var openAIEncodings = map[string]string{
+	"gpt-4o":          "o200k_base",
+	"gpt-4.1":         "o200k_base",
+	"gpt-4.5":         "o200k_base",
+	"o1":              "o200k_base",
+	"o3":              "o200k_base",
+	"o4":              "o200k_base",
+	"gpt-4":           "cl100k_base",
+	"gpt-3.5-turbo":   "cl100k_base",
+	"text-embedding-": "cl100k_base",
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*tiktoken.Tiktoken{}
+)
+
+// loadEncoding returns the BPE encoding of an OpenAI model, or nil for models without a
+// known encoding. tiktoken-go downloads the ranks on first use and caches them in
+// TIKTOKEN_CACHE_DIR; set tiktoken.SetBpeLoader to an offline loader to use bundled
+// ranks instead. Loaded encodings are shared, and failed loads are retried on the next call.
+func loadEncoding(modelID string) (*tiktoken.Tiktoken, error) {
+	name, ok := longestPrefixMatch(openAIEncodings, normalizeModelID(modelID))
+	if !ok {
+		return nil, nil
+	}
+
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+
+	if encoding, ok := encodings[name]; ok {
+		return encoding, nil
+	}
+	encoding, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s BPE ranks: %w", name, err)
+	}
+	encodings[name] = encoding
+	return encoding, nil
+}
+
+// bpeCounter counts text tokens with a BPE encoding
+func bpeCounter(encoding *tiktoken.Tiktoken) func(string) int {
+	return func(text string) int {
+		if text == "" {
+			return 0
+		}
+		return len(encoding.EncodeOrdinary(text))
+	}
+}
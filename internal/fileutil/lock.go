@@ -0,0 +1,22 @@
+package fileutil
+
+import "os"
+
+// LockFile takes an exclusive advisory lock on path, creating the file if needed, and
+// blocks until the lock is free. The lock also excludes other processes and is held until
+// unlock is called. Lock a separate file rather than one that is replaced by renames.
+func LockFile(path string) (unlock func() error, err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer file.Close()
+		return unlockFile(file)
+	}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package fileutil
+
+import "os"
+
+// lockFile is a no-op on platforms without file locks, where only one process is expected
+func lockFile(file *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without file locks
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultChunkSize    = 1000
+	defaultChunkOverlap = 200
+)
+
+// Chunker splits text into overlapping chunks small enough to embed. Chunks end at a
+// paragraph, sentence or word boundary when one falls in the second half of the window.
+type Chunker struct {
+	Size    int // Maximum characters per chunk, defaults to 1000
+	Overlap int // Characters repeated from the previous chunk, defaults to 200, negative for none
+}
+
+// Split returns the chunks of text in order. Whitespace-only text yields no chunks.
+func (c Chunker) Split(text string) []string {
+	size := c.Size
+	if size <= 0 {
+		size = defaultChunkSize
+	}
+	overlap := c.Overlap
+	if overlap == 0 {
+		overlap = defaultChunkOverlap
+	}
+	// Overlap must leave room for progress
+	overlap = min(max(overlap, 0), size/2)
+
+	runes := []rune(text)
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			end = breakPoint(runes, start+size/2, end)
+		}
+
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		// Begin the next chunk overlap characters back, moved forward to a word start
+		next := max(end-overlap, start+1)
+		for next < end && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// breakPoint returns the best place in (from, to] to end a chunk: after a paragraph
+// break, then after a sentence, then after any whitespace. It returns to if there is none.
+func breakPoint(runes []rune, from, to int) int {
+	sentence, word := 0, 0
+	for i := to; i > from; i-- {
+		r := runes[i-1]
+		if !unicode.IsSpace(r) {
+			continue
+		}
+		var prev rune
+		if i >= 2 {
+			prev = runes[i-2]
+		}
+		if r == '\n' && prev == '\n' {
+			return i
+		}
+		if sentence == 0 && strings.ContainsRune(".!?", prev) {
+			sentence = i
+		}
+		if word == 0 {
+			word = i
+		}
+	}
+
+	switch {
+	case sentence > 0:
+		return sentence
+	case word > 0:
+		return word
+	default:
+		return to
+	}
+}
@@ -0,0 +1,54 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkerSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunker Chunker
+		text    string
+		want    []string
+	}{
+		{"empty", Chunker{}, "", nil},
+		{"whitespace only", Chunker{}, " \n\t ", nil},
+		{"fits in one chunk", Chunker{}, "  short text  ", []string{"short text"}},
+		{"breaks at a paragraph", Chunker{Size: 30, Overlap: -1}, "First paragraph here.\n\nSecond one follows.", []string{"First paragraph here.", "Second one follows."}},
+		{"breaks at a sentence", Chunker{Size: 30, Overlap: -1}, "One two three four. Five six seven eight nine", []string{"One two three four.", "Five six seven eight nine"}},
+		{"ignores breaks in the first half", Chunker{Size: 30, Overlap: -1}, "One two three. Four five six seven eight", []string{"One two three. Four five six", "seven eight"}},
+		{"breaks at a word", Chunker{Size: 12, Overlap: -1}, "alpha beta gamma delta", []string{"alpha beta", "gamma delta"}},
+		{"overlaps whole words", Chunker{Size: 16, Overlap: 6}, "alpha beta gamma delta epsilon", []string{"alpha beta", "beta gamma", "gamma delta", "delta epsilon"}},
+		{"no boundary", Chunker{Size: 4, Overlap: -1}, "abcdefghij", []string{"abcd", "efgh", "ij"}},
+	}
+
+	for _, tt := range tests {
+		got := tt.chunker.Split(tt.text)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChunkerCoversText(t *testing.T) {
+	text := strings.Repeat("Wörter mit Umlauten füllen diesen Satz. ", 200)
+	chunker := Chunker{Size: 120, Overlap: 30}
+
+	chunks := chunker.Split(text)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > chunker.Size {
+			t.Errorf("chunk %d has %d characters", i, n)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("chunk %d splits a character", i)
+		}
+	}
+	if !strings.HasPrefix(text, chunks[0]) || !strings.HasSuffix(strings.TrimSpace(text), chunks[len(chunks)-1]) {
+		t.Error("chunks do not cover the start and end of the text")
+	}
+}
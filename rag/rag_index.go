@@ -0,0 +1,285 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/NaheedRayan/openrouter-go/internal/fileutil"
+)
+
+// Similarity selects how an Index scores a query against stored vectors
+type Similarity int
+
+const (
+	// Cosine compares vector directions and ignores their lengths
+	Cosine Similarity = iota
+	// DotProduct multiplies vectors; equal to Cosine for normalized vectors and cheaper
+	DotProduct
+)
+
+// Document is a piece of text stored in an Index with its embedding
+type Document struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"vector"`
+}
+
+// Result is a document matched by a search, with its similarity score
+type Result struct {
+	Document
+	Score float64
+}
+
+// Filter restricts a search to documents whose metadata has every listed key and value
+type Filter map[string]string
+
+// matches reports whether metadata satisfies the filter
+func (f Filter) matches(metadata map[string]string) bool {
+	for key, value := range f {
+		if v, ok := metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Index is an in-memory vector index searched by brute force, which is fast enough
+// for tens of thousands of documents. The zero value is an empty cosine index.
+// An Index is safe for concurrent use.
+type Index struct {
+	Similarity Similarity
+
+	mu    sync.RWMutex
+	docs  []Document
+	norms []float64 // Vector lengths, kept alongside docs for cosine scoring
+	byID  map[string]int
+}
+
+// NewIndex creates an empty index
+func NewIndex(similarity Similarity) *Index {
+	return &Index{Similarity: similarity, byID: map[string]int{}}
+}
+
+// Add stores documents, replacing any with the same ID. If any document is invalid,
+// none are stored.
+func (x *Index) Add(docs ...Document) error {
+	return x.replace(nil, docs)
+}
+
+// ReplaceMatching removes every document whose metadata matches filter and stores docs
+// in one step. If any document is invalid, the index is left unchanged.
+func (x *Index) ReplaceMatching(filter Filter, docs ...Document) error {
+	return x.replace(func(doc Document) bool { return filter.matches(doc.Metadata) }, docs)
+}
+
+// replace validates docs as a batch, then removes the documents selected by remove,
+// if any, and stores docs
+func (x *Index) replace(remove func(Document) bool, docs []Document) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	// Vectors must match the documents that stay, or each other if none do
+	dims := 0
+	for _, doc := range x.docs {
+		if remove == nil || !remove(doc) {
+			dims = len(doc.Vector)
+			break
+		}
+	}
+	for _, doc := range docs {
+		if doc.ID == "" {
+			return fmt.Errorf("document has no ID")
+		}
+		if len(doc.Vector) == 0 {
+			return fmt.Errorf("document %s has no vector", doc.ID)
+		}
+		if dims == 0 {
+			dims = len(doc.Vector)
+		}
+		if len(doc.Vector) != dims {
+			return fmt.Errorf("document %s has %d dimensions, index has %d", doc.ID, len(doc.Vector), dims)
+		}
+	}
+
+	if remove != nil {
+		var ids []string
+		for _, doc := range x.docs {
+			if remove(doc) {
+				ids = append(ids, doc.ID)
+			}
+		}
+		x.delete(ids)
+	}
+
+	if x.byID == nil {
+		x.byID = map[string]int{}
+	}
+	for _, doc := range docs {
+		if i, ok := x.byID[doc.ID]; ok {
+			x.docs[i] = doc
+			x.norms[i] = norm(doc.Vector)
+			continue
+		}
+		x.byID[doc.ID] = len(x.docs)
+		x.docs = append(x.docs, doc)
+		x.norms = append(x.norms, norm(doc.Vector))
+	}
+
+	return nil
+}
+
+// Delete removes documents by ID. Missing IDs are ignored.
+func (x *Index) Delete(ids ...string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.delete(ids)
+}
+
+// delete removes documents by ID. The caller must hold the write lock.
+func (x *Index) delete(ids []string) {
+	for _, id := range ids {
+		i, ok := x.byID[id]
+		if !ok {
+			continue
+		}
+
+		// Move the last document into the gap
+		last := len(x.docs) - 1
+		x.docs[i], x.norms[i] = x.docs[last], x.norms[last]
+		x.byID[x.docs[i].ID] = i
+		x.docs, x.norms = x.docs[:last], x.norms[:last]
+		delete(x.byID, id)
+	}
+}
+
+// DeleteMatching removes every document whose metadata matches filter and returns how many went
+func (x *Index) DeleteMatching(filter Filter) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var ids []string
+	for _, doc := range x.docs {
+		if filter.matches(doc.Metadata) {
+			ids = append(ids, doc.ID)
+		}
+	}
+	x.delete(ids)
+	return len(ids)
+}
+
+// Get returns the document stored under id
+func (x *Index) Get(id string) (Document, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	i, ok := x.byID[id]
+	if !ok {
+		return Document{}, false
+	}
+	return x.docs[i], true
+}
+
+// Len returns the number of documents
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// Search returns the k documents most similar to query that match filter, best first.
+// A nil filter matches every document. A query whose dimensions differ from the index
+// is an error, since it usually means it was embedded with a different model.
+func (x *Index) Search(query []float32, k int, filter Filter) ([]Result, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	if k <= 0 || len(x.docs) == 0 {
+		return nil, nil
+	}
+	if len(query) != len(x.docs[0].Vector) {
+		return nil, fmt.Errorf("query has %d dimensions, index has %d", len(query), len(x.docs[0].Vector))
+	}
+
+	queryNorm := norm(query)
+	var results []Result
+	for i, doc := range x.docs {
+		if !filter.matches(doc.Metadata) {
+			continue
+		}
+
+		score := dot(query, doc.Vector)
+		if x.Similarity == Cosine {
+			if queryNorm == 0 || x.norms[i] == 0 {
+				score = 0
+			} else {
+				score /= queryNorm * x.norms[i]
+			}
+		}
+		results = append(results, Result{Document: doc, Score: score})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// indexFile is the on-disk form of an Index
+type indexFile struct {
+	Similarity Similarity `json:"similarity"`
+	Documents  []Document `json:"documents"`
+}
+
+// Save writes the index to path as JSON, through a temporary file so that a crash
+// never leaves a partially written index behind
+func (x *Index) Save(path string) error {
+	x.mu.RLock()
+	data, err := json.Marshal(indexFile{Similarity: x.Similarity, Documents: x.docs})
+	x.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("error encoding index: %w", err)
+	}
+
+	return fileutil.WriteFileAtomic(path, data)
+}
+
+// LoadIndex reads an index written by Save
+func LoadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file indexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error decoding index: %w", err)
+	}
+
+	x := NewIndex(file.Similarity)
+	if err := x.Add(file.Documents...); err != nil {
+		return nil, fmt.Errorf("error loading index: %w", err)
+	}
+	return x, nil
+}
+
+// dot returns the dot product of two vectors of equal length
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// norm returns the length of a vector
+func norm(v []float32) float64 {
+	return math.Sqrt(dot(v, v))
+}
@@ -0,0 +1,165 @@
+package rag
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// indexTestDocuments are three documents pointing along different axes
+var indexTestDocuments = []Document{
+	{ID: "x", Text: "along x", Metadata: map[string]string{"lang": "en"}, Vector: []float32{1, 0, 0}},
+	{ID: "xy", Text: "between x and y", Metadata: map[string]string{"lang": "de"}, Vector: []float32{2, 2, 0}},
+	{ID: "z", Text: "along z", Metadata: map[string]string{"lang": "en", "draft": "yes"}, Vector: []float32{0, 0, 3}},
+}
+
+// ids lists the document IDs of results in order
+func ids(results []Result) []string {
+	var ids []string
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestIndexSearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		similarity Similarity
+		query      []float32
+		k          int
+		filter     Filter
+		want       []string
+		top        float64
+	}{
+		{"cosine", Cosine, []float32{1, 0.1, 0}, 3, nil, []string{"x", "xy", "z"}, 0.995},
+		{"cosine ignores length", Cosine, []float32{0, 0, 0.1}, 1, nil, []string{"z"}, 1},
+		{"dot product favors length", DotProduct, []float32{1, 0.1, 0}, 2, nil, []string{"xy", "x"}, 2.2},
+		{"filter", Cosine, []float32{1, 1, 0}, 3, Filter{"lang": "en"}, []string{"x", "z"}, math.Sqrt2 / 2},
+		{"filter on two keys", Cosine, []float32{1, 1, 0}, 3, Filter{"lang": "en", "draft": "yes"}, []string{"z"}, 0},
+		{"filter without matches", Cosine, []float32{1, 1, 0}, 3, Filter{"lang": "fr"}, nil, 0},
+		{"zero query", Cosine, []float32{0, 0, 0}, 1, nil, []string{"x"}, 0},
+		{"k of zero", Cosine, []float32{1, 0, 0}, 0, nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		index := NewIndex(tt.similarity)
+		if err := index.Add(indexTestDocuments...); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+
+		results, err := index.Search(tt.query, tt.k, tt.filter)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(ids(results), tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids(results), tt.want)
+		}
+		if len(results) > 0 && math.Abs(results[0].Score-tt.top) > 0.01 {
+			t.Errorf("%s: got top score %f, want %f", tt.name, results[0].Score, tt.top)
+		}
+	}
+}
+
+func TestIndexAddValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		docs []Document
+	}{
+		{"missing ID", []Document{{Vector: []float32{1, 0, 0}}}},
+		{"missing vector", []Document{{ID: "a"}}},
+		{"wrong dimensions", []Document{{ID: "a", Vector: []float32{1, 0, 0}}, {ID: "b", Vector: []float32{1, 0}}}},
+	}
+
+	for _, tt := range tests {
+		index := NewIndex(Cosine)
+		if err := index.Add(tt.docs...); err == nil || index.Len() != 0 {
+			t.Errorf("%s: got %v with %d documents stored, want an error and none", tt.name, err, index.Len())
+		}
+	}
+
+	index := NewIndex(Cosine)
+	index.Add(indexTestDocuments...)
+	if err := index.Add(Document{ID: "w", Vector: []float32{1, 0}}); err == nil {
+		t.Error("added a document with dimensions that differ from the index")
+	}
+	if _, err := index.Search([]float32{1, 0}, 1, nil); err == nil {
+		t.Error("searched with a query that has the wrong dimensions")
+	}
+}
+
+func TestIndexReplaceAndDelete(t *testing.T) {
+	var index Index // The zero value is usable
+	if err := index.Add(indexTestDocuments...); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	// Replacing by ID keeps one document per ID
+	if err := index.Add(Document{ID: "x", Text: "replaced", Vector: []float32{0, 1, 0}}); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if doc, _ := index.Get("x"); index.Len() != 3 || doc.Text != "replaced" {
+		t.Errorf("got %d documents and %q", index.Len(), doc.Text)
+	}
+
+	index.Delete("xy", "missing")
+	if _, ok := index.Get("xy"); ok || index.Len() != 2 {
+		t.Errorf("got %d documents after deleting one", index.Len())
+	}
+	// The document moved into the gap is still found by ID
+	if doc, ok := index.Get("z"); !ok || doc.Text != "along z" {
+		t.Errorf("got %+v, %v", doc, ok)
+	}
+
+	if removed := index.DeleteMatching(Filter{"draft": "yes"}); removed != 1 || index.Len() != 1 {
+		t.Errorf("removed %d, %d left", removed, index.Len())
+	}
+}
+
+func TestIndexReplaceMatching(t *testing.T) {
+	index := NewIndex(Cosine)
+	index.Add(indexTestDocuments...)
+
+	// Invalid replacements leave the index as it was
+	if err := index.ReplaceMatching(Filter{"lang": "en"}, Document{ID: "bad"}); err == nil || index.Len() != 3 {
+		t.Errorf("got %v with %d documents", err, index.Len())
+	}
+
+	// Once every document is replaced, the new ones may change the dimensions
+	err := index.ReplaceMatching(Filter{}, Document{ID: "a", Vector: []float32{1, 0}}, Document{ID: "b", Vector: []float32{0, 1}})
+	if err != nil || index.Len() != 2 {
+		t.Fatalf("got %v with %d documents", err, index.Len())
+	}
+	if results, err := index.Search([]float32{0, 1}, 1, nil); err != nil || results[0].ID != "b" {
+		t.Errorf("got %v, %v", ids(results), err)
+	}
+}
+
+func TestIndexSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	index := NewIndex(DotProduct)
+	index.Add(indexTestDocuments...)
+
+	if err := index.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadIndex(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if loaded.Similarity != DotProduct || loaded.Len() != len(indexTestDocuments) {
+		t.Errorf("got similarity %d with %d documents", loaded.Similarity, loaded.Len())
+	}
+	for _, doc := range indexTestDocuments {
+		if got, ok := loaded.Get(doc.ID); !ok || !reflect.DeepEqual(got, doc) {
+			t.Errorf("got %+v, want %+v", got, doc)
+		}
+	}
+
+	if _, err := LoadIndex(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
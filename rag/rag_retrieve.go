@@ -0,0 +1,118 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/NaheedRayan/openrouter-go/ai"
+)
+
+const defaultTopK = 4
+
+// citationPattern finds source numbers such as [2] in a reply
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// RetrieveOptions controls RetrieveAndComplete
+type RetrieveOptions struct {
+	TopK     int     // Chunks given to the model, defaults to 4
+	Filter   Filter  // Restricts retrieval to matching metadata
+	MinScore float64 // Chunks scoring below this are left out
+	Query    string  // Text to search for, defaults to the last user message
+}
+
+// Answer is a completion grounded in retrieved chunks
+type Answer struct {
+	ai.Response
+	Sources    []Result      // Chunks given to the model, numbered from 1 in order
+	Citations  []Result      // Sources the reply cites by number, in order of first citation
+	EmbedUsage ai.TokenUsage // Tokens spent embedding the query
+}
+
+// RetrieveAndComplete searches store for chunks relevant to the conversation, adds them
+// as numbered sources to the last user message and asks client to answer with citations
+func RetrieveAndComplete(ctx context.Context, client ai.Client, store *Store, messages []ai.InputMessage, config ai.ModelConfig, opts RetrieveOptions) (Answer, error) {
+	query := opts.Query
+	if query == "" {
+		last := lastUserMessage(messages)
+		if last < 0 {
+			return Answer{}, fmt.Errorf("no user message to retrieve for")
+		}
+		query = messages[last].Content
+	}
+
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+
+	results, embedUsage, err := store.Query(ctx, query, topK, opts.Filter)
+	if err != nil {
+		return Answer{EmbedUsage: embedUsage}, err
+	}
+
+	var sources []Result
+	for _, result := range results {
+		if result.Score >= opts.MinScore {
+			sources = append(sources, result)
+		}
+	}
+
+	response, err := client.TextCompletion(ctx, AugmentMessages(messages, sources), config)
+	answer := Answer{Response: response, Sources: sources, EmbedUsage: embedUsage}
+	if err != nil {
+		return answer, err
+	}
+
+	answer.Citations = citedSources(response.Text, sources)
+	return answer, nil
+}
+
+// AugmentMessages returns a copy of messages with sources prepended to the last user
+// message as a numbered context block. Use it to stream a grounded answer.
+func AugmentMessages(messages []ai.InputMessage, sources []Result) []ai.InputMessage {
+	last := lastUserMessage(messages)
+	if last < 0 || len(sources) == 0 {
+		return messages
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Answer using the numbered sources below. Cite the sources you use as [1], [2] and so on. ")
+	prompt.WriteString("If the sources do not contain the answer, say so.\n\n")
+	for i, source := range sources {
+		fmt.Fprintf(&prompt, "[%d] %s\n%s\n\n", i+1, source.Metadata[MetadataSource], source.Text)
+	}
+	prompt.WriteString("Question: ")
+	prompt.WriteString(messages[last].Content)
+
+	augmented := append([]ai.InputMessage(nil), messages...)
+	augmented[last].Content = prompt.String()
+	return augmented
+}
+
+// lastUserMessage returns the index of the last user message, or -1
+func lastUserMessage(messages []ai.InputMessage) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == ai.RoleUser {
+			return i
+		}
+	}
+	return -1
+}
+
+// citedSources returns the sources referenced by number in text
+func citedSources(text string, sources []Result) []Result {
+	var cited []Result
+	seen := map[int]bool{}
+	for _, match := range citationPattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil || n < 1 || n > len(sources) || seen[n] {
+			continue
+		}
+		seen[n] = true
+		cited = append(cited, sources[n-1])
+	}
+	return cited
+}
@@ -0,0 +1,94 @@
+package rag
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NaheedRayan/openrouter-go/ai"
+)
+
+// fakeClient answers with reply and records the messages it was sent
+type fakeClient struct {
+	ai.Client
+	reply    string
+	messages []ai.InputMessage
+}
+
+func (c *fakeClient) TextCompletion(ctx context.Context, messages []ai.InputMessage, config ai.ModelConfig) (ai.Response, error) {
+	c.messages = messages
+	return ai.Response{Text: c.reply}, nil
+}
+
+// newRetrieveTestStore indexes one chunk about each animal
+func newRetrieveTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	store := NewStore(&fakeEmbedder{}, ai.EmbedOptions{})
+	for _, source := range []string{"cat", "dog", "fish"} {
+		if _, err := store.AddText(context.Background(), source+".txt", "All about the "+source+".", nil); err != nil {
+			t.Fatalf("add: %v", err)
+		}
+	}
+	return store
+}
+
+func TestRetrieveAndComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      RetrieveOptions
+		reply     string
+		sources   []string
+		citations []string
+	}{
+		{"cites in order of first use", RetrieveOptions{TopK: 2}, "Dogs bark [2], cats purr [1] [2].", []string{"dog.txt#0", "cat.txt#0"}, []string{"cat.txt#0", "dog.txt#0"}},
+		{"ignores unknown numbers", RetrieveOptions{TopK: 1}, "See [1] and [7].", []string{"dog.txt#0"}, []string{"dog.txt#0"}},
+		{"min score", RetrieveOptions{TopK: 3, MinScore: 0.5}, "[1]", []string{"dog.txt#0"}, []string{"dog.txt#0"}},
+		{"explicit query", RetrieveOptions{TopK: 1, Query: "fish"}, "[1]", []string{"fish.txt#0"}, []string{"fish.txt#0"}},
+		{"filter", RetrieveOptions{Filter: Filter{MetadataSource: "cat.txt"}}, "no citation", []string{"cat.txt#0"}, nil},
+	}
+
+	for _, tt := range tests {
+		client := &fakeClient{reply: tt.reply}
+		messages := []ai.InputMessage{{Role: ai.RoleUser, Content: "hello"}, {Role: ai.RoleAssistant, Content: "hi"}, {Role: ai.RoleUser, Content: "tell me about the dog, the dog and the cat"}}
+
+		answer, err := RetrieveAndComplete(context.Background(), client, newRetrieveTestStore(t), messages, ai.ModelConfig{}, tt.opts)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := ids(answer.Sources); !reflect.DeepEqual(got, tt.sources) {
+			t.Errorf("%s: got sources %v, want %v", tt.name, got, tt.sources)
+		}
+		if got := ids(answer.Citations); !reflect.DeepEqual(got, tt.citations) {
+			t.Errorf("%s: got citations %v, want %v", tt.name, got, tt.citations)
+		}
+		if answer.Text != tt.reply || answer.EmbedUsage.InputTokens != 1 {
+			t.Errorf("%s: got %q with %d embedding tokens", tt.name, answer.Text, answer.EmbedUsage.InputTokens)
+		}
+
+		// Only the last user message carries the sources
+		last := client.messages[2].Content
+		if !strings.HasPrefix(last, "Answer using the numbered sources") || !strings.HasSuffix(last, "Question: tell me about the dog, the dog and the cat") {
+			t.Errorf("%s: got prompt %q", tt.name, last)
+		}
+		if client.messages[0].Content != "hello" || messages[2].Content != "tell me about the dog, the dog and the cat" {
+			t.Errorf("%s: other or caller messages were changed", tt.name)
+		}
+	}
+}
+
+func TestRetrieveWithoutUserMessage(t *testing.T) {
+	_, err := RetrieveAndComplete(context.Background(), &fakeClient{}, newRetrieveTestStore(t), []ai.InputMessage{{Role: ai.RoleSystem, Content: "sys"}}, ai.ModelConfig{}, RetrieveOptions{})
+	if err == nil {
+		t.Error("retrieved without a user message")
+	}
+}
+
+func TestAugmentMessagesWithoutSources(t *testing.T) {
+	messages := []ai.InputMessage{{Role: ai.RoleUser, Content: "hi"}}
+	if got := AugmentMessages(messages, nil); !reflect.DeepEqual(got, messages) {
+		t.Errorf("got %+v", got)
+	}
+}
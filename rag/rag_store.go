@@ -0,0 +1,86 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/NaheedRayan/openrouter-go/ai"
+)
+
+// Metadata keys set on every chunk added through a Store
+const (
+	MetadataSource = "source" // Source name passed to AddText
+	MetadataChunk  = "chunk"  // Position of the chunk within its source, from 0
+)
+
+// Store chunks text, embeds it with a provider and keeps the vectors in an Index
+type Store struct {
+	Embedder     ai.Embedder
+	EmbedOptions ai.EmbedOptions // Model, Dimensions and Normalize for every request; InputType is set per call
+	Chunker      Chunker
+	Index        *Index
+}
+
+// NewStore creates a store with an empty cosine index
+func NewStore(embedder ai.Embedder, opts ai.EmbedOptions) *Store {
+	return &Store{
+		Embedder:     embedder,
+		EmbedOptions: opts,
+		Index:        NewIndex(Cosine),
+	}
+}
+
+// AddText chunks and embeds text under a source name such as a file path or URL.
+// Chunks previously added under the same source are replaced; if embedding or indexing
+// fails, the old chunks are kept.
+func (s *Store) AddText(ctx context.Context, source, text string, metadata map[string]string) (ai.TokenUsage, error) {
+	chunks := s.Chunker.Split(text)
+	if len(chunks) == 0 {
+		s.Index.DeleteMatching(Filter{MetadataSource: source})
+		return ai.TokenUsage{}, nil
+	}
+
+	opts := s.EmbedOptions
+	opts.InputType = ai.EmbedInputDocument
+	vectors, usage, err := s.Embedder.Embed(ctx, chunks, opts)
+	if err != nil {
+		return usage, fmt.Errorf("error embedding %s: %w", source, err)
+	}
+
+	docs := make([]Document, len(chunks))
+	for i, chunk := range chunks {
+		chunkMetadata := make(map[string]string, len(metadata)+2)
+		for key, value := range metadata {
+			chunkMetadata[key] = value
+		}
+		chunkMetadata[MetadataSource] = source
+		chunkMetadata[MetadataChunk] = strconv.Itoa(i)
+
+		docs[i] = Document{
+			ID:       fmt.Sprintf("%s#%d", source, i),
+			Text:     chunk,
+			Metadata: chunkMetadata,
+			Vector:   vectors[i],
+		}
+	}
+
+	// Old chunks are only dropped once every new one is known to fit
+	return usage, s.Index.ReplaceMatching(Filter{MetadataSource: source}, docs...)
+}
+
+// Query embeds query and returns the k most similar chunks that match filter
+func (s *Store) Query(ctx context.Context, query string, k int, filter Filter) ([]Result, ai.TokenUsage, error) {
+	opts := s.EmbedOptions
+	opts.InputType = ai.EmbedInputQuery
+	vectors, usage, err := s.Embedder.Embed(ctx, []string{query}, opts)
+	if err != nil {
+		return nil, usage, fmt.Errorf("error embedding query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, usage, fmt.Errorf("expected 1 query embedding, got %d", len(vectors))
+	}
+
+	results, err := s.Index.Search(vectors[0], k, filter)
+	return results, usage, err
+}
@@ -0,0 +1,118 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/NaheedRayan/openrouter-go/ai"
+)
+
+// fakeEmbedder counts the words "cat", "dog" and "fish" in each input, so texts about the
+// same animal point the same way
+type fakeEmbedder struct {
+	err        error
+	inputTypes []string
+	calls      int
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, inputs []string, opts ai.EmbedOptions) ([][]float32, ai.TokenUsage, error) {
+	e.calls++
+	e.inputTypes = append(e.inputTypes, opts.InputType)
+	if e.err != nil {
+		return nil, ai.TokenUsage{}, e.err
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		input = strings.ToLower(input)
+		vectors[i] = []float32{
+			float32(strings.Count(input, "cat")),
+			float32(strings.Count(input, "dog")),
+			float32(strings.Count(input, "fish")) + 0.1,
+		}
+	}
+	return vectors, ai.TokenUsage{InputTokens: len(inputs), TotalTokens: len(inputs)}, nil
+}
+
+func TestStoreAddAndQuery(t *testing.T) {
+	embedder := &fakeEmbedder{}
+	store := NewStore(embedder, ai.EmbedOptions{})
+	store.Chunker = Chunker{Size: 40, Overlap: -1}
+	ctx := context.Background()
+
+	usage, err := store.AddText(ctx, "pets.txt", "The cat sleeps on the cat bed.\n\nThe dog barks at the dog next door.", map[string]string{"owner": "ann"})
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := store.AddText(ctx, "tank.txt", "A fish swims around the fish tank.", nil); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if store.Index.Len() != 3 || usage.InputTokens != 2 {
+		t.Fatalf("got %d chunks and %d tokens", store.Index.Len(), usage.InputTokens)
+	}
+
+	doc, ok := store.Index.Get("pets.txt#1")
+	if !ok || doc.Metadata[MetadataSource] != "pets.txt" || doc.Metadata[MetadataChunk] != "1" || doc.Metadata["owner"] != "ann" {
+		t.Errorf("got %+v", doc)
+	}
+
+	tests := []struct {
+		query  string
+		filter Filter
+		want   string
+	}{
+		{"where is the dog?", nil, "pets.txt#1"},
+		{"feed the cat", nil, "pets.txt#0"},
+		{"fish", nil, "tank.txt#0"},
+		{"fish", Filter{"owner": "ann"}, "pets.txt#"},
+	}
+	for _, tt := range tests {
+		results, _, err := store.Query(ctx, tt.query, 1, tt.filter)
+		if err != nil || len(results) != 1 || !strings.HasPrefix(results[0].ID, tt.want) {
+			t.Errorf("%q: got %v, %v, want %s", tt.query, ids(results), err, tt.want)
+		}
+	}
+
+	if embedder.inputTypes[0] != ai.EmbedInputDocument || embedder.inputTypes[len(embedder.inputTypes)-1] != ai.EmbedInputQuery {
+		t.Errorf("got input types %v", embedder.inputTypes)
+	}
+}
+
+func TestStoreReplacesSource(t *testing.T) {
+	embedder := &fakeEmbedder{}
+	store := NewStore(embedder, ai.EmbedOptions{})
+	store.Chunker = Chunker{Size: 20, Overlap: -1}
+	ctx := context.Background()
+
+	store.AddText(ctx, "notes", "one cat here. two dogs there. three fish swim.", nil)
+	store.AddText(ctx, "other", "a dog", nil)
+	before := store.Index.Len()
+
+	// A failed embedding keeps the old chunks
+	embedder.err = errors.New("unavailable")
+	if _, err := store.AddText(ctx, "notes", "replacement", nil); err == nil || store.Index.Len() != before {
+		t.Errorf("got %v with %d chunks, want an error and %d", err, store.Index.Len(), before)
+	}
+	embedder.err = nil
+
+	if _, err := store.AddText(ctx, "notes", "only a cat now", nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if store.Index.Len() != 2 {
+		t.Errorf("got %d chunks, want the new one and the other source", store.Index.Len())
+	}
+
+	// Empty text removes the source
+	if _, err := store.AddText(ctx, "notes", "  ", nil); err != nil || store.Index.Len() != 1 {
+		t.Errorf("got %v with %d chunks", err, store.Index.Len())
+	}
+}
+
+func TestStoreQueryError(t *testing.T) {
+	store := NewStore(&fakeEmbedder{err: errors.New("unavailable")}, ai.EmbedOptions{})
+	if _, _, err := store.Query(context.Background(), "cat", 1, nil); err == nil {
+		t.Error("query succeeded without an embedding")
+	}
+}